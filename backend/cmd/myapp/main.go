@@ -19,8 +19,9 @@ func main() {
 		log.Printf("警告: 加载 .env 文件失败: %v", err)
 	}
 
-	repository.DBconnect() //数据库连接
-	service.Init()         //初始化每天学习时间记录
+	repository.DBconnect()  //数据库连接
+	service.StartEventBus() //事件总线（成就评估等订阅方）
+	service.Init()          //初始化每天学习时间记录
 	r := gin.Default()
 
 	// 添加全局 CORS 中间件
@@ -76,6 +77,16 @@ func main() {
 	utils.LogInfo("成就模块加载成功", nil)
 	handler.AI(r) //AI学习计划
 	utils.LogInfo("AI模块加载成功", nil)
+	handler.WeeklyReport(r) //学习周报
+	utils.LogInfo("周报模块加载成功", nil)
+	handler.Relation(r) //关注和好友
+	utils.LogInfo("好友模块加载成功", nil)
+	handler.StudyGroup(r) //学习小组
+	utils.LogInfo("小组模块加载成功", nil)
+	handler.Challenge(r) //挑战赛
+	utils.LogInfo("挑战赛模块加载成功", nil)
+	handler.Report(r) //举报与审核
+	utils.LogInfo("举报模块加载成功", nil)
 	// TODO: 实现这些函数后再启用
 	// handler.ChatHistory(r) //聊天历史 // P1修复：聊天历史和房间管理
 	// utils.LogInfo("聊天历史模块加载成功", nil)
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
 	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/service"
 
 	"github.com/gin-gonic/gin"
@@ -10,6 +11,8 @@ func BasicUser(r *gin.Engine) {
 	// 公开接口：不需要认证
 	r.POST("/api/register", service.RegisterUser())
 	r.GET("/api/avatar/:id", service.ServeAvatar())
+	r.GET("/api/avatars/:name", service.ServeCustomAvatar())
+	r.GET("/api/files/:key", service.ServeAttachmentFile())
 	r.POST("/api/login", service.LoginUser())
 	r.POST("/api/sendEmailCode", service.SendEmailCode()) // 修复：发送验证码
 	r.POST("/api/verifyEmail", service.VerifyEmail())     // 新增：验证邮箱验证码
@@ -26,12 +29,18 @@ func BasicUser(r *gin.Engine) {
 	e.GET("/api/getUser", service.GetUser())
 	e.GET("/api/getTodayPoints", service.GetTodayPoints())
 	e.POST("/api/swithhead", service.SwithHead())
+	e.POST("/api/avatar", service.UploadAvatar())
+	e.DELETE("/api/avatar", service.DeleteAvatar())
 	e.PUT("/api/updateDaka", service.DoDaKa())
 	e.PUT("/api/updateRemindTime", service.UpdateUserRemindTime())
 	e.PUT("/api/updateRemindStatus", service.UpdateUserRemind())
 	e.GET("/api/getDakaRecords", service.GetDaKaRecords())
 	e.PUT("/api/addPoints", service.AddPointsHandler())
 	e.GET("/api/getUserStats", service.GetUserStats())
+	e.GET("/api/notifications", service.GetNotifications())
+	e.GET("/api/notifications/unread-count", service.GetUnreadNotificationCount())
+	e.POST("/api/notifications/:id/read", service.MarkNotificationRead())
+	e.POST("/api/notifications/read-all", service.MarkAllNotificationsRead())
 }
 
 func Flag(r *gin.Engine) {
@@ -60,6 +69,8 @@ func BasicFlag(r *gin.Engine) {
 	e.POST("/api/flagcomment", service.CommentOnFlag())
 	e.DELETE("/api/flagdeletecomment", service.DeleteFlagComment())
 	e.GET("/api/getflaglike", service.GetFlagLikes())
+	e.GET("/api/flags/:id/comments", service.GetFlagComments())
+	e.GET("/api/flags/:id/comments/:commentId/replies", service.GetFlagCommentReplies())
 
 	// 新增接口：获取有日期的flag（用于日历高亮）
 	e.GET("/api/flags/with-dates", service.GetFlagsWithDates())
@@ -83,6 +94,16 @@ func BasicPost(r *gin.Engine) {
 	e.DELETE("/api/deleteUserPost", service.DeleteUserPost())
 	e.POST("/api/commentOnPost", service.CommentOnPost())
 	e.DELETE("/api/deleteComment", service.DeleteUserPostComment())
+	e.POST("/api/attachments", service.UploadAttachment())
+	e.DELETE("/api/attachments/:id", service.DeleteAttachment())
+	e.PUT("/api/posts/:id", service.EditUserPost())
+	e.GET("/api/posts/:id/revisions", service.GetPostRevisions())
+	e.GET("/api/posts/:id/comments", service.GetPostComments())
+	e.GET("/api/posts/:id/comments/:commentId/replies", service.GetPostCommentReplies())
+	e.GET("/api/feed", service.GetFeed())
+	e.GET("/api/trending", service.GetTrending())
+	e.GET("/api/tags/trending", service.GetTrendingTags())
+	e.GET("/api/tags/:name/posts", service.GetPostsByTag())
 }
 
 func ChatWebSocket(r *gin.Engine) {
@@ -108,6 +129,11 @@ func Ranking(r *gin.Engine) {
 	r.GET("/api/countranking", service.GetUserCount())
 	r.GET("/api/learnTimeRanking", service.GetUserMonthLearnTime())
 	r.GET("/api/dakaRanking", service.GetUserTotalDaka())
+
+	// 统一封神榜需要登录，以便返回自己的名次
+	e := r.Group("/")
+	e.Use(service.JWTAuth())
+	e.GET("/api/ranking", service.GetRanking())
 }
 
 func LearnTime(r *gin.Engine) {
@@ -150,6 +176,104 @@ func AI(r *gin.Engine) {
 	e := r.Group("/")
 	e.Use(service.JWTAuth())
 	e.POST("/api/ai/generate-plan", service.GenerateLearningPlan)
+	e.POST("/api/ai/generate-plan/stream", service.GenerateLearningPlanStream) // SSE 流式生成
+	e.GET("/api/ai/plans", service.GetAIPlanHistory())
+	e.GET("/api/ai/plans/:id", service.GetAIPlanDetail())
+	e.POST("/api/ai/plans/:id/revise", service.RevisePlan())       // 根据执行情况调整计划
+	e.POST("/api/ai/plans/:id/apply", service.ApplyPlanRevision()) // 应用调整到已有flag
+}
+
+// 学习周报路由
+func WeeklyReport(r *gin.Engine) {
+	// 所有接口需要认证：创建路由组
+	e := r.Group("/")
+	e.Use(service.JWTAuth())
+	e.GET("/api/reports/weekly", service.GetWeeklyReports())
+	e.POST("/api/reports/weekly", service.GenerateWeeklyReport())
+	e.GET("/api/reports/weekly/:id", service.GetWeeklyReportDetail())
+}
+
+// 关注、好友和黑名单路由
+func Relation(r *gin.Engine) {
+	// 所有接口需要认证：创建路由组
+	e := r.Group("/")
+	e.Use(service.JWTAuth())
+	e.POST("/api/users/:id/follow", service.FollowUser())
+	e.DELETE("/api/users/:id/follow", service.UnfollowUser())
+	e.POST("/api/users/:id/block", service.BlockUser())
+	e.DELETE("/api/users/:id/block", service.UnblockUser())
+	e.GET("/api/users/:id/followers", service.GetFollowers())
+	e.GET("/api/users/:id/following", service.GetFollowing())
+	e.GET("/api/blocks", service.GetBlockedUsers())
+	e.GET("/api/friends", service.GetFriends())
+	e.POST("/api/friends/requests", service.SendFriendRequest())
+	e.GET("/api/friends/requests", service.GetFriendRequests())
+	e.POST("/api/friends/requests/:id/accept", service.AcceptFriendRequest())
+	e.POST("/api/friends/requests/:id/reject", service.RejectFriendRequest())
+	e.GET("/api/ranking/friends", service.GetFriendsRanking()) // 好友封神榜
+}
+
+// 学习小组路由
+func StudyGroup(r *gin.Engine) {
+	// 所有接口需要认证：创建路由组
+	e := r.Group("/")
+	e.Use(service.JWTAuth())
+	e.POST("/api/groups", service.CreateStudyGroup())
+	e.GET("/api/groups", service.GetMyStudyGroups())
+	e.POST("/api/groups/join", service.JoinStudyGroup()) // 通过邀请码加入
+	e.GET("/api/groups/:id", service.GetStudyGroup())
+	e.PUT("/api/groups/:id", service.UpdateStudyGroup())
+	e.DELETE("/api/groups/:id", service.DeleteStudyGroup())
+	e.POST("/api/groups/:id/invite-code", service.ResetGroupInviteCode())
+	e.POST("/api/groups/:id/leave", service.LeaveStudyGroup())
+	e.GET("/api/groups/:id/members", service.GetGroupMembers())
+	e.POST("/api/groups/:id/members/:uid/approve", service.ApproveGroupMember())
+	e.POST("/api/groups/:id/members/:uid/reject", service.RejectGroupMember())
+	e.PUT("/api/groups/:id/members/:uid/role", service.SetGroupMemberRole())
+	e.DELETE("/api/groups/:id/members/:uid", service.RemoveGroupMember())
+	e.POST("/api/groups/:id/flags", service.CreateGroupFlag())
+	e.GET("/api/groups/:id/flags", service.GetGroupFlags())
+	e.DELETE("/api/groups/:id/flags/:fid", service.DeleteGroupFlag())
+	e.GET("/api/groups/:id/ranking", service.GetGroupRanking())
+	e.GET("/api/groups/:id/activities", service.GetGroupActivities())
+}
+
+// 挑战赛路由
+func Challenge(r *gin.Engine) {
+	// 所有接口需要认证：创建路由组
+	e := r.Group("/")
+	e.Use(service.JWTAuth())
+	e.GET("/api/challenges", service.GetChallenges())
+	e.GET("/api/challenges/:id", service.GetChallenge())
+	e.POST("/api/challenges/:id/join", service.JoinChallenge())
+	e.DELETE("/api/challenges/:id/join", service.LeaveChallenge())
+	e.GET("/api/challenges/:id/participants", service.GetChallengeParticipants())
+	e.GET("/api/badges", service.GetMyChallengeBadges())
+
+	// 管理员接口
+	a := r.Group("/api/admin")
+	a.Use(service.JWTAuth(), service.RequireRole(model.UserRoleAdmin))
+	a.POST("/challenges", service.CreateChallenge())
+	a.PUT("/challenges/:id", service.UpdateChallenge())
+	a.DELETE("/challenges/:id", service.DeleteChallenge())
+	a.POST("/challenges/:id/evaluate", service.EvaluateChallenge())
+}
+
+// 举报与审核路由
+func Report(r *gin.Engine) {
+	e := r.Group("/")
+	e.Use(service.JWTAuth())
+	e.POST("/api/reports", service.CreateReport())
+
+	// 管理员接口：审核队列
+	a := r.Group("/api/admin")
+	a.Use(service.JWTAuth(), service.RequireRole(model.UserRoleAdmin))
+	a.GET("/reports", service.GetReports())
+	a.POST("/reports/:id/resolve", service.ResolveReport())
+	a.POST("/users/:id/unsuspend", service.UnsuspendUser())
+	a.POST("/content/unhide", service.UnhideContent())
+	a.GET("/moderation", service.GetModerationQueue())
+	a.POST("/moderation/:id/resolve", service.ResolveModeration())
 }
 
 // P1修复：聊天历史和谈玄斋管理路由
@@ -0,0 +1,116 @@
+package model
+
+// 成就规则类型：决定如何把指标的原始记录汇总成一个数值
+const (
+	AchievementRuleTotal      = "total"       // 累计值
+	AchievementRuleDailyMax   = "daily_max"   // 单日最大值
+	AchievementRuleStreak     = "streak"      // 最长连续天数
+	AchievementRuleDistinct   = "distinct"    // 不同取值的数量（如flag标签）
+	AchievementRuleHourWindow = "hour_window" // 在指定时段内发生的天数
+)
+
+// 成就指标：规则汇总的数据来源
+const (
+	AchievementMetricFlagsCreated    = "flags_created"    // 创建的flag
+	AchievementMetricFlagCompletions = "flag_completions" // flag完成记录
+	AchievementMetricPoints          = "points"           // 积分
+	AchievementMetricLearnMinutes    = "learn_minutes"    // 学习时长（分钟）
+	AchievementMetricDaka            = "daka"             // 打卡
+	AchievementMetricPosts           = "posts"            // 发布的帖子
+	AchievementMetricAchievements    = "achievements"     // 已解锁的其他成就
+)
+
+// 成就等级（铜/银/金）
+type AchievementTier struct {
+	Name      string
+	Threshold int
+	Points    int // 达到该等级的奖励积分
+}
+
+// 成就定义
+type AchievementDef struct {
+	Name        string // 成就名，同时作为用户成就记录的唯一键
+	Description string
+	Rule        string
+	Metric      string
+	Threshold   int // 单级成就的阈值；分级成就以 Tiers 为准
+	HourFrom    int // hour_window 规则的起始小时（含）
+	HourTo      int // hour_window 规则的结束小时（不含）
+	Icon        string
+	Points      int               // 单级成就的解锁奖励积分
+	Tiers       []AchievementTier // 分级成就的各等级，阈值递增
+	Hidden      bool              // 隐藏成就：解锁前不展示描述和进度
+}
+
+// 成就注册表：新增成就只需在这里追加定义
+var AchievementDefs = []AchievementDef{
+	{Name: "首次完成", Description: "第一次设置flag", Rule: AchievementRuleTotal, Metric: AchievementMetricFlagsCreated, Threshold: 1, Icon: "🚩", Points: 10},
+	{Name: "7天连卡", Description: "连续打卡7天", Rule: AchievementRuleStreak, Metric: AchievementMetricDaka, Threshold: 7, Icon: "📅", Points: 30},
+	{Name: "任务大师", Description: "完成50个flag", Rule: AchievementRuleTotal, Metric: AchievementMetricFlagCompletions, Threshold: 50, Icon: "🏅", Points: 100},
+	{Name: "目标达成", Description: "积分超过1000", Rule: AchievementRuleTotal, Metric: AchievementMetricPoints, Threshold: 1000, Icon: "🎯", Points: 50},
+	{Name: "学习之星", Description: "累计学习时间达到1000/5000/20000分钟", Rule: AchievementRuleTotal, Metric: AchievementMetricLearnMinutes, Icon: "⭐", Tiers: []AchievementTier{
+		{Name: "铜", Threshold: 1000, Points: 50},
+		{Name: "银", Threshold: 5000, Points: 150},
+		{Name: "金", Threshold: 20000, Points: 500},
+	}},
+	{Name: "坚持不懈", Description: "累计打卡30/100/365天", Rule: AchievementRuleTotal, Metric: AchievementMetricDaka, Icon: "💪", Tiers: []AchievementTier{
+		{Name: "铜", Threshold: 30, Points: 80},
+		{Name: "银", Threshold: 100, Points: 200},
+		{Name: "金", Threshold: 365, Points: 600},
+	}},
+	{Name: "效率达人", Description: "单日完成5个flag", Rule: AchievementRuleDailyMax, Metric: AchievementMetricFlagCompletions, Threshold: 5, Icon: "⚡", Points: 40},
+	{Name: "专注大师", Description: "单日学习时长超过4小时", Rule: AchievementRuleDailyMax, Metric: AchievementMetricLearnMinutes, Threshold: 240, Icon: "🧘", Points: 40},
+	{Name: "早起鸟", Description: "早上6点前打卡5次", Rule: AchievementRuleHourWindow, Metric: AchievementMetricDaka, Threshold: 5, HourFrom: 0, HourTo: 6, Icon: "🐦", Points: 30},
+	{Name: "夜猫子", Description: "晚上10点后打卡5次", Rule: AchievementRuleHourWindow, Metric: AchievementMetricDaka, Threshold: 5, HourFrom: 22, HourTo: 24, Icon: "🦉", Points: 30},
+	{Name: "完美主义", Description: "连续10天完成flag", Rule: AchievementRuleStreak, Metric: AchievementMetricFlagCompletions, Threshold: 10, Icon: "💎", Points: 60},
+	{Name: "全能选手", Description: "完成5种不同标签的flag", Rule: AchievementRuleDistinct, Metric: AchievementMetricFlagCompletions, Threshold: 5, Icon: "🎨", Points: 40},
+	{Name: "社交达人", Description: "发布10条动态", Rule: AchievementRuleTotal, Metric: AchievementMetricPosts, Threshold: 10, Icon: "💬", Points: 30},
+	{Name: "时间管理者", Description: "连续30天完成至少1个flag", Rule: AchievementRuleStreak, Metric: AchievementMetricFlagCompletions, Threshold: 30, Icon: "⏰", Points: 150},
+	{Name: "百日筑基", Description: "连续打卡100天", Rule: AchievementRuleStreak, Metric: AchievementMetricDaka, Threshold: 100, Icon: "🏯", Points: 300, Hidden: true},
+	{Name: "笔耕不辍", Description: "单日发布3条动态", Rule: AchievementRuleDailyMax, Metric: AchievementMetricPosts, Threshold: 3, Icon: "✒️", Points: 30, Hidden: true},
+	{Name: "成就收集者", Description: "解锁10个徽章", Rule: AchievementRuleTotal, Metric: AchievementMetricAchievements, Threshold: 10, Icon: "🏆", Points: 100},
+}
+
+// 成就的各等级；单级成就视为只有一级
+func (d AchievementDef) Levels() []AchievementTier {
+	if len(d.Tiers) > 0 {
+		return d.Tiers
+	}
+	return []AchievementTier{{Threshold: d.Threshold, Points: d.Points}}
+}
+
+// 最高等级的阈值
+func (d AchievementDef) MaxThreshold() int {
+	levels := d.Levels()
+	return levels[len(levels)-1].Threshold
+}
+
+// 数值达到的等级（0 表示未解锁）
+func (d AchievementDef) TierFor(value int) int {
+	tier := 0
+	for _, level := range d.Levels() {
+		if value >= level.Threshold {
+			tier++
+		}
+	}
+	return tier
+}
+
+// 根据成就名查找定义
+func FindAchievementDef(name string) (AchievementDef, bool) {
+	for _, def := range AchievementDefs {
+		if def.Name == name {
+			return def, true
+		}
+	}
+	return AchievementDef{}, false
+}
+
+// 所有有效的成就名
+func AchievementNames() []string {
+	names := make([]string, 0, len(AchievementDefs))
+	for _, def := range AchievementDefs {
+		names = append(names, def.Name)
+	}
+	return names
+}
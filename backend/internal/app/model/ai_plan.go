@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// AI学习计划记录（每次生成或命中缓存都会保存一条）
+type AIPlan struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"index" json:"user_id"`
+	Goal          string     `json:"goal"`                                 // 请求中的学习目标
+	Background    string     `json:"background"`                           // 请求中的个人背景
+	ReqDifficulty int        `json:"req_difficulty"`                       // 请求中的期望难度
+	RequestHash   string     `gorm:"size:64;index" json:"-"`               // 归一化请求的哈希，用于缓存命中
+	Flag          string     `json:"flag"`                                 // 生成的计划标题
+	Difficulty    int        `json:"difficulty"`                           // 生成的难度评分
+	Plan          string     `gorm:"type:text" json:"plan"`                // 计划正文
+	Cached        bool       `gorm:"not null;default:false" json:"cached"` // 是否命中缓存（不计入每日配额）
+	ParentID      *uint      `gorm:"index" json:"parent_id,omitempty"`     // 调整版计划对应的原计划ID
+	Adjustments   string     `gorm:"type:text" json:"-"`                   // 调整建议（JSON）
+	AppliedAt     *time.Time `json:"applied_at,omitempty"`                 // 调整应用到flag的时间
+	CreatedAt     time.Time  `gorm:"index" json:"created_at"`
+}
+
+// 学习计划以外的模型调用记录（如手动生成周报），与真实生成的计划一起计入每日配额
+type AIUsage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Task      string    `gorm:"size:32" json:"task"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 附件归属类型
+const (
+	AttachmentOwnerPost        = "post"
+	AttachmentOwnerPostComment = "post_comment"
+	AttachmentOwnerFlagComment = "flag_comment"
+	AttachmentOwnerChatMessage = "chat_message"
+)
+
+// 上传的图片附件；上传后未被引用的附件会被定时清理
+type Attachment struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	Key         string    `gorm:"size:64;uniqueIndex;not null" json:"-"` // 原图在存储中的文件名
+	ThumbKey    string    `gorm:"size:64;index" json:"-"`                // 缩略图在存储中的文件名
+	ContentType string    `gorm:"size:32" json:"content_type"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	OwnerType   string    `gorm:"size:32;index:idx_attachment_owner" json:"-"` // 为空表示尚未被引用
+	OwnerID     uint      `gorm:"index:idx_attachment_owner" json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	URL         string    `gorm:"-" json:"url"`
+	ThumbURL    string    `gorm:"-" json:"thumb_url"`
+}
+
+func (a *Attachment) fillURLs() {
+	a.URL = "/api/files/" + a.Key
+	a.ThumbURL = a.URL
+	if a.ThumbKey != "" {
+		a.ThumbURL = "/api/files/" + a.ThumbKey
+	}
+}
+
+// AfterFind - GORM钩子：查询后填充访问地址
+func (a *Attachment) AfterFind(tx *gorm.DB) error {
+	a.fillURLs()
+	return nil
+}
+
+// AfterCreate - GORM钩子：创建后填充访问地址
+func (a *Attachment) AfterCreate(tx *gorm.DB) error {
+	a.fillURLs()
+	return nil
+}
@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// 挑战赛：在起止日期内完成指定指标的目标即可获得积分和徽章
+type Challenge struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	Title        string     `gorm:"size:100;not null" json:"title"`
+	Description  string     `gorm:"size:1000" json:"description"`
+	Metric       string     `gorm:"size:32;not null" json:"metric"` // daka_days / learn_minutes / flag_completions
+	Label        int        `json:"label"`                          // flag_completions 时限定的flag标签（1-5），0 表示不限
+	Target       int        `gorm:"not null" json:"target"`
+	StartDate    time.Time  `gorm:"index" json:"start_date"` // 开始日期 00:00
+	EndDate      time.Time  `gorm:"index" json:"end_date"`   // 结束日期 00:00（当天计入）
+	RewardPoints int        `json:"reward_points"`
+	BadgeName    string     `gorm:"size:50" json:"badge_name"`
+	BadgeIcon    string     `gorm:"size:16" json:"badge_icon"`
+	CreatedBy    uint       `json:"created_by"`
+	SettledAt    *time.Time `json:"settled_at"` // 结束后最后一次评估的时间
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// 统计截止时间（结束日期的第二天 00:00）
+func (c Challenge) Deadline() time.Time {
+	return c.EndDate.AddDate(0, 0, 1)
+}
+
+// 挑战赛参与者
+type ChallengeParticipant struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	ChallengeID uint       `gorm:"uniqueIndex:idx_challenge_user;not null" json:"challenge_id"`
+	UserID      uint       `gorm:"uniqueIndex:idx_challenge_user;index;not null" json:"user_id"`
+	Progress    int        `json:"progress"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"joined_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// 挑战赛徽章（完成挑战后获得）
+type ChallengeBadge struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"uniqueIndex:idx_badge_user_challenge;not null" json:"user_id"`
+	ChallengeID uint      `gorm:"uniqueIndex:idx_badge_user_challenge;not null" json:"challenge_id"`
+	Name        string    `gorm:"size:50" json:"name"`
+	Icon        string    `gorm:"size:16" json:"icon"`
+	CreatedAt   time.Time `json:"awarded_at"`
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// 审核记录状态
+const (
+	ModerationStatusDone     = "done"     // 无需人工处理
+	ModerationStatusPending  = "pending"  // 待人工复核
+	ModerationStatusApproved = "approved" // 复核通过
+	ModerationStatusRejected = "rejected" // 复核判定违规
+)
+
+// 内容审核记录（命中敏感词或模型判定需处理时保存）
+type ModerationRecord struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"index" json:"user_id"`
+	Scene      string     `gorm:"size:32;index" json:"scene"`                                       // post / post_comment / flag_comment / chat / ai_goal
+	Content    string     `gorm:"type:text" json:"content"`                                         // 原始内容（替换前）
+	Action     string     `gorm:"size:16;index" json:"action"`                                      // block / mask / review
+	Hits       string     `json:"hits"`                                                             // 命中的敏感词，逗号分隔
+	Source     string     `gorm:"size:16" json:"source"`                                            // dict / llm
+	Reason     string     `json:"reason,omitempty"`                                                 // 模型给出的理由
+	TargetType string     `gorm:"size:32;index:idx_moderation_target" json:"target_type,omitempty"` // 内容保存后回填：post / post_comment / flag_comment / chat_message
+	TargetID   uint       `gorm:"index:idx_moderation_target" json:"target_id,omitempty"`
+	Status     string     `gorm:"size:16;index;default:pending" json:"status"` // 命中复核词或模型要求复核时为 pending（mask 也可能同时需要复核），其余为 done
+	Note       string     `gorm:"size:255" json:"note,omitempty"`              // 复核备注
+	HandlerID  *uint      `json:"handler_id,omitempty"`                        // 复核的管理员
+	HandledAt  *time.Time `json:"handled_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// 通知类型
+const (
+	NotificationLike        = "like"        // 帖子或flag被点赞
+	NotificationComment     = "comment"     // 帖子或flag被评论
+	NotificationReply       = "reply"       // 评论被回复
+	NotificationMention     = "mention"     // 被 @
+	NotificationMessage     = "message"     // 离线时收到私信
+	NotificationAchievement = "achievement" // 解锁成就
+	NotificationModeration  = "moderation"  // 举报处理结果、警告、封禁
+)
+
+// 除 ContentType* 之外的通知目标类型
+const NotificationTargetAchievement = "achievement"
+
+// 站内通知
+type Notification struct {
+	ID         uint                   `gorm:"primaryKey" json:"id"`
+	UserID     uint                   `gorm:"index:idx_notification_user;not null" json:"user_id"` // 接收者
+	Type       string                 `gorm:"size:32;not null" json:"type"`
+	ActorID    uint                   `gorm:"index" json:"actor_id,omitempty"`       // 触发者，系统通知为 0
+	TargetType string                 `gorm:"size:32" json:"target_type,omitempty"`  // post / flag / post_comment / flag_comment / chat_message / achievement / report
+	TargetID   uint                   `json:"target_id,omitempty"`                   // 目标ID
+	Content    string                 `gorm:"size:255" json:"content"`               // 摘要
+	Data       map[string]interface{} `gorm:"serializer:json;type:text" json:"data"` // 前端跳转需要的附加信息（post_id、flag_id 等）
+	IsRead     bool                   `gorm:"index:idx_notification_user;not null;default:false" json:"is_read"`
+	ReadAt     *time.Time             `json:"read_at,omitempty"`
+	CreatedAt  time.Time              `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// 帖子历史版本：每次编辑前保存一份旧的标题和内容
+type PostRevision struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	PostID    uint      `gorm:"index;not null" json:"post_id"`
+	Title     string    `json:"title"`
+	Content   string    `gorm:"type:text" json:"content"`
+	CreatedAt time.Time `json:"created_at"` // 被替换下来的时间
+}
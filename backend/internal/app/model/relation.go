@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// 用户关系类型
+const (
+	RelationFollow = "follow" // 关注
+	RelationBlock  = "block"  // 拉黑
+)
+
+// 好友申请状态
+const (
+	FriendRequestPending  = "pending"
+	FriendRequestAccepted = "accepted"
+	FriendRequestRejected = "rejected"
+)
+
+// 用户关系（单向）：UserID 关注/拉黑 TargetID；互相关注即为好友
+type UserRelation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_user_target_type;not null" json:"user_id"`
+	TargetID  uint      `gorm:"uniqueIndex:idx_user_target_type;index;not null" json:"target_id"`
+	Type      string    `gorm:"uniqueIndex:idx_user_target_type;size:16;not null" json:"type"` // follow / block
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 好友申请，同意后双方互相关注
+type FriendRequest struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	FromID    uint      `gorm:"index;not null" json:"from_id"`
+	ToID      uint      `gorm:"index;not null" json:"to_id"`
+	Message   string    `gorm:"size:100" json:"message"`
+	Status    string    `gorm:"size:16;index;default:'pending'" json:"status"` // pending / accepted / rejected
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
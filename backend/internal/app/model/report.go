@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+// 举报状态
+const (
+	ReportStatusOpen      = "open"      // 待处理
+	ReportStatusActioned  = "actioned"  // 已处理
+	ReportStatusDismissed = "dismissed" // 已驳回
+)
+
+// 管理员处理举报的方式
+const (
+	ReportActionHide    = "hide"    // 隐藏内容
+	ReportActionDelete  = "delete"  // 删除内容
+	ReportActionWarn    = "warn"    // 警告作者
+	ReportActionSuspend = "suspend" // 封禁作者
+	ReportActionDismiss = "dismiss" // 驳回举报
+)
+
+// 用户举报；同一条内容的举报会一起处理
+type Report struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	ReporterID   uint       `gorm:"index;not null" json:"reporter_id"`
+	TargetType   string     `gorm:"size:32;index:idx_report_target;not null" json:"target_type"` // post / post_comment / flag / flag_comment / chat_message
+	TargetID     uint       `gorm:"index:idx_report_target;not null" json:"target_id"`
+	TargetUserID uint       `gorm:"index" json:"target_user_id"`              // 被举报内容的作者
+	Reason       string     `gorm:"size:32;not null" json:"reason"`           // spam / abuse / porn / illegal / other
+	Detail       string     `gorm:"size:500" json:"detail"`                   // 举报说明
+	Snapshot     string     `gorm:"type:text" json:"snapshot"`                // 举报时的内容快照，内容删除后仍可查看
+	Status       string     `gorm:"size:16;index;default:open" json:"status"` // open / actioned / dismissed
+	Action       string     `gorm:"size:16" json:"action,omitempty"`          // 处理方式
+	Note         string     `gorm:"size:255" json:"note,omitempty"`           // 处理备注
+	HandlerID    *uint      `json:"handler_id,omitempty"`                     // 处理的管理员
+	HandledAt    *time.Time `json:"handled_at,omitempty"`
+	CreatedAt    time.Time  `gorm:"index" json:"created_at"`
+}
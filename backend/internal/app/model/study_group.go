@@ -0,0 +1,82 @@
+package model
+
+import "time"
+
+// 小组成员角色
+const (
+	GroupRoleOwner  = "owner"
+	GroupRoleAdmin  = "admin"
+	GroupRoleMember = "member"
+)
+
+// 小组成员状态
+const (
+	GroupMemberPending = "pending" // 等待审批
+	GroupMemberActive  = "active"
+)
+
+// 学习小组
+type StudyGroup struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"size:50;not null" json:"name"`
+	Description  string    `gorm:"size:500" json:"description"`
+	OwnerID      uint      `gorm:"index;not null" json:"owner_id"`
+	InviteCode   string    `gorm:"size:16;uniqueIndex" json:"-"`      // 只对组长和管理员返回
+	NeedApproval bool      `gorm:"default:true" json:"need_approval"` // 通过邀请码加入是否需要审批
+	MaxMembers   int       `gorm:"default:50" json:"max_members"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// 小组成员
+type GroupMember struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	GroupID   uint       `gorm:"uniqueIndex:idx_group_user;not null" json:"group_id"`
+	UserID    uint       `gorm:"uniqueIndex:idx_group_user;index;not null" json:"user_id"`
+	Role      string     `gorm:"size:16;default:'member'" json:"role"`    // owner / admin / member
+	Status    string     `gorm:"size:16;default:'pending'" json:"status"` // pending / active
+	JoinedAt  *time.Time `json:"joined_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// 小组共同目标：统计全体成员在时间范围内的指标之和
+type GroupFlag struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	GroupID     uint       `gorm:"index;not null" json:"group_id"`
+	Title       string     `gorm:"size:100;not null" json:"title"`
+	Description string     `gorm:"size:500" json:"description"`
+	Metric      string     `gorm:"size:32;not null" json:"metric"` // learn_minutes / daka_days / flag_completions / points
+	Target      int        `gorm:"not null" json:"target"`
+	StartTime   time.Time  `json:"start_time"`
+	EndTime     time.Time  `json:"end_time"`
+	CreatedBy   uint       `json:"created_by"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// 小组动态
+type GroupActivity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	GroupID   uint      `gorm:"index:idx_group_created;not null" json:"group_id"`
+	UserID    uint      `json:"user_id"`
+	Type      string    `gorm:"size:32" json:"type"`
+	Content   string    `gorm:"size:255" json:"content"`
+	CreatedAt time.Time `gorm:"index:idx_group_created" json:"created_at"`
+}
+
+// 目标指标（小组共同目标、挑战赛共用）
+const (
+	GoalMetricLearnMinutes    = "learn_minutes"    // 学习时长（分钟）
+	GoalMetricDakaDays        = "daka_days"        // 打卡天数
+	GoalMetricFlagCompletions = "flag_completions" // 完成flag次数
+	GoalMetricPoints          = "points"           // 获得积分
+)
+
+// 是否是有效的目标指标
+func IsValidGoalMetric(metric string) bool {
+	switch metric {
+	case GoalMetricLearnMinutes, GoalMetricDakaDays, GoalMetricFlagCompletions, GoalMetricPoints:
+		return true
+	}
+	return false
+}
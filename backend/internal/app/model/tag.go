@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// 内容类型（提及、话题、通知、举报共用）
+const (
+	ContentTypePost        = "post"
+	ContentTypeFlag        = "flag"
+	ContentTypePostComment = "post_comment"
+	ContentTypeFlagComment = "flag_comment"
+	ContentTypeChatMessage = "chat_message"
+)
+
+// 话题
+type Tag struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"size:64;uniqueIndex;not null" json:"name"` // 小写
+	UseCount   int       `gorm:"not null;default:0" json:"use_count"`      // 累计使用次数
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `gorm:"index" json:"last_used_at"`
+}
+
+// 话题出现在哪条内容里
+type TagUsage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TagID     uint      `gorm:"uniqueIndex:idx_tag_usage_owner;not null" json:"tag_id"`
+	OwnerType string    `gorm:"size:32;uniqueIndex:idx_tag_usage_owner;index:idx_tag_usage_lookup" json:"owner_type"`
+	OwnerID   uint      `gorm:"uniqueIndex:idx_tag_usage_owner;index:idx_tag_usage_lookup" json:"owner_id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// 某条内容 @ 了某个用户
+type Mention struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"uniqueIndex:idx_mention_owner;not null" json:"user_id"` // 被提及的用户
+	FromUserID uint      `gorm:"index;not null" json:"from_user_id"`
+	OwnerType  string    `gorm:"size:32;uniqueIndex:idx_mention_owner" json:"owner_type"`
+	OwnerID    uint      `gorm:"uniqueIndex:idx_mention_owner" json:"owner_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -7,22 +7,32 @@ import (
 	"gorm.io/gorm"
 )
 
+// 用户角色
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin"
+)
+
 type User struct {
-	ID             uint          `gorm:"primaryKey" json:"user_id"`       //用户ID
-	Name           string        `json:"name"`                            //用户名
-	Email          string        `json:"email"`                           //邮箱
-	Password       string        `json:"password"`                        //密码
-	Status         string        `json:"status"`                          //用户状态
-	IsRemind       bool          `json:"is_remind" gorm:"default:true"`   //是否开启提醒
-	DoFlag         time.Time     `json:"do_flag"`                         //最后打卡时间
-	HeadShow       int           `json:"head_show" gorm:"default:1"`      //头像显示
-	RemindHour     int           `json:"time_remind" default:"12"`        //提醒小时
-	RemindMin      int           `json:"min_remind" default:"0"`          //提醒分钟
-	Daka           int           `json:"daka"`                            //总打卡数
-	MonthLearntime int           `json:"month_learn_time"`                //本月学习时长
-	FlagNumber     int           `json:"flag_number"`                     //完成flag数量
-	Count          int           `json:"count"`                           //积分
-	Labels         Label         `json:"labels" gorm:"foreignKey:UserID"` //完成flag的标签数
+	ID             uint          `gorm:"primaryKey" json:"user_id"`          //用户ID
+	Name           string        `json:"name"`                               //用户名
+	Email          string        `json:"email"`                              //邮箱
+	Password       string        `json:"password"`                           //密码
+	Status         string        `json:"status"`                             //用户状态
+	IsRemind       bool          `json:"is_remind" gorm:"default:true"`      //是否开启提醒
+	DoFlag         time.Time     `json:"do_flag"`                            //最后打卡时间
+	HeadShow       int           `json:"head_show" gorm:"default:1"`         //头像显示
+	AvatarKey      string        `json:"-" gorm:"size:64"`                   //自定义头像文件名前缀，为空时使用内置头像
+	RemindHour     int           `json:"time_remind" default:"12"`           //提醒小时
+	RemindMin      int           `json:"min_remind" default:"0"`             //提醒分钟
+	Daka           int           `json:"daka"`                               //总打卡数
+	MonthLearntime int           `json:"month_learn_time"`                   //本月学习时长
+	FlagNumber     int           `json:"flag_number"`                        //完成flag数量
+	Count          int           `json:"count"`                              //积分
+	Role           string        `json:"role" gorm:"size:16;default:'user'"` //角色：user / admin
+	Warnings       int           `json:"warnings" gorm:"not null;default:0"` //被管理员警告次数
+	SuspendedUntil *time.Time    `json:"suspended_until,omitempty"`          //封禁截止时间，期间不能登录和发布内容
+	Labels         Label         `json:"labels" gorm:"foreignKey:UserID"`    //完成flag的标签数
 	DaKaNumber     []Daka_number `gorm:"foreignKey:UserID"`
 	LearnTimes     []LearnTime   `gorm:"foreignKey:UserID"` //外键绑定learn_time表
 	Flags          []Flag        `gorm:"foreignKey:UserID"` //外键绑定flag表
@@ -30,6 +40,14 @@ type User struct {
 	Achievements   []Achievement `gorm:"foreignKey:UserID"` //一对多绑定achievement表
 }
 
+// 用户头像地址：优先使用上传的自定义头像，否则回退到内置头像
+func (u *User) AvatarURL() string {
+	if u.AvatarKey != "" {
+		return utils.GetCustomAvatarPath(u.AvatarKey, utils.AvatarSizeLarge)
+	}
+	return utils.GetAvatarPath(u.HeadShow)
+}
+
 // Flag - 前端字段为主
 type Flag struct {
 	ID         uint          `gorm:"primaryKey" json:"id"`
@@ -49,6 +67,18 @@ type Flag struct {
 	CreatedAt  time.Time     `json:"created_at"`                                               // 前端: createdAt
 	StartTime  time.Time     `gorm:"column:start_time" json:"start_time"`                      // 前端: startTime
 	EndTime    time.Time     `gorm:"column:end_time" json:"end_time"`                          // 前端: endTime
+	AIPlanID   *uint         `gorm:"column:ai_plan_id;index" json:"ai_plan_id,omitempty"`      // 由AI学习计划创建时关联的计划ID
+	HotScore   float64       `gorm:"index;not null;default:0" json:"hot_score"`                // 热度分（点赞、评论随时间衰减）
+	Hidden     bool          `gorm:"index;not null;default:false" json:"hidden,omitempty"`     // 被管理员隐藏
+}
+
+// flag标签编号对应的名称
+var FlagLabelNames = map[int]string{
+	1: "生活",
+	2: "学习",
+	3: "工作",
+	4: "兴趣",
+	5: "运动",
 }
 
 // AfterFind - GORM钩子：查询后转换label
@@ -78,14 +108,7 @@ func (f *Flag) AfterFind(tx *gorm.DB) error {
 // BeforeSave - GORM钩子：保存前转换label
 func (f *Flag) BeforeSave(tx *gorm.DB) error {
 	// 将数字label转换为字符串存储到数据库
-	labelMap := map[int]string{
-		1: "生活",
-		2: "学习",
-		3: "工作",
-		4: "兴趣",
-		5: "运动",
-	}
-	if val, ok := labelMap[f.Label]; ok {
+	if val, ok := FlagLabelNames[f.Label]; ok {
 		f.LabelStr = val
 	} else {
 		f.LabelStr = "学习" // 默认学习
@@ -96,47 +119,55 @@ func (f *Flag) BeforeSave(tx *gorm.DB) error {
 
 // 帖子
 type Post struct {
-	ID         uint          `gorm:"primaryKey" json:"id"`
-	Title      string        `json:"title"`
-	Content    string        `json:"content"`
-	Like       int           `json:"like"`
-	UserID     uint          `gorm:"foreignKey:UserID" json:"user_id"`
-	FlagID     *uint         `gorm:"index" json:"flag_id,omitempty"`          // 关联的Flag ID（可选）
-	User       *User         `gorm:"foreignKey:UserID" json:"user,omitempty"` // 关联用户信息
-	UserName   string        `gorm:"-" json:"userName"`                       // 前端需要的用户名（计算字段）
-	UserAvatar string        `gorm:"-" json:"userAvatar"`                     // 前端需要的用户头像（计算字段）
-	CreatedAt  time.Time     `json:"created_at"`
-	UpdatedAt  time.Time     `json:"updated_at"`
-	Comments   []PostComment `gorm:"foreignKey:PostID" json:"comments"` //外键绑定post_comment表
+	ID          uint          `gorm:"primaryKey" json:"id"`
+	Title       string        `json:"title"`
+	Content     string        `json:"content"`
+	Like        int           `json:"like"`
+	UserID      uint          `gorm:"foreignKey:UserID" json:"user_id"`
+	FlagID      *uint         `gorm:"index" json:"flag_id,omitempty"`          // 关联的Flag ID（可选）
+	User        *User         `gorm:"foreignKey:UserID" json:"user,omitempty"` // 关联用户信息
+	UserName    string        `gorm:"-" json:"userName"`                       // 前端需要的用户名（计算字段）
+	UserAvatar  string        `gorm:"-" json:"userAvatar"`                     // 前端需要的用户头像（计算字段）
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	Comments    []PostComment `gorm:"foreignKey:PostID" json:"comments"`                                    //外键绑定post_comment表
+	HotScore    float64       `gorm:"index;not null;default:0" json:"hot_score"`                            // 热度分（点赞、评论随时间衰减）
+	Attachments []Attachment  `gorm:"polymorphic:Owner;polymorphicValue:post" json:"attachments,omitempty"` // 图片附件
+	Edited      bool          `gorm:"not null;default:false" json:"edited"`                                 // 是否编辑过
+	EditedAt    *time.Time    `json:"edited_at,omitempty"`                                                  // 最后编辑时间
+	Hidden      bool          `gorm:"index;not null;default:false" json:"hidden,omitempty"`                 // 被管理员隐藏
 }
 
 // AfterFind - GORM钩子：查询后自动填充用户信息
 func (p *Post) AfterFind(tx *gorm.DB) error {
 	if p.User != nil {
 		p.UserName = p.User.Name
-		p.UserAvatar = utils.GetAvatarPath(p.User.HeadShow)
+		p.UserAvatar = p.User.AvatarURL()
 	}
 	return nil
 }
 
 // 帖子评论
 type PostComment struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	PostID     uint      `json:"post_id"`
-	UserID     uint      `json:"userId" gorm:"column:user_id"` // 评论者ID
-	Content    string    `json:"content"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-	User       *User     `gorm:"foreignKey:UserID" json:"-"` // 关联用户信息
-	UserName   string    `gorm:"-" json:"userName"`          // 前端需要的用户名（计算字段）
-	UserAvatar string    `gorm:"-" json:"userAvatar"`        // 前端需要的用户头像（计算字段）
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	PostID      uint         `json:"post_id"`
+	UserID      uint         `json:"userId" gorm:"column:user_id"`     // 评论者ID
+	ParentID    *uint        `gorm:"index" json:"parent_id,omitempty"` // 回复的根评论ID（为空表示一级评论）
+	Content     string       `json:"content"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Attachments []Attachment `gorm:"polymorphic:Owner;polymorphicValue:post_comment" json:"attachments,omitempty"` // 图片附件
+	Hidden      bool         `gorm:"index;not null;default:false" json:"hidden,omitempty"`                         // 被管理员隐藏
+	User        *User        `gorm:"foreignKey:UserID" json:"-"`                                                   // 关联用户信息
+	UserName    string       `gorm:"-" json:"userName"`                                                            // 前端需要的用户名（计算字段）
+	UserAvatar  string       `gorm:"-" json:"userAvatar"`                                                          // 前端需要的用户头像（计算字段）
 }
 
 // AfterFind - GORM钩子：查询后自动填充用户信息
 func (c *PostComment) AfterFind(tx *gorm.DB) error {
 	if c.User != nil {
 		c.UserName = c.User.Name
-		c.UserAvatar = utils.GetAvatarPath(c.User.HeadShow)
+		c.UserAvatar = c.User.AvatarURL()
 	}
 	return nil
 }
@@ -149,13 +180,46 @@ type UserPostLike struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// 用户点赞flag关系表
+type UserFlagLike struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_user_flag_like" json:"user_id"`
+	FlagID    uint      `gorm:"uniqueIndex:idx_user_flag_like;index" json:"flag_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // flag评论
 type FlagComment struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	FlagID      uint         `gorm:"index" json:"flag_id"`
+	UserID      uint         `json:"userId" gorm:"column:user_id;index"` // 评论者ID
+	ParentID    *uint        `gorm:"index" json:"parent_id,omitempty"`   // 回复的根评论ID（为空表示一级评论）
+	Content     string       `json:"content"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Attachments []Attachment `gorm:"polymorphic:Owner;polymorphicValue:flag_comment" json:"attachments,omitempty"` // 图片附件
+	Hidden      bool         `gorm:"index;not null;default:false" json:"hidden,omitempty"`                         // 被管理员隐藏
+	User        *User        `gorm:"foreignKey:UserID" json:"-"`                                                   // 关联用户信息
+	UserName    string       `gorm:"-" json:"userName"`                                                            // 前端需要的用户名（计算字段）
+	UserAvatar  string       `gorm:"-" json:"userAvatar"`                                                          // 前端需要的用户头像（计算字段）
+}
+
+// AfterFind - GORM钩子：查询后自动填充用户信息
+func (c *FlagComment) AfterFind(tx *gorm.DB) error {
+	if c.User != nil {
+		c.UserName = c.User.Name
+		c.UserAvatar = c.User.AvatarURL()
+	}
+	return nil
+}
+
+// flag完成记录（每次达到每日目标时记一条，用于统计完成历史）
+type FlagCompletion struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	FlagID    uint      `json:"flag_id"`
-	Content   string    `json:"content"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	FlagID    uint      `gorm:"index" json:"flag_id"`
+	Label     int       `json:"label"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
 }
 
 type Achievement struct {
@@ -165,6 +229,8 @@ type Achievement struct {
 	Description string    `json:"description"`
 	HadDone     bool      `json:"had_done"`
 	GotTime     time.Time `json:"got_time"`
+	Progress    int       `gorm:"not null;default:0" json:"progress"`       // 当前进度（按成就定义的规则汇总的数值）
+	Tier        int       `gorm:"not null;default:0" json:"tier"`           // 已达到的等级（单级成就解锁后为1）
 	User        *User     `gorm:"foreignKey:UserID;references:ID" json:"-"` // 补充关联声明
 }
 
@@ -214,22 +280,24 @@ type TrackPoint struct {
 
 // 聊天消息
 type ChatMessage struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	FromUserID uint      `json:"from" gorm:"column:from_user_id"`
-	ToUserID   uint      `json:"to" gorm:"column:to_user_id"` // 0表示群聊
-	RoomID     string    `json:"room_id"`
-	Content    string    `json:"content"`
-	CreatedAt  time.Time `json:"created_at"`
-	User       *User     `gorm:"foreignKey:FromUserID" json:"-"` // 关联发送者信息
-	UserName   string    `gorm:"-" json:"user_name"`
-	UserAvatar string    `gorm:"-" json:"user_avatar"`
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	FromUserID  uint         `json:"from" gorm:"column:from_user_id"`
+	ToUserID    uint         `json:"to" gorm:"column:to_user_id"` // 0表示群聊
+	RoomID      string       `json:"room_id"`
+	Content     string       `json:"content"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Attachments []Attachment `gorm:"polymorphic:Owner;polymorphicValue:chat_message" json:"attachments,omitempty"` // 图片附件
+	Hidden      bool         `gorm:"index;not null;default:false" json:"hidden,omitempty"`                         // 被管理员隐藏
+	User        *User        `gorm:"foreignKey:FromUserID" json:"-"`                                               // 关联发送者信息
+	UserName    string       `gorm:"-" json:"user_name"`
+	UserAvatar  string       `gorm:"-" json:"user_avatar"`
 }
 
 // AfterFind - GORM钩子：查询后自动填充用户信息
 func (m *ChatMessage) AfterFind(tx *gorm.DB) error {
 	if m.User != nil {
 		m.UserName = m.User.Name
-		m.UserAvatar = utils.GetAvatarPath(m.User.HeadShow)
+		m.UserAvatar = m.User.AvatarURL()
 	}
 	return nil
 }
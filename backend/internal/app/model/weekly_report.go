@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// 学习周报（每个用户每周一条，重新生成时覆盖）
+type WeeklyReport struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"uniqueIndex:idx_user_week" json:"user_id"`
+	WeekStart      time.Time  `gorm:"uniqueIndex:idx_user_week" json:"week_start"` // 周一 00:00
+	LearnMinutes   int        `json:"learn_minutes"`                               // 本周学习总时长（分钟）
+	LearnDays      int        `json:"learn_days"`                                  // 有学习记录的天数
+	DakaDays       int        `json:"daka_days"`                                   // 打卡天数
+	FlagsCompleted int        `json:"flags_completed"`                             // 完成flag次数
+	PointsEarned   int        `json:"points_earned"`                               // 获得积分
+	DailyMinutes   string     `gorm:"type:text" json:"-"`                          // 每日学习时长（JSON）
+	Summary        string     `gorm:"type:text" json:"summary"`                    // 总结
+	Suggestions    string     `gorm:"type:text" json:"-"`                          // 建议（JSON数组）
+	Source         string     `gorm:"size:16" json:"source"`                       // llm / template
+	EmailedAt      *time.Time `json:"emailed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+)
+
+// 获取用户所有打卡日的记录（历史记录以 month_daka > 0 判断，今天以 had_done 为准）
+func GetDakaDaysByUserID(userID uint) ([]model.Daka_number, error) {
+	var records []model.Daka_number
+	err := DB.Scopes(dakaDoneScope).Where("user_id = ?", userID).
+		Order("daka_date asc").
+		Find(&records).Error
+	return records, err
+}
+
+// 获取用户所有flag完成记录
+func GetFlagCompletionsByUserID(userID uint) ([]model.FlagCompletion, error) {
+	var completions []model.FlagCompletion
+	err := DB.Where("user_id = ?", userID).Order("created_at asc").Find(&completions).Error
+	return completions, err
+}
+
+// 获取用户发帖时间
+func GetPostTimesByUserID(userID uint) ([]time.Time, error) {
+	var times []time.Time
+	err := DB.Model(&model.Post{}).Where("user_id = ?", userID).Order("created_at asc").Pluck("created_at", &times).Error
+	return times, err
+}
+
+// 提升成就等级（首次解锁时写入解锁时间）；等级已被并发修改时不更新，返回是否更新成功
+func UpgradeAchievementTier(achievementID uint, fromTier, toTier, progress int, firstUnlock bool, at time.Time) (bool, error) {
+	updates := map[string]interface{}{"had_done": true, "tier": toTier, "progress": progress}
+	if firstUnlock {
+		updates["got_time"] = at
+	}
+	result := DB.Model(&model.Achievement{}).
+		Where("id = ? AND tier = ?", achievementID, fromTier).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// 更新成就进度
+func UpdateAchievementProgress(achievementID uint, progress int) error {
+	return DB.Model(&model.Achievement{}).Where("id = ?", achievementID).Update("progress", progress).Error
+}
+
+// 统计每个成就的解锁人数
+func CountAchievementUnlocks() (map[string]int64, error) {
+	var rows []struct {
+		Name  string
+		Total int64
+	}
+	err := DB.Model(&model.Achievement{}).
+		Select("name, COUNT(DISTINCT user_id) AS total").
+		Where("had_done = ?", true).
+		Group("name").
+		Scan(&rows).Error
+	counts := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		counts[r.Name] = r.Total
+	}
+	return counts, err
+}
+
+// 统计用户总数
+func CountUsers() (int64, error) {
+	var total int64
+	err := DB.Model(&model.User{}).Count(&total).Error
+	return total, err
+}
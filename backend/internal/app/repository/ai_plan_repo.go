@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 保存AI学习计划
+func SaveAIPlan(plan *model.AIPlan) error {
+	return DB.Create(plan).Error
+}
+
+// 统计用户某时间之后的模型调用次数：真实生成的计划（不含缓存命中）加其他调用记录
+func countAIUsageSince(tx *gorm.DB, userID uint, since time.Time) (int64, error) {
+	var plans, usages int64
+	if err := tx.Model(&model.AIPlan{}).
+		Where("user_id = ? AND cached = ? AND created_at >= ?", userID, false, since).
+		Count(&plans).Error; err != nil {
+		return 0, err
+	}
+	err := tx.Model(&model.AIUsage{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Count(&usages).Error
+	return plans + usages, err
+}
+
+// 在事务中检查配额并写入记录，quota<=0 表示不限
+// 锁住用户行，保证同一用户的并发请求不会同时通过配额检查；返回 false 表示配额已用完
+func reserveAIQuota(userID uint, since time.Time, quota int, record interface{}) (bool, error) {
+	reserved := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userID).Error; err != nil {
+			return err
+		}
+		if quota > 0 {
+			used, err := countAIUsageSince(tx, userID, since)
+			if err != nil {
+				return err
+			}
+			if used >= int64(quota) {
+				return nil
+			}
+		}
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		reserved = true
+		return nil
+	})
+	return reserved, err
+}
+
+// 预占一次生成配额并写入计划占位记录
+func ReserveAIPlan(plan *model.AIPlan, since time.Time, quota int) (bool, error) {
+	return reserveAIQuota(plan.UserID, since, quota, plan)
+}
+
+// 预占一次生成配额并写入调用记录
+func ReserveAIUsage(usage *model.AIUsage, since time.Time, quota int) (bool, error) {
+	return reserveAIQuota(usage.UserID, since, quota, usage)
+}
+
+// 删除调用记录（模型调用失败时退还配额）
+func DeleteAIUsage(usageID uint) error {
+	return DB.Delete(&model.AIUsage{}, usageID).Error
+}
+
+// 生成完成后写回计划内容
+func UpdateAIPlan(plan *model.AIPlan) error {
+	return DB.Save(plan).Error
+}
+
+// 删除AI计划记录（生成失败时退还占用的配额）
+func DeleteAIPlan(planID uint) error {
+	return DB.Delete(&model.AIPlan{}, planID).Error
+}
+
+// 查找缓存有效期内相同请求的最新计划（只匹配真实生成的记录，有效期从生成时算起）
+func FindCachedAIPlan(requestHash string, since time.Time) (model.AIPlan, error) {
+	var plan model.AIPlan
+	err := DB.Where("request_hash = ? AND cached = ? AND created_at >= ?", requestHash, false, since).
+		Order("created_at desc").
+		First(&plan).Error
+	return plan, err
+}
+
+// 统计用户某时间之后调用模型的次数（不含缓存命中）
+func CountUserAIUsageSince(userID uint, since time.Time) (int64, error) {
+	return countAIUsageSince(DB, userID, since)
+}
+
+// 分页获取用户的AI学习计划历史（最新在前）
+func GetAIPlansByUserID(userID uint, offset, limit int) ([]model.AIPlan, int64, error) {
+	var plans []model.AIPlan
+	var total int64
+	// plan 为空的是正在生成的占位记录
+	query := DB.Model(&model.AIPlan{}).Where("user_id = ? AND plan <> ''", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at desc").Offset(offset).Limit(limit).Find(&plans).Error
+	return plans, total, err
+}
+
+// 根据ID获取AI学习计划
+func GetAIPlanByID(planID uint) (model.AIPlan, error) {
+	var plan model.AIPlan
+	err := DB.First(&plan, planID).Error
+	return plan, err
+}
+
+// 获取由某个AI计划创建的flag
+func GetFlagsByAIPlanID(userID uint, planID uint) ([]model.Flag, error) {
+	var flags []model.Flag
+	err := DB.Where("user_id = ? AND ai_plan_id = ?", userID, planID).Order("id").Find(&flags).Error
+	return flags, err
+}
+
+// 更新调整版计划的应用时间
+func UpdateAIPlanAppliedAt(planID uint, appliedAt time.Time) error {
+	return DB.Model(&model.AIPlan{}).Where("id = ?", planID).Update("applied_at", appliedAt).Error
+}
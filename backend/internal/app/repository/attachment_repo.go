@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+)
+
+// 保存附件记录
+func SaveAttachment(a *model.Attachment) error {
+	return DB.Save(a).Error
+}
+
+// 根据ID获取附件
+func GetAttachmentByID(id uint) (model.Attachment, error) {
+	var a model.Attachment
+	err := DB.First(&a, id).Error
+	return a, err
+}
+
+// 根据原图或缩略图的文件名获取附件
+func GetAttachmentByKey(key string) (model.Attachment, error) {
+	var a model.Attachment
+	err := DB.Where("`key` = ? OR thumb_key = ?", key, key).First(&a).Error
+	return a, err
+}
+
+// 删除附件记录
+func DeleteAttachment(id uint) error {
+	return DB.Delete(&model.Attachment{}, id).Error
+}
+
+// 用户尚未被引用的附件中有几个在给定ID内
+func CountUnboundAttachments(userID uint, ids []uint) (int64, error) {
+	var count int64
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	err := DB.Model(&model.Attachment{}).
+		Where("id IN ? AND user_id = ? AND owner_type = ?", ids, userID, "").
+		Count(&count).Error
+	return count, err
+}
+
+// 把用户尚未被引用的附件关联到帖子、评论或聊天消息
+func BindAttachments(userID uint, ids []uint, ownerType string, ownerID uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return DB.Model(&model.Attachment{}).
+		Where("id IN ? AND user_id = ? AND owner_type = ?", ids, userID, "").
+		Updates(map[string]interface{}{"owner_type": ownerType, "owner_id": ownerID}).Error
+}
+
+// 内容删除后解除附件关联，由定时任务清理
+func ReleaseAttachments(ownerType string, ownerIDs []uint) error {
+	if len(ownerIDs) == 0 {
+		return nil
+	}
+	return DB.Model(&model.Attachment{}).
+		Where("owner_type = ? AND owner_id IN ?", ownerType, ownerIDs).
+		Updates(map[string]interface{}{"owner_type": "", "owner_id": 0}).Error
+}
+
+// 批量获取一组内容的附件
+func GetAttachmentsByOwners(ownerType string, ownerIDs []uint) (map[uint][]model.Attachment, error) {
+	result := make(map[uint][]model.Attachment)
+	if len(ownerIDs) == 0 {
+		return result, nil
+	}
+	var attachments []model.Attachment
+	err := DB.Where("owner_type = ? AND owner_id IN ?", ownerType, ownerIDs).Order("id asc").Find(&attachments).Error
+	for _, a := range attachments {
+		result[a.OwnerID] = append(result[a.OwnerID], a)
+	}
+	return result, err
+}
+
+// before 之前上传且未被引用的附件
+func GetStaleAttachments(before time.Time, limit int) ([]model.Attachment, error) {
+	var attachments []model.Attachment
+	err := DB.Where("owner_type = ? AND created_at < ?", "", before).Order("id asc").Limit(limit).Find(&attachments).Error
+	return attachments, err
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"gorm.io/gorm"
+)
+
+// 保存挑战赛
+func SaveChallenge(challenge *model.Challenge) error {
+	return DB.Save(challenge).Error
+}
+
+// 根据ID获取挑战赛
+func GetChallengeByID(id uint) (model.Challenge, error) {
+	var challenge model.Challenge
+	err := DB.First(&challenge, id).Error
+	return challenge, err
+}
+
+// 删除挑战赛及参与记录（已发放的徽章保留）
+func DeleteChallenge(id uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("challenge_id = ?", id).Delete(&model.ChallengeParticipant{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Challenge{}, id).Error
+	})
+}
+
+// 按状态分页获取挑战赛：upcoming 未开始，active 进行中，ended 已结束，空为未结束的全部
+func GetChallenges(status string, now time.Time, offset, limit int) ([]model.Challenge, int64, error) {
+	var challenges []model.Challenge
+	var total int64
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	query := DB.Model(&model.Challenge{})
+	order := "start_date asc, id asc"
+	switch status {
+	case "upcoming":
+		query = query.Where("start_date > ?", today)
+	case "active":
+		query = query.Where("start_date <= ? AND end_date >= ?", today, today)
+	case "ended":
+		query = query.Where("end_date < ?", today)
+		order = "end_date desc, id desc"
+	default:
+		query = query.Where("end_date >= ?", today)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order(order).Offset(offset).Limit(limit).Find(&challenges).Error
+	return challenges, total, err
+}
+
+// 需要评估的挑战赛：已开始且尚未结算
+func GetChallengesToEvaluate(now time.Time) ([]model.Challenge, error) {
+	var challenges []model.Challenge
+	err := DB.Where("start_date <= ? AND settled_at IS NULL", now).Find(&challenges).Error
+	return challenges, err
+}
+
+// 标记挑战赛已结算
+func MarkChallengeSettled(id uint, at time.Time) error {
+	return DB.Model(&model.Challenge{}).Where("id = ?", id).Update("settled_at", at).Error
+}
+
+// 获取参与记录
+func GetChallengeParticipant(challengeID, userID uint) (model.ChallengeParticipant, error) {
+	var p model.ChallengeParticipant
+	err := DB.Where("challenge_id = ? AND user_id = ?", challengeID, userID).First(&p).Error
+	return p, err
+}
+
+// 保存参与记录
+func SaveChallengeParticipant(p *model.ChallengeParticipant) error {
+	return DB.Save(p).Error
+}
+
+// 退出挑战赛
+func DeleteChallengeParticipant(challengeID, userID uint) error {
+	return DB.Where("challenge_id = ? AND user_id = ?", challengeID, userID).Delete(&model.ChallengeParticipant{}).Error
+}
+
+// 挑战赛的所有参与者
+func GetAllChallengeParticipants(challengeID uint) ([]model.ChallengeParticipant, error) {
+	var participants []model.ChallengeParticipant
+	err := DB.Where("challenge_id = ?", challengeID).Find(&participants).Error
+	return participants, err
+}
+
+// 分页获取参与者（按进度降序）
+func GetChallengeParticipants(challengeID uint, offset, limit int) ([]model.ChallengeParticipant, int64, error) {
+	var participants []model.ChallengeParticipant
+	var total int64
+	query := DB.Model(&model.ChallengeParticipant{}).Where("challenge_id = ?", challengeID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("progress desc, completed_at asc, id asc").Offset(offset).Limit(limit).Find(&participants).Error
+	return participants, total, err
+}
+
+// 批量统计各挑战赛的参与人数
+func CountParticipantsByChallenges(challengeIDs []uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64)
+	if len(challengeIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		ChallengeID uint
+		Count       int64
+	}
+	err := DB.Model(&model.ChallengeParticipant{}).
+		Select("challenge_id, COUNT(*) AS count").
+		Where("challenge_id IN ?", challengeIDs).
+		Group("challenge_id").
+		Scan(&rows).Error
+	for _, r := range rows {
+		counts[r.ChallengeID] = r.Count
+	}
+	return counts, err
+}
+
+// 用户参加了其中哪些挑战赛
+func GetUserParticipations(userID uint, challengeIDs []uint) (map[uint]model.ChallengeParticipant, error) {
+	joined := make(map[uint]model.ChallengeParticipant)
+	if len(challengeIDs) == 0 {
+		return joined, nil
+	}
+	var participants []model.ChallengeParticipant
+	err := DB.Where("user_id = ? AND challenge_id IN ?", userID, challengeIDs).Find(&participants).Error
+	for _, p := range participants {
+		joined[p.ChallengeID] = p
+	}
+	return joined, err
+}
+
+// 更新参与者进度
+func UpdateChallengeProgress(id uint, progress int) error {
+	return DB.Model(&model.ChallengeParticipant{}).Where("id = ?", id).Update("progress", progress).Error
+}
+
+// 标记完成并发放徽章，返回是否是本次完成的（避免重复发放）
+func CompleteChallenge(p model.ChallengeParticipant, badge *model.ChallengeBadge, at time.Time) (bool, error) {
+	completed := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.ChallengeParticipant{}).
+			Where("id = ? AND completed_at IS NULL", p.ID).
+			Updates(map[string]interface{}{"completed_at": at, "progress": p.Progress})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		completed = true
+		return tx.Create(badge).Error
+	})
+	return completed, err
+}
+
+// 用户获得的挑战赛徽章
+func GetChallengeBadgesByUserID(userID uint) ([]model.ChallengeBadge, error) {
+	var badges []model.ChallengeBadge
+	err := DB.Where("user_id = ?", userID).Order("created_at desc").Find(&badges).Error
+	return badges, err
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+)
+
+// 帖子作者ID
+func GetPostAuthorID(postID uint) (uint, error) {
+	var post model.Post
+	err := DB.Select("id, user_id").First(&post, postID).Error
+	return post.UserID, err
+}
+
+// 帖子可见性判断所需的最少字段（ID、作者、标题、是否隐藏）
+func GetPostVisibility(postID uint) (model.Post, error) {
+	var post model.Post
+	err := DB.Select("id, user_id, title, hidden").First(&post, postID).Error
+	return post, err
+}
+
+// 分页获取帖子的一级评论（最早在前）
+func GetPostComments(postID uint, offset, limit int) ([]model.PostComment, int64, error) {
+	var comments []model.PostComment
+	var total int64
+	query := DB.Model(&model.PostComment{}).Where("post_id = ? AND parent_id IS NULL AND hidden = ?", postID, false)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Preload("User").Preload("Attachments").Order("created_at asc, id asc").Offset(offset).Limit(limit).Find(&comments).Error
+	return comments, total, err
+}
+
+// 分页获取帖子评论的回复（最早在前）
+func GetPostCommentReplies(rootID uint, offset, limit int) ([]model.PostComment, int64, error) {
+	var replies []model.PostComment
+	var total int64
+	query := DB.Model(&model.PostComment{}).Where("parent_id = ? AND hidden = ?", rootID, false)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Preload("User").Preload("Attachments").Order("created_at asc, id asc").Offset(offset).Limit(limit).Find(&replies).Error
+	return replies, total, err
+}
+
+// 批量统计帖子评论的回复数
+func CountPostCommentReplies(rootIDs []uint) (map[uint]int64, error) {
+	return countGrouped(&model.PostComment{}, "parent_id", rootIDs)
+}
+
+// 分页获取flag的一级评论（最早在前）
+func GetFlagComments(flagID uint, offset, limit int) ([]model.FlagComment, int64, error) {
+	var comments []model.FlagComment
+	var total int64
+	query := DB.Model(&model.FlagComment{}).Where("flag_id = ? AND parent_id IS NULL AND hidden = ?", flagID, false)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Preload("User").Preload("Attachments").Order("created_at asc, id asc").Offset(offset).Limit(limit).Find(&comments).Error
+	return comments, total, err
+}
+
+// 分页获取flag评论的回复（最早在前）
+func GetFlagCommentReplies(rootID uint, offset, limit int) ([]model.FlagComment, int64, error) {
+	var replies []model.FlagComment
+	var total int64
+	query := DB.Model(&model.FlagComment{}).Where("parent_id = ? AND hidden = ?", rootID, false)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Preload("User").Preload("Attachments").Order("created_at asc, id asc").Offset(offset).Limit(limit).Find(&replies).Error
+	return replies, total, err
+}
+
+// 批量统计flag评论的回复数
+func CountFlagCommentReplies(rootIDs []uint) (map[uint]int64, error) {
+	return countGrouped(&model.FlagComment{}, "parent_id", rootIDs)
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"gorm.io/gorm"
+)
+
+// 信息流条目类型，排序值相同时帖子排在flag前面
+const (
+	FeedKindPost = "post"
+	FeedKindFlag = "flag"
+)
+
+// 信息流排序方式
+const (
+	FeedSortLatest = "latest"
+	FeedSortHot    = "hot"
+)
+
+// 信息流游标：上一页最后一条的排序值、类型和ID
+type FeedCursor struct {
+	Value interface{}
+	Kind  string
+	ID    uint
+}
+
+// 信息流查询条件
+type FeedQuery struct {
+	Sort       string
+	UserIDs    []uint    // 非空时只查这些用户发布的内容
+	ExcludeIDs []uint    // 屏蔽关系中的用户
+	Since      time.Time // 非零时只查此后发布的内容
+	Cursor     *FeedCursor
+	Limit      int
+}
+
+// 排序列
+func feedSortColumn(sort string) string {
+	if sort == FeedSortHot {
+		return "hot_score"
+	}
+	return "created_at"
+}
+
+// 按游标和过滤条件构建某一类条目的查询
+func feedScope(query *gorm.DB, q FeedQuery, kind string) *gorm.DB {
+	col := feedSortColumn(q.Sort)
+	query = query.Where("hidden = ?", false)
+	if len(q.UserIDs) > 0 {
+		query = query.Where("user_id IN ?", q.UserIDs)
+	}
+	if len(q.ExcludeIDs) > 0 {
+		query = query.Where("user_id NOT IN ?", q.ExcludeIDs)
+	}
+	if !q.Since.IsZero() {
+		query = query.Where("created_at >= ?", q.Since)
+	}
+	if c := q.Cursor; c != nil {
+		switch {
+		case c.Kind == kind:
+			query = query.Where("("+col+" < ? OR ("+col+" = ? AND id < ?))", c.Value, c.Value, c.ID)
+		case kind == FeedKindPost:
+			// 游标是flag：排序值相同的帖子已经在前面返回过
+			query = query.Where(col+" < ?", c.Value)
+		default:
+			// 游标是帖子：排序值相同的flag还没有返回
+			query = query.Where(col+" <= ?", c.Value)
+		}
+	}
+	return query.Order(col + " desc").Order("id desc").Limit(q.Limit)
+}
+
+// 信息流中的帖子
+func GetFeedPosts(q FeedQuery) ([]model.Post, error) {
+	var posts []model.Post
+	err := feedScope(DB.Model(&model.Post{}), q, FeedKindPost).Find(&posts).Error
+	return posts, err
+}
+
+// 信息流中的公开flag
+func GetFeedFlags(q FeedQuery) ([]model.Flag, error) {
+	var flags []model.Flag
+	err := feedScope(DB.Model(&model.Flag{}).Where("is_public = ?", true), q, FeedKindFlag).Find(&flags).Error
+	return flags, err
+}
+
+// 批量统计帖子评论数
+func CountPostComments(postIDs []uint) (map[uint]int64, error) {
+	return countGrouped(&model.PostComment{}, "post_id", postIDs)
+}
+
+// 批量统计flag评论数
+func CountFlagComments(flagIDs []uint) (map[uint]int64, error) {
+	return countGrouped(&model.FlagComment{}, "flag_id", flagIDs)
+}
+
+func countGrouped(table interface{}, column string, ids []uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64)
+	if len(ids) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		ID    uint
+		Count int64
+	}
+	err := DB.Model(table).
+		Select(column+" AS id, COUNT(*) AS count").
+		Where(column+" IN ?", ids).
+		Group(column).
+		Scan(&rows).Error
+	for _, r := range rows {
+		counts[r.ID] = r.Count
+	}
+	return counts, err
+}
+
+// 用户点过赞的帖子（限定在给定帖子内）
+func FilterLikedPosts(userID uint, postIDs []uint) (map[uint]bool, error) {
+	return filterLiked(&model.UserPostLike{}, "post_id", userID, postIDs)
+}
+
+// 用户点过赞的flag（限定在给定flag内）
+func FilterLikedFlags(userID uint, flagIDs []uint) (map[uint]bool, error) {
+	return filterLiked(&model.UserFlagLike{}, "flag_id", userID, flagIDs)
+}
+
+func filterLiked(table interface{}, column string, userID uint, targetIDs []uint) (map[uint]bool, error) {
+	liked := make(map[uint]bool)
+	if len(targetIDs) == 0 {
+		return liked, nil
+	}
+	var ids []uint
+	err := DB.Model(table).
+		Where("user_id = ? AND "+column+" IN ?", userID, targetIDs).
+		Pluck(column, &ids).Error
+	for _, id := range ids {
+		liked[id] = true
+	}
+	return liked, err
+}
+
+// 计算热度所需的数据
+type HotStats struct {
+	ID        uint
+	CreatedAt time.Time
+	Likes     int64
+	Comments  int64
+}
+
+// 帖子的热度数据：ids 非空时只查这些帖子，否则查 since 之后发布的帖子
+func GetPostHotStats(ids []uint, since time.Time) ([]HotStats, error) {
+	var stats []HotStats
+	query := DB.Model(&model.Post{}).Select("id, created_at")
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	} else {
+		query = query.Where("created_at >= ?", since)
+	}
+	if err := query.Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+	postIDs := make([]uint, 0, len(stats))
+	for _, s := range stats {
+		postIDs = append(postIDs, s.ID)
+	}
+	likes, err := countGrouped(&model.UserPostLike{}, "post_id", postIDs)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := CountPostComments(postIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		stats[i].Likes = likes[stats[i].ID]
+		stats[i].Comments = comments[stats[i].ID]
+	}
+	return stats, nil
+}
+
+// 公开flag的热度数据：ids 非空时只查这些flag，否则查 since 之后发布的flag
+func GetFlagHotStats(ids []uint, since time.Time) ([]HotStats, error) {
+	var stats []HotStats
+	query := DB.Model(&model.Flag{}).Select("id, created_at").Where("is_public = ?", true)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	} else {
+		query = query.Where("created_at >= ?", since)
+	}
+	if err := query.Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+	flagIDs := make([]uint, 0, len(stats))
+	for _, s := range stats {
+		flagIDs = append(flagIDs, s.ID)
+	}
+	likes, err := countGrouped(&model.UserFlagLike{}, "flag_id", flagIDs)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := CountFlagComments(flagIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		stats[i].Likes = likes[stats[i].ID]
+		stats[i].Comments = comments[stats[i].ID]
+	}
+	return stats, nil
+}
+
+// 更新帖子热度
+func UpdatePostHotScore(id uint, score float64) error {
+	return DB.Model(&model.Post{}).Where("id = ?", id).UpdateColumn("hot_score", score).Error
+}
+
+// 更新flag热度
+func UpdateFlagHotScore(id uint, score float64) error {
+	return DB.Model(&model.Flag{}).Where("id = ?", id).UpdateColumn("hot_score", score).Error
+}
+
+// 发布时间早于 before 的内容热度清零
+func ResetStaleHotScores(before time.Time) error {
+	for _, m := range []interface{}{&model.Post{}, &model.Flag{}} {
+		if err := DB.Model(m).Where("created_at < ? AND hot_score > 0", before).UpdateColumn("hot_score", 0).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+)
+
+// 保存内容审核记录
+func SaveModerationRecord(record *model.ModerationRecord) error {
+	return DB.Create(record).Error
+}
+
+// 内容保存后回填审核记录对应的内容
+func SetModerationRecordTarget(ids []uint, targetType string, targetID uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return DB.Model(&model.ModerationRecord{}).Where("id IN ?", ids).
+		Updates(map[string]interface{}{"target_type": targetType, "target_id": targetID}).Error
+}
+
+// 分页获取审核记录（待复核的最早在前，其余最新在前）
+func GetModerationRecords(status, scene string, offset, limit int) ([]model.ModerationRecord, int64, error) {
+	var records []model.ModerationRecord
+	var total int64
+	query := DB.Model(&model.ModerationRecord{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if scene != "" {
+		query = query.Where("scene = ?", scene)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	order := "created_at desc, id desc"
+	if status == model.ModerationStatusPending {
+		order = "created_at asc, id asc"
+	}
+	err := query.Order(order).Offset(offset).Limit(limit).Find(&records).Error
+	return records, total, err
+}
+
+// 根据ID获取审核记录
+func GetModerationRecordByID(id uint) (model.ModerationRecord, error) {
+	var record model.ModerationRecord
+	err := DB.First(&record, id).Error
+	return record, err
+}
+
+// 处理待复核的审核记录，返回是否实际更新（已处理时返回 false）
+func ResolveModerationRecord(id uint, status, note string, handlerID uint, at time.Time) (bool, error) {
+	result := DB.Model(&model.ModerationRecord{}).
+		Where("id = ? AND status = ?", id, model.ModerationStatusPending).
+		Updates(map[string]interface{}{
+			"status":     status,
+			"note":       note,
+			"handler_id": handlerID,
+			"handled_at": at,
+		})
+	return result.RowsAffected > 0, result.Error
+}
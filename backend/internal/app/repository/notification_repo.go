@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+)
+
+// 保存通知
+func SaveNotification(n *model.Notification) error {
+	return DB.Save(n).Error
+}
+
+// 查找同一触发者对同一目标的同类通知（用于去重和合并）
+func FindNotification(userID uint, typ string, actorID uint, targetType string, targetID uint, unreadOnly bool) (model.Notification, error) {
+	var n model.Notification
+	query := DB.Where("user_id = ? AND type = ? AND actor_id = ? AND target_type = ? AND target_id = ?",
+		userID, typ, actorID, targetType, targetID)
+	if unreadOnly {
+		query = query.Where("is_read = ?", false)
+	}
+	err := query.Order("id desc").First(&n).Error
+	return n, err
+}
+
+// 分页获取用户的通知（最新在前）
+func GetNotifications(userID uint, unreadOnly bool, offset, limit int) ([]model.Notification, int64, error) {
+	var notifications []model.Notification
+	var total int64
+	query := DB.Model(&model.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("is_read = ?", false)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at desc, id desc").Offset(offset).Limit(limit).Find(&notifications).Error
+	return notifications, total, err
+}
+
+// 未读通知数
+func CountUnreadNotifications(userID uint) (int64, error) {
+	var count int64
+	err := DB.Model(&model.Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Count(&count).Error
+	return count, err
+}
+
+// 根据ID获取用户的通知
+func GetNotificationByID(userID, id uint) (model.Notification, error) {
+	var n model.Notification
+	err := DB.Where("id = ? AND user_id = ?", id, userID).First(&n).Error
+	return n, err
+}
+
+// 标记一条通知为已读
+func MarkNotificationRead(userID, id uint, at time.Time) error {
+	return DB.Model(&model.Notification{}).
+		Where("id = ? AND user_id = ? AND is_read = ?", id, userID, false).
+		Updates(map[string]interface{}{"is_read": true, "read_at": at}).Error
+}
+
+// 全部标为已读，返回标记的条数
+func MarkAllNotificationsRead(userID uint, at time.Time) (int64, error) {
+	result := DB.Model(&model.Notification{}).
+		Where("user_id = ? AND is_read = ?", userID, false).
+		Updates(map[string]interface{}{"is_read": true, "read_at": at})
+	return result.RowsAffected, result.Error
+}
+
+// 删除某个时间之前的已读通知
+func DeleteReadNotificationsBefore(before time.Time) (int64, error) {
+	result := DB.Where("is_read = ? AND created_at < ?", true, before).Delete(&model.Notification{})
+	return result.RowsAffected, result.Error
+}
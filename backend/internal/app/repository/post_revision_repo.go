@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"gorm.io/gorm"
+)
+
+// 编辑帖子：先保存旧版本，再更新标题和内容并标记为已编辑
+func EditPost(post *model.Post, title, content string, at time.Time) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		revision := model.PostRevision{PostID: post.ID, Title: post.Title, Content: post.Content}
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Post{}).Where("id = ?", post.ID).Updates(map[string]interface{}{
+			"title":     title,
+			"content":   content,
+			"edited":    true,
+			"edited_at": at,
+		}).Error; err != nil {
+			return err
+		}
+		post.Title, post.Content, post.Edited, post.EditedAt = title, content, true, &at
+		return nil
+	})
+}
+
+// 分页获取帖子的历史版本（最新在前）
+func GetPostRevisions(postID uint, offset, limit int) ([]model.PostRevision, int64, error) {
+	var revisions []model.PostRevision
+	var total int64
+	query := DB.Model(&model.PostRevision{}).Where("post_id = ?", postID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at desc, id desc").Offset(offset).Limit(limit).Find(&revisions).Error
+	return revisions, total, err
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+)
+
+// 排行榜中单个用户的分数
+type RankingScore struct {
+	UserID uint
+	Score  int
+}
+
+// 用户总积分
+func GetPointsScores() ([]RankingScore, error) {
+	return userColumnScores("count")
+}
+
+// 用户总打卡天数
+func GetDakaScores() ([]RankingScore, error) {
+	return userColumnScores("daka")
+}
+
+// 用户累计完成flag数
+func GetFlagScores() ([]RankingScore, error) {
+	return userColumnScores("flag_number")
+}
+
+func userColumnScores(column string) ([]RankingScore, error) {
+	var scores []RankingScore
+	err := DB.Model(&model.User{}).
+		Select("id AS user_id, " + column + " AS score").
+		Where(column + " > 0").
+		Scan(&scores).Error
+	return scores, err
+}
+
+// 某时间之后获得的积分
+func GetPointsScoresSince(since time.Time) ([]RankingScore, error) {
+	var scores []RankingScore
+	err := DB.Model(&model.PointsLog{}).
+		Select("user_id, SUM(amount) AS score").
+		Where("created_at >= ?", since).
+		Group("user_id").
+		Having("SUM(amount) > 0").
+		Scan(&scores).Error
+	return scores, err
+}
+
+// 某时间之后的学习时长（分钟），since 为零值时统计全部
+func GetLearnTimeScoresSince(since time.Time) ([]RankingScore, error) {
+	var scores []RankingScore
+	err := DB.Model(&model.LearnTime{}).
+		Select("user_id, SUM(duration) AS score").
+		Where("created_at >= ? AND duration > 0", since).
+		Group("user_id").
+		Scan(&scores).Error
+	return scores, err
+}
+
+// 某时间之后的打卡天数（历史记录以 month_daka > 0 判断，今天以 had_done 为准）
+func GetDakaScoresSince(since time.Time) ([]RankingScore, error) {
+	var scores []RankingScore
+	err := DB.Model(&model.Daka_number{}).Scopes(dakaDoneScope).
+		Select("user_id, COUNT(DISTINCT DATE(daka_date)) AS score").
+		Where("daka_date >= ?", since).
+		Group("user_id").
+		Scan(&scores).Error
+	return scores, err
+}
+
+// 某时间之后完成flag的次数
+func GetFlagScoresSince(since time.Time) ([]RankingScore, error) {
+	var scores []RankingScore
+	err := DB.Model(&model.FlagCompletion{}).
+		Select("user_id, COUNT(*) AS score").
+		Where("created_at >= ?", since).
+		Group("user_id").
+		Scan(&scores).Error
+	return scores, err
+}
+
+// 批量获取用户的公开信息（只查询名字和头像）
+func GetUsersPublicInfo(userIDs []uint) ([]model.User, error) {
+	var users []model.User
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+	err := DB.Select("id", "name", "head_show", "avatar_key").Where("id IN ?", userIDs).Find(&users).Error
+	return users, err
+}
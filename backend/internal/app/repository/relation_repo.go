@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 是否存在某种关系
+func HasRelation(userID, targetID uint, relType string) (bool, error) {
+	var count int64
+	err := DB.Model(&model.UserRelation{}).
+		Where("user_id = ? AND target_id = ? AND type = ?", userID, targetID, relType).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// 双方任意一方拉黑了对方
+func IsBlockedBetween(a, b uint) (bool, error) {
+	var count int64
+	err := DB.Model(&model.UserRelation{}).
+		Where("type = ? AND ((user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?))", model.RelationBlock, a, b, b, a).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// 添加关系（已存在时忽略）
+func AddRelation(userID, targetID uint, relType string) error {
+	return addRelation(DB, userID, targetID, relType)
+}
+
+func addRelation(tx *gorm.DB, userID, targetID uint, relType string) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.UserRelation{UserID: userID, TargetID: targetID, Type: relType}).Error
+}
+
+// 删除关系
+func DeleteRelation(userID, targetID uint, relType string) error {
+	return DB.Where("user_id = ? AND target_id = ? AND type = ?", userID, targetID, relType).
+		Delete(&model.UserRelation{}).Error
+}
+
+// 拉黑：同时解除双方的关注并拒绝双方之间待处理的好友申请
+func BlockUser(userID, targetID uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("type = ? AND ((user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?))",
+			model.RelationFollow, userID, targetID, targetID, userID).
+			Delete(&model.UserRelation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.FriendRequest{}).
+			Where("status = ? AND ((from_id = ? AND to_id = ?) OR (from_id = ? AND to_id = ?))",
+				model.FriendRequestPending, userID, targetID, targetID, userID).
+			Update("status", model.FriendRequestRejected).Error; err != nil {
+			return err
+		}
+		return addRelation(tx, userID, targetID, model.RelationBlock)
+	})
+}
+
+// 关注我的人（按关注时间倒序）
+func GetFollowers(userID uint, offset, limit int) ([]model.UserRelation, int64, error) {
+	return pageRelations(DB.Model(&model.UserRelation{}).Where("target_id = ? AND type = ?", userID, model.RelationFollow), offset, limit)
+}
+
+// 我关注的人（按关注时间倒序）
+func GetFollowing(userID uint, offset, limit int) ([]model.UserRelation, int64, error) {
+	return pageRelations(DB.Model(&model.UserRelation{}).Where("user_id = ? AND type = ?", userID, model.RelationFollow), offset, limit)
+}
+
+// 我拉黑的人
+func GetBlocked(userID uint, offset, limit int) ([]model.UserRelation, int64, error) {
+	return pageRelations(DB.Model(&model.UserRelation{}).Where("user_id = ? AND type = ?", userID, model.RelationBlock), offset, limit)
+}
+
+func pageRelations(query *gorm.DB, offset, limit int) ([]model.UserRelation, int64, error) {
+	var relations []model.UserRelation
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at desc, id desc").Offset(offset).Limit(limit).Find(&relations).Error
+	return relations, total, err
+}
+
+// 我关注的所有人的ID
+func GetFollowingIDs(userID uint) ([]uint, error) {
+	var ids []uint
+	err := DB.Model(&model.UserRelation{}).
+		Where("user_id = ? AND type = ?", userID, model.RelationFollow).
+		Pluck("target_id", &ids).Error
+	return ids, err
+}
+
+// 好友（互相关注）的ID
+func GetFriendIDs(userID uint) ([]uint, error) {
+	var ids []uint
+	err := DB.Table("user_relations AS a").
+		Joins("JOIN user_relations AS b ON b.user_id = a.target_id AND b.target_id = a.user_id AND b.type = a.type").
+		Where("a.user_id = ? AND a.type = ?", userID, model.RelationFollow).
+		Pluck("a.target_id", &ids).Error
+	return ids, err
+}
+
+// 在给定用户中筛出我关注的人
+func FilterFollowing(userID uint, targetIDs []uint) (map[uint]bool, error) {
+	following := make(map[uint]bool)
+	if len(targetIDs) == 0 {
+		return following, nil
+	}
+	var ids []uint
+	err := DB.Model(&model.UserRelation{}).
+		Where("user_id = ? AND type = ? AND target_id IN ?", userID, model.RelationFollow, targetIDs).
+		Pluck("target_id", &ids).Error
+	for _, id := range ids {
+		following[id] = true
+	}
+	return following, err
+}
+
+// 与我存在拉黑关系的所有用户（我拉黑的和拉黑我的）
+func GetBlockedIDsBetween(userID uint) ([]uint, error) {
+	var ids []uint
+	err := DB.Model(&model.UserRelation{}).
+		Select("CASE WHEN user_id = ? THEN target_id ELSE user_id END", userID).
+		Where("type = ? AND (user_id = ? OR target_id = ?)", model.RelationBlock, userID, userID).
+		Scan(&ids).Error
+	return ids, err
+}
+
+// 保存好友申请
+func SaveFriendRequest(req *model.FriendRequest) error {
+	return DB.Save(req).Error
+}
+
+// 根据ID获取好友申请
+func GetFriendRequestByID(id uint) (model.FriendRequest, error) {
+	var req model.FriendRequest
+	err := DB.First(&req, id).Error
+	return req, err
+}
+
+// 获取两人之间待处理的好友申请
+func GetPendingFriendRequest(fromID, toID uint) (model.FriendRequest, error) {
+	var req model.FriendRequest
+	err := DB.Where("from_id = ? AND to_id = ? AND status = ?", fromID, toID, model.FriendRequestPending).First(&req).Error
+	return req, err
+}
+
+// 收到的（received）或发出的（sent）好友申请，status 为空时返回全部状态
+func GetFriendRequests(userID uint, box, status string, offset, limit int) ([]model.FriendRequest, int64, error) {
+	var reqs []model.FriendRequest
+	var total int64
+	column := "to_id"
+	if box == "sent" {
+		column = "from_id"
+	}
+	query := DB.Model(&model.FriendRequest{}).Where(column+" = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at desc, id desc").Offset(offset).Limit(limit).Find(&reqs).Error
+	return reqs, total, err
+}
+
+// 同意好友申请：更新状态并让双方互相关注
+func AcceptFriendRequest(req *model.FriendRequest) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(req).Update("status", model.FriendRequestAccepted).Error; err != nil {
+			return err
+		}
+		if err := addRelation(tx, req.FromID, req.ToID, model.RelationFollow); err != nil {
+			return err
+		}
+		return addRelation(tx, req.ToID, req.FromID, model.RelationFollow)
+	})
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"gorm.io/gorm"
+)
+
+// 可被隐藏的内容对应的表
+func contentModel(targetType string) (interface{}, error) {
+	switch targetType {
+	case model.ContentTypePost:
+		return &model.Post{}, nil
+	case model.ContentTypePostComment:
+		return &model.PostComment{}, nil
+	case model.ContentTypeFlag:
+		return &model.Flag{}, nil
+	case model.ContentTypeFlagComment:
+		return &model.FlagComment{}, nil
+	case model.ContentTypeChatMessage:
+		return &model.ChatMessage{}, nil
+	}
+	return nil, fmt.Errorf("未知的内容类型: %s", targetType)
+}
+
+// 保存举报
+func SaveReport(report *model.Report) error {
+	return DB.Create(report).Error
+}
+
+// 用户是否已经举报过这条内容且尚未处理
+func HasOpenReport(reporterID uint, targetType string, targetID uint) (bool, error) {
+	var count int64
+	err := DB.Model(&model.Report{}).
+		Where("reporter_id = ? AND target_type = ? AND target_id = ? AND status = ?", reporterID, targetType, targetID, model.ReportStatusOpen).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// 根据ID获取举报
+func GetReportByID(id uint) (model.Report, error) {
+	var report model.Report
+	err := DB.First(&report, id).Error
+	return report, err
+}
+
+// 分页获取举报（待处理的最早在前，其余最新在前）
+func GetReports(status, targetType string, offset, limit int) ([]model.Report, int64, error) {
+	var reports []model.Report
+	var total int64
+	query := DB.Model(&model.Report{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	order := "created_at desc, id desc"
+	if status == model.ReportStatusOpen {
+		order = "created_at asc, id asc"
+	}
+	err := query.Order(order).Offset(offset).Limit(limit).Find(&reports).Error
+	return reports, total, err
+}
+
+// 批量统计每条内容被举报且待处理的次数
+func CountOpenReportsByTarget(targetType string, targetIDs []uint) (map[uint]int64, error) {
+	result := make(map[uint]int64, len(targetIDs))
+	if len(targetIDs) == 0 {
+		return result, nil
+	}
+	var rows []struct {
+		TargetID uint
+		Count    int64
+	}
+	err := DB.Model(&model.Report{}).Select("target_id, COUNT(*) AS count").
+		Where("target_type = ? AND target_id IN ? AND status = ?", targetType, targetIDs, model.ReportStatusOpen).
+		Group("target_id").Scan(&rows).Error
+	for _, r := range rows {
+		result[r.TargetID] = r.Count
+	}
+	return result, err
+}
+
+// 处理同一条内容的全部待处理举报，返回这些举报的举报人
+func ResolveReports(targetType string, targetID uint, status, action, note string, handlerID uint, at time.Time) ([]uint, error) {
+	var reporterIDs []uint
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&model.Report{}).Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, model.ReportStatusOpen)
+		if err := query.Distinct().Pluck("reporter_id", &reporterIDs).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Report{}).
+			Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, model.ReportStatusOpen).
+			Updates(map[string]interface{}{
+				"status":     status,
+				"action":     action,
+				"note":       note,
+				"handler_id": handlerID,
+				"handled_at": at,
+			}).Error
+	})
+	return reporterIDs, err
+}
+
+// 隐藏或恢复内容；内容不存在时返回 gorm.ErrRecordNotFound
+func SetContentHidden(targetType string, targetID uint, hidden bool) error {
+	m, err := contentModel(targetType)
+	if err != nil {
+		return err
+	}
+	result := DB.Model(m).Where("id = ?", targetID).Update("hidden", hidden)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	// MySQL 对值未变化的行返回 0，需要再确认内容是否存在
+	var count int64
+	if err := DB.Model(m).Where("id = ?", targetID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// 根据ID获取聊天消息
+func GetChatMessageByID(id uint) (model.ChatMessage, error) {
+	var message model.ChatMessage
+	err := DB.Preload("Attachments").First(&message, id).Error
+	return message, err
+}
+
+// 删除聊天消息
+func DeleteChatMessage(id uint) error {
+	return DB.Delete(&model.ChatMessage{}, id).Error
+}
+
+// 警告次数加一
+func AddUserWarning(userID uint) error {
+	return DB.Model(&model.User{}).Where("id = ?", userID).UpdateColumn("warnings", gorm.Expr("warnings + 1")).Error
+}
+
+// 设置封禁截止时间（nil 表示解除封禁）
+func SetUserSuspendedUntil(userID uint, until *time.Time) error {
+	return DB.Model(&model.User{}).Where("id = ?", userID).Update("suspended_until", until).Error
+}
+
+// 用户的封禁截止时间（只查询这一列）
+func GetUserSuspendedUntil(userID uint) (*time.Time, error) {
+	var user model.User
+	err := DB.Select("id", "suspended_until").First(&user, userID).Error
+	return user.SuspendedUntil, err
+}
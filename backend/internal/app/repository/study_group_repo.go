@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"gorm.io/gorm"
+)
+
+// 创建小组，创建者自动成为组长
+func CreateStudyGroup(group *model.StudyGroup) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(group).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Create(&model.GroupMember{
+			GroupID:  group.ID,
+			UserID:   group.OwnerID,
+			Role:     model.GroupRoleOwner,
+			Status:   model.GroupMemberActive,
+			JoinedAt: &now,
+		}).Error
+	})
+}
+
+// 根据ID获取小组
+func GetStudyGroupByID(id uint) (model.StudyGroup, error) {
+	var group model.StudyGroup
+	err := DB.First(&group, id).Error
+	return group, err
+}
+
+// 根据邀请码获取小组
+func GetStudyGroupByInviteCode(code string) (model.StudyGroup, error) {
+	var group model.StudyGroup
+	err := DB.Where("invite_code = ?", code).First(&group).Error
+	return group, err
+}
+
+// 获取所有小组（启动时创建小组聊天室）
+func GetAllStudyGroups() ([]model.StudyGroup, error) {
+	var groups []model.StudyGroup
+	err := DB.Find(&groups).Error
+	return groups, err
+}
+
+// 保存小组信息
+func SaveStudyGroup(group *model.StudyGroup) error {
+	return DB.Save(group).Error
+}
+
+// 解散小组：删除成员、共同目标和动态
+func DeleteStudyGroup(id uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, m := range []interface{}{&model.GroupMember{}, &model.GroupFlag{}, &model.GroupActivity{}} {
+			if err := tx.Where("group_id = ?", id).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&model.StudyGroup{}, id).Error
+	})
+}
+
+// 获取用户在小组中的成员记录
+func GetGroupMember(groupID, userID uint) (model.GroupMember, error) {
+	var member model.GroupMember
+	err := DB.Where("group_id = ? AND user_id = ?", groupID, userID).First(&member).Error
+	return member, err
+}
+
+// 保存成员记录
+func SaveGroupMember(member *model.GroupMember) error {
+	return DB.Save(member).Error
+}
+
+// 移除成员
+func DeleteGroupMember(groupID, userID uint) error {
+	return DB.Where("group_id = ? AND user_id = ?", groupID, userID).Delete(&model.GroupMember{}).Error
+}
+
+// 分页获取小组成员（组长、管理员在前）
+func GetGroupMembers(groupID uint, status string, offset, limit int) ([]model.GroupMember, int64, error) {
+	var members []model.GroupMember
+	var total int64
+	query := DB.Model(&model.GroupMember{}).Where("group_id = ? AND status = ?", groupID, status)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order(fmt.Sprintf("FIELD(role, '%s', '%s', '%s')", model.GroupRoleOwner, model.GroupRoleAdmin, model.GroupRoleMember)).
+		Order("created_at asc").
+		Offset(offset).Limit(limit).
+		Find(&members).Error
+	return members, total, err
+}
+
+// 小组正式成员数
+func CountActiveGroupMembers(groupID uint) (int64, error) {
+	var count int64
+	err := DB.Model(&model.GroupMember{}).Where("group_id = ? AND status = ?", groupID, model.GroupMemberActive).Count(&count).Error
+	return count, err
+}
+
+// 批量统计各小组的正式成员数
+func CountActiveMembersByGroups(groupIDs []uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64)
+	if len(groupIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		GroupID uint
+		Count   int64
+	}
+	err := DB.Model(&model.GroupMember{}).
+		Select("group_id, COUNT(*) AS count").
+		Where("group_id IN ? AND status = ?", groupIDs, model.GroupMemberActive).
+		Group("group_id").
+		Scan(&rows).Error
+	for _, r := range rows {
+		counts[r.GroupID] = r.Count
+	}
+	return counts, err
+}
+
+// 小组所有正式成员的ID
+func GetActiveMemberIDs(groupID uint) ([]uint, error) {
+	var ids []uint
+	err := DB.Model(&model.GroupMember{}).
+		Where("group_id = ? AND status = ?", groupID, model.GroupMemberActive).
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// 用户作为正式成员加入的小组记录
+func GetUserGroupMemberships(userID uint) ([]model.GroupMember, error) {
+	var members []model.GroupMember
+	err := DB.Where("user_id = ? AND status = ?", userID, model.GroupMemberActive).Order("created_at desc").Find(&members).Error
+	return members, err
+}
+
+// 批量获取小组
+func GetStudyGroupsByIDs(ids []uint) ([]model.StudyGroup, error) {
+	var groups []model.StudyGroup
+	if len(ids) == 0 {
+		return groups, nil
+	}
+	err := DB.Where("id IN ?", ids).Find(&groups).Error
+	return groups, err
+}
+
+// 保存共同目标
+func SaveGroupFlag(flag *model.GroupFlag) error {
+	return DB.Save(flag).Error
+}
+
+// 根据ID获取共同目标
+func GetGroupFlagByID(id uint) (model.GroupFlag, error) {
+	var flag model.GroupFlag
+	err := DB.First(&flag, id).Error
+	return flag, err
+}
+
+// 小组的所有共同目标（最新在前）
+func GetGroupFlags(groupID uint) ([]model.GroupFlag, error) {
+	var flags []model.GroupFlag
+	err := DB.Where("group_id = ?", groupID).Order("created_at desc").Find(&flags).Error
+	return flags, err
+}
+
+// 进行中且未完成的共同目标
+func GetOpenGroupFlags(groupID uint, metric string, now time.Time) ([]model.GroupFlag, error) {
+	var flags []model.GroupFlag
+	err := DB.Where("group_id = ? AND metric = ? AND completed_at IS NULL AND start_time <= ? AND end_time > ?", groupID, metric, now, now).
+		Find(&flags).Error
+	return flags, err
+}
+
+// 标记共同目标已完成，返回是否是本次标记的（避免重复通知）
+func MarkGroupFlagCompleted(id uint, at time.Time) (bool, error) {
+	result := DB.Model(&model.GroupFlag{}).Where("id = ? AND completed_at IS NULL", id).Update("completed_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+// 删除共同目标
+func DeleteGroupFlag(id uint) error {
+	return DB.Delete(&model.GroupFlag{}, id).Error
+}
+
+// 添加小组动态
+func AddGroupActivity(activity *model.GroupActivity) error {
+	return DB.Create(activity).Error
+}
+
+// 分页获取小组动态（最新在前）
+func GetGroupActivities(groupID uint, offset, limit int) ([]model.GroupActivity, int64, error) {
+	var activities []model.GroupActivity
+	var total int64
+	query := DB.Model(&model.GroupActivity{}).Where("group_id = ?", groupID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at desc, id desc").Offset(offset).Limit(limit).Find(&activities).Error
+	return activities, total, err
+}
+
+// 一组用户在时间区间内各自的指标值；label > 0 时只统计该标签的flag完成记录
+func GetGoalMetricScores(metric string, label int, userIDs []uint, start, end time.Time) (map[uint]int, error) {
+	scores := make(map[uint]int)
+	if len(userIDs) == 0 {
+		return scores, nil
+	}
+	var rows []RankingScore
+	var err error
+	switch metric {
+	case model.GoalMetricLearnMinutes:
+		err = DB.Model(&model.LearnTime{}).
+			Select("user_id, SUM(duration) AS score").
+			Where("user_id IN ? AND created_at >= ? AND created_at < ? AND duration > 0", userIDs, start, end).
+			Group("user_id").
+			Scan(&rows).Error
+	case model.GoalMetricDakaDays:
+		err = DB.Model(&model.Daka_number{}).Scopes(dakaDoneScope).
+			Select("user_id, COUNT(DISTINCT DATE(daka_date)) AS score").
+			Where("user_id IN ? AND daka_date >= ? AND daka_date < ?", userIDs, start, end).
+			Group("user_id").
+			Scan(&rows).Error
+	case model.GoalMetricFlagCompletions:
+		query := DB.Model(&model.FlagCompletion{}).
+			Select("user_id, COUNT(*) AS score").
+			Where("user_id IN ? AND created_at >= ? AND created_at < ?", userIDs, start, end)
+		if label > 0 {
+			query = query.Where("label = ?", label)
+		}
+		err = query.Group("user_id").Scan(&rows).Error
+	case model.GoalMetricPoints:
+		err = DB.Model(&model.PointsLog{}).
+			Select("user_id, SUM(amount) AS score").
+			Where("user_id IN ? AND created_at >= ? AND created_at < ?", userIDs, start, end).
+			Group("user_id").
+			Scan(&rows).Error
+	default:
+		err = fmt.Errorf("未知的目标指标: %s", metric)
+	}
+	for _, r := range rows {
+		scores[r.UserID] = r.Score
+	}
+	return scores, err
+}
+
+// 一组用户在时间区间内的指标之和
+func SumGoalMetricForUsers(metric string, userIDs []uint, start, end time.Time) (int64, error) {
+	scores, err := GetGoalMetricScores(metric, 0, userIDs, start, end)
+	var total int64
+	for _, score := range scores {
+		total += int64(score)
+	}
+	return total, err
+}
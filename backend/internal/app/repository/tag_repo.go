@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 话题在一段时间内的使用次数
+type TagStat struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// 用解析出的话题替换某条内容原有的话题（编辑时只给新增的话题计数）
+func ReplaceContentTags(ownerType string, ownerID uint, names []string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var existing []uint
+		if err := tx.Model(&model.TagUsage{}).Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+			Pluck("tag_id", &existing).Error; err != nil {
+			return err
+		}
+		had := make(map[uint]bool, len(existing))
+		for _, id := range existing {
+			had[id] = true
+		}
+
+		now := time.Now()
+		keep := make([]uint, 0, len(names))
+		for _, name := range names {
+			tag := model.Tag{Name: name, LastUsedAt: now}
+			if err := tx.Where("name = ?", name).FirstOrCreate(&tag).Error; err != nil {
+				return err
+			}
+			keep = append(keep, tag.ID)
+			if had[tag.ID] {
+				continue
+			}
+			usage := model.TagUsage{TagID: tag.ID, OwnerType: ownerType, OwnerID: ownerID}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&usage).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.Tag{}).Where("id = ?", tag.ID).Updates(map[string]interface{}{
+				"use_count":    gorm.Expr("use_count + 1"),
+				"last_used_at": now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		query := tx.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID)
+		if len(keep) > 0 {
+			query = query.Where("tag_id NOT IN ?", keep)
+		}
+		return query.Delete(&model.TagUsage{}).Error
+	})
+}
+
+// 一段时间内使用最多的话题
+func GetTrendingTags(since time.Time, limit int) ([]TagStat, error) {
+	var stats []TagStat
+	err := DB.Table("tag_usages").
+		Select("tags.id AS id, tags.name AS name, COUNT(*) AS count").
+		Joins("JOIN tags ON tags.id = tag_usages.tag_id").
+		Where("tag_usages.created_at >= ?", since).
+		Group("tags.id, tags.name").
+		Order("count desc, tags.id desc").
+		Limit(limit).
+		Scan(&stats).Error
+	return stats, err
+}
+
+// 按名称获取话题
+func GetTagByName(name string) (model.Tag, error) {
+	var tag model.Tag
+	err := DB.Where("name = ?", name).First(&tag).Error
+	return tag, err
+}
+
+// 分页获取带某个话题的帖子（最新在前），excludeUserIDs 为需要屏蔽的作者
+func GetPostsByTag(tagID uint, excludeUserIDs []uint, offset, limit int) ([]model.Post, int64, error) {
+	var posts []model.Post
+	var total int64
+	query := DB.Model(&model.Post{}).
+		Joins("JOIN tag_usages ON tag_usages.owner_id = posts.id AND tag_usages.owner_type = ?", model.ContentTypePost).
+		Where("tag_usages.tag_id = ? AND posts.hidden = ?", tagID, false)
+	if len(excludeUserIDs) > 0 {
+		query = query.Where("posts.user_id NOT IN ?", excludeUserIDs)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Select("posts.*").Preload("User").Preload("Attachments").
+		Order("posts.created_at desc, posts.id desc").
+		Offset(offset).Limit(limit).Find(&posts).Error
+	return posts, total, err
+}
+
+// 按用户名批量查询用户（只查询ID和名字）
+func GetUsersByNames(names []string) ([]model.User, error) {
+	var users []model.User
+	if len(names) == 0 {
+		return users, nil
+	}
+	err := DB.Select("id", "name").Where("name IN ?", names).Find(&users).Error
+	return users, err
+}
+
+// 某条内容已经提及过的用户
+func GetMentionedUserIDs(ownerType string, ownerID uint) ([]uint, error) {
+	var ids []uint
+	err := DB.Model(&model.Mention{}).Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+		Pluck("user_id", &ids).Error
+	return ids, err
+}
+
+// 保存提及记录（重复的忽略）
+func SaveMentions(mentions []model.Mention) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+	return DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&mentions).Error
+}
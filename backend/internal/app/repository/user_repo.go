@@ -27,7 +27,7 @@ func DBconnect() {
 		return
 	}
 	DB = db
-	DB.AutoMigrate(&model.User{}, &model.Flag{}, &model.Post{}, &model.PostComment{}, &model.Achievement{}, &model.LearnTime{}, &model.Daka_number{}, &model.EmailCode{}, &model.FlagComment{}, &model.TrackPoint{}, &model.ChatMessage{}, &model.UserPostLike{}, &model.PointsLog{})
+	DB.AutoMigrate(&model.User{}, &model.Flag{}, &model.Post{}, &model.PostComment{}, &model.Achievement{}, &model.LearnTime{}, &model.Daka_number{}, &model.EmailCode{}, &model.FlagComment{}, &model.TrackPoint{}, &model.ChatMessage{}, &model.UserPostLike{}, &model.PointsLog{}, &model.AIPlan{}, &model.FlagCompletion{}, &model.WeeklyReport{}, &model.ModerationRecord{}, &model.UserRelation{}, &model.FriendRequest{}, &model.StudyGroup{}, &model.GroupMember{}, &model.GroupFlag{}, &model.GroupActivity{}, &model.Challenge{}, &model.ChallengeParticipant{}, &model.ChallengeBadge{}, &model.UserFlagLike{}, &model.PostRevision{}, &model.Attachment{}, &model.Tag{}, &model.TagUsage{}, &model.Mention{}, &model.Notification{}, &model.Report{}, &model.AIUsage{})
 }
 
 // user添加到数据库
@@ -63,8 +63,12 @@ func UpdateUser(user model.User) error {
 
 // 从数据库删除flag
 func DeleteFlagFromDB(flagID uint) error {
-	result := DB.Delete(&model.Flag{}, flagID)
-	return result.Error
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("flag_id = ?", flagID).Delete(&model.UserFlagLike{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Flag{}, flagID).Error
+	})
 }
 
 // 通过用户ID获取flag列表
@@ -155,19 +159,34 @@ func UpdatePlanContent(flagID uint, newPlanContent string) error {
 	return result.Error
 }
 
-// 更新flag的评论
-func UpdateFlagComment(flagID uint, newComment string) error {
-	var flagComment model.FlagComment
-	flagComment.FlagID = flagID
-	flagComment.Content = newComment
-	result := DB.Model(&model.FlagComment{}).Where("flag_id = ?", flagID).Create(&flagComment)
-	return result.Error
+// 添加flag评论
+func AddFlagComment(comment *model.FlagComment) error {
+	return DB.Create(comment).Error
 }
 
-// 删除flag的评论
-func DeleteFlagComment(flagcommentID uint) error {
-	result := DB.Model(&model.FlagComment{}).Where("id = ?", flagcommentID).Delete(&model.FlagComment{})
-	return result.Error
+// 根据ID获取flag评论
+func GetFlagCommentByID(id uint) (model.FlagComment, error) {
+	var comment model.FlagComment
+	err := DB.Preload("User").Preload("Attachments").First(&comment, id).Error
+	return comment, err
+}
+
+// 删除flag的评论（一级评论连同其回复一起删除）
+func DeleteFlagComment(flagcommentID uint) ([]uint, error) {
+	var ids []uint
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.FlagComment{}).Where("id = ? OR parent_id = ?", flagcommentID, flagcommentID).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Where("owner_type = ? AND owner_id IN ?", model.ContentTypeFlagComment, ids).Delete(&model.TagUsage{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&model.FlagComment{}).Error
+	})
+	return ids, err
 }
 
 // 更新用户密码
@@ -201,6 +220,12 @@ func UpdateFlagHadDone(flagID uint, isdo bool) error {
 	return result.Error
 }
 
+// 把未完成的flag标记为完成，返回是否实际更新（已完成时返回 false）
+func MarkFlagDone(flagID uint) (bool, error) {
+	result := DB.Model(&model.Flag{}).Where("id = ? AND had_done = ?", flagID, false).Update("had_done", true)
+	return result.RowsAffected > 0, result.Error
+}
+
 // 打卡时间更新
 func UpdateUserDoFlag(id uint, doFlag time.Time) error {
 	result := DB.Model(&model.User{}).Where("id=?", id).Update("do_flag", doFlag)
@@ -220,16 +245,23 @@ func UpdateUserStatus(id uint, status string) error {
 }
 
 // 发布帖子
-func AddPostToDB(Id uint, post model.Post) error {
+func AddPostToDB(Id uint, post *model.Post) error {
 	post.UserID = Id
-	result := DB.Create(&post)
+	result := DB.Create(post)
 	return result.Error
 }
 
 // 删除帖子
 func DeletePostFromDB(postID uint) error {
-	result := DB.Delete(&model.Post{}, postID)
-	return result.Error
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("post_id = ?", postID).Delete(&model.PostRevision{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("owner_type = ? AND owner_id = ?", model.ContentTypePost, postID).Delete(&model.TagUsage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Post{}, postID).Error
+	})
 }
 
 // 根据FlagID删除关联的帖子
@@ -239,24 +271,36 @@ func DeletePostsByFlagID(flagID uint) error {
 }
 
 // 添加评论
-func AddPostCommentToDB(postId uint, comment model.PostComment) error {
+func AddPostCommentToDB(postId uint, comment *model.PostComment) error {
 	comment.PostID = postId
-	result := DB.Create(&comment)
+	result := DB.Create(comment)
 	return result.Error
 }
 
-// 删除评论
-func DeletePostCommentFromDB(commentID uint) error {
-	result := DB.Delete(&model.PostComment{}, commentID)
-	return result.Error
+// 删除评论（一级评论连同其回复一起删除）
+func DeletePostCommentFromDB(commentID uint) ([]uint, error) {
+	var ids []uint
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.PostComment{}).Where("id = ? OR parent_id = ?", commentID, commentID).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := tx.Where("owner_type = ? AND owner_id IN ?", model.ContentTypePostComment, ids).Delete(&model.TagUsage{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&model.PostComment{}).Error
+	})
+	return ids, err
 }
 
 // 根据关键词找帖子
 func SearchPosts(keyword string) ([]model.Post, error) {
 	var posts []model.Post
 	like := "%" + keyword + "%"
-	err := DB.Preload("User").Preload("Comments").
-		Where("title LIKE ? OR content LIKE ?", like, like).Find(&posts).Error
+	err := DB.Preload("User").Preload("Comments", "hidden = ?", false).
+		Where("(title LIKE ? OR content LIKE ?) AND hidden = ?", like, like, false).Find(&posts).Error
 	return posts, err
 }
 
@@ -381,31 +425,39 @@ func GetFlagByID(flagID uint) (model.Flag, error) {
 	return flag, result.Error
 }
 
-// 获取所有的帖子（包含用户信息）
-func GetAllPosts() ([]model.Post, error) {
+// 分页获取帖子（最新在前）
+func GetPostsPage(offset, limit int) ([]model.Post, int64, error) {
 	var posts []model.Post
-	result := DB.Preload("Comments.User").Preload("User").Order("created_at desc").Find(&posts)
-	return posts, result.Error
+	var total int64
+	if err := DB.Model(&model.Post{}).Where("hidden = ?", false).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := DB.Preload("Comments", "hidden = ?", false).Preload("Comments.User").Preload("Comments.Attachments").Preload("User").Preload("Attachments").
+		Where("hidden = ?", false).
+		Order("created_at desc").Order("id desc").
+		Offset(offset).Limit(limit).
+		Find(&posts).Error
+	return posts, total, err
 }
 
 // 根据ID获取单个帖子
 func GetPostByID(postID uint) (model.Post, error) {
 	var post model.Post
-	result := DB.Preload("Comments.User").Preload("User").First(&post, postID)
+	result := DB.Preload("Comments", "hidden = ?", false).Preload("Comments.User").Preload("Comments.Attachments").Preload("User").Preload("Attachments").First(&post, postID)
 	return post, result.Error
 }
 
 // 根据ID获取单个评论
 func GetCommentByID(commentID uint) (model.PostComment, error) {
 	var comment model.PostComment
-	result := DB.Preload("User").First(&comment, commentID)
+	result := DB.Preload("User").Preload("Attachments").First(&comment, commentID)
 	return comment, result.Error
 }
 
 // 获取所有可见的flag
 func GetVisibleFlags() ([]model.Flag, error) {
 	var flags []model.Flag
-	result := DB.Preload("FlagComment").Where("is_public = ?", true).Find(&flags)
+	result := DB.Preload("Comments", "hidden = ?", false).Preload("Comments.User").Where("is_public = ? AND hidden = ?", true, false).Find(&flags)
 	return flags, result.Error
 }
 
@@ -714,6 +766,14 @@ func GetAchievementByName(usrID uint, name string) (model.Achievement, error) {
 	return achievement, result.Error
 }
 
+// 筛选已打卡的记录
+// had_done 每天凌晨会被重置，历史记录以 month_daka > 0 判断当天是否打卡，今天的记录仍以 had_done 为准
+func dakaDoneScope(db *gorm.DB) *gorm.DB {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return db.Where("month_daka > 0").Where("had_done = ? OR daka_date < ?", true, today)
+}
+
 // 添加打卡记录
 func DakaNumberToDB(user_id uint) error {
 	// 先查询是否存在打卡记录
@@ -889,14 +949,49 @@ func UpdateUserRemindStatus(id uint, IsRemind bool) error {
 }
 
 // flag点赞
-func UpdateFlagLikes(flagID uint, like int) error {
-	result := DB.Model(&model.Flag{}).Where("id = ?", flagID).Update("likes", like)
-	return result.Error
+// 切换flag点赞状态，返回最新点赞数和当前是否已点赞
+func ToggleFlagLike(flagID uint, userID uint) (int, bool, error) {
+	var likes int
+	var liked bool
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND flag_id = ?", userID, flagID).Delete(&model.UserFlagLike{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			// 已点赞，取消点赞，确保不会小于0
+			if err := tx.Model(&model.Flag{}).Where("id = ?", flagID).
+				UpdateColumn("like", gorm.Expr("CASE WHEN `like` > 0 THEN `like` - 1 ELSE 0 END")).Error; err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Create(&model.UserFlagLike{UserID: userID, FlagID: flagID}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.Flag{}).Where("id = ?", flagID).
+				UpdateColumn("like", gorm.Expr("`like` + 1")).Error; err != nil {
+				return err
+			}
+			liked = true
+		}
+		return tx.Model(&model.Flag{}).Where("id = ?", flagID).Pluck("like", &likes).Error
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return likes, liked, nil
+}
+
+// 按点赞关系表重算flag点赞数（旧接口允许客户端直接写入 like，历史数据与关系表不一致）
+func RecountFlagLikes() (int64, error) {
+	count := "(SELECT COUNT(*) FROM user_flag_likes WHERE user_flag_likes.flag_id = flags.id)"
+	result := DB.Model(&model.Flag{}).Where("`like` <> "+count).UpdateColumn("like", gorm.Expr(count))
+	return result.RowsAffected, result.Error
 }
 
 // post点赞
 // 切换帖子点赞状态（自动判断点赞/取消点赞）- 使用事务确保原子性
-func TogglePostLike(postID uint, userID uint) (int, error) {
+func TogglePostLike(postID uint, userID uint) (int, bool, error) {
 	utils.LogInfo("TogglePostLike 函数被调用", map[string]interface{}{
 		"post_id": postID,
 		"user_id": userID,
@@ -910,7 +1005,7 @@ func TogglePostLike(postID uint, userID uint) (int, error) {
 			"user_id": userID,
 			"error":   tx.Error.Error(),
 		})
-		return 0, tx.Error
+		return 0, false, tx.Error
 	}
 
 	defer func() {
@@ -937,7 +1032,7 @@ func TogglePostLike(postID uint, userID uint) (int, error) {
 				"user_id": userID,
 				"error":   err.Error(),
 			})
-			return 0, err
+			return 0, false, err
 		}
 
 		// 减少点赞数，确保不会小于0
@@ -948,7 +1043,7 @@ func TogglePostLike(postID uint, userID uint) (int, error) {
 				"user_id": userID,
 				"error":   err.Error(),
 			})
-			return 0, err
+			return 0, false, err
 		}
 
 		// 获取更新后的点赞数
@@ -960,7 +1055,7 @@ func TogglePostLike(postID uint, userID uint) (int, error) {
 				"user_id": userID,
 				"error":   err.Error(),
 			})
-			return 0, err
+			return 0, false, err
 		}
 
 		// 提交事务
@@ -970,7 +1065,7 @@ func TogglePostLike(postID uint, userID uint) (int, error) {
 				"user_id": userID,
 				"error":   err.Error(),
 			})
-			return 0, err
+			return 0, false, err
 		}
 
 		utils.LogInfo("取消点赞成功", map[string]interface{}{
@@ -978,7 +1073,7 @@ func TogglePostLike(postID uint, userID uint) (int, error) {
 			"user_id":   userID,
 			"new_likes": post.Like,
 		})
-		return post.Like, nil
+		return post.Like, false, nil
 
 	} else if errors.Is(err, gorm.ErrRecordNotFound) {
 		// 未点赞，添加点赞
@@ -994,7 +1089,7 @@ func TogglePostLike(postID uint, userID uint) (int, error) {
 				"user_id": userID,
 				"error":   err.Error(),
 			})
-			return 0, err
+			return 0, false, err
 		}
 
 		// 增加点赞数
@@ -1005,7 +1100,7 @@ func TogglePostLike(postID uint, userID uint) (int, error) {
 				"user_id": userID,
 				"error":   err.Error(),
 			})
-			return 0, err
+			return 0, false, err
 		}
 
 		// 获取更新后的点赞数
@@ -1017,7 +1112,7 @@ func TogglePostLike(postID uint, userID uint) (int, error) {
 				"user_id": userID,
 				"error":   err.Error(),
 			})
-			return 0, err
+			return 0, false, err
 		}
 
 		// 提交事务
@@ -1027,7 +1122,7 @@ func TogglePostLike(postID uint, userID uint) (int, error) {
 				"user_id": userID,
 				"error":   err.Error(),
 			})
-			return 0, err
+			return 0, false, err
 		}
 
 		utils.LogInfo("点赞成功", map[string]interface{}{
@@ -1035,7 +1130,7 @@ func TogglePostLike(postID uint, userID uint) (int, error) {
 			"user_id":   userID,
 			"new_likes": post.Like,
 		})
-		return post.Like, nil
+		return post.Like, true, nil
 
 	} else {
 		// 其他数据库错误
@@ -1045,7 +1140,7 @@ func TogglePostLike(postID uint, userID uint) (int, error) {
 			"user_id": userID,
 			"error":   err.Error(),
 		})
-		return 0, err
+		return 0, false, err
 	}
 }
 
@@ -1068,6 +1163,13 @@ func GetPostLikes(flagID uint) (int, error) {
 	return post.Like, result.Error
 }
 
+// 获取用户点过赞的flag ID列表
+func GetLikedFlagIDsByUser(userID uint) ([]uint, error) {
+	var ids []uint
+	err := DB.Model(&model.UserFlagLike{}).Where("user_id = ?", userID).Pluck("flag_id", &ids).Error
+	return ids, err
+}
+
 // 获取用户点过赞的帖子ID列表
 func GetLikedPostIDsByUser(userID uint) ([]uint, error) {
 	var likes []model.UserPostLike
@@ -1143,7 +1245,7 @@ func SaveChatMessage(message *model.ChatMessage) error {
 // 获取谈玄斋历史消息（最近30条）
 func GetChatHistory(roomID string, limit int) ([]model.ChatMessage, error) {
 	var messages []model.ChatMessage
-	err := DB.Preload("User").Where("room_id = ?", roomID).Order("created_at desc").Limit(limit).Find(&messages).Error
+	err := DB.Preload("User").Preload("Attachments").Where("room_id = ? AND hidden = ?", roomID, false).Order("created_at desc").Limit(limit).Find(&messages).Error
 	if err != nil {
 		return nil, err
 	}
@@ -1157,8 +1259,8 @@ func GetChatHistory(roomID string, limit int) ([]model.ChatMessage, error) {
 // 获取私聊历史消息（最近30条）
 func GetPrivateChatHistory(userID1, userID2 uint, limit int) ([]model.ChatMessage, error) {
 	var messages []model.ChatMessage
-	err := DB.Preload("User").
-		Where("(from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?)", userID1, userID2, userID2, userID1).
+	err := DB.Preload("User").Preload("Attachments").
+		Where("((from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?)) AND hidden = ?", userID1, userID2, userID2, userID1, false).
 		Order("created_at desc").
 		Limit(limit).
 		Find(&messages).Error
@@ -1189,7 +1291,7 @@ func GetPrivateConversations(userID uint) ([]Conversation, error) {
 	// 简化版本：直接查询所有私聊消息，在Go中处理分组
 	var messages []model.ChatMessage
 	err := DB.Preload("User").
-		Where("(from_user_id = ? OR to_user_id = ?) AND (room_id = '' OR room_id IS NULL)", userID, userID).
+		Where("(from_user_id = ? OR to_user_id = ?) AND (room_id = '' OR room_id IS NULL) AND hidden = ?", userID, userID, false).
 		Order("created_at DESC").
 		Find(&messages).Error
 
@@ -1221,16 +1323,10 @@ func GetPrivateConversations(userID uint) ([]Conversation, error) {
 			continue
 		}
 
-		// 构建头像路径（使用 utils.GetAvatarPath 统一返回 /api/avatar/:id）
-		var avatar string
-		if user.HeadShow > 0 {
-			avatar = utils.GetAvatarPath(user.HeadShow)
-		}
-
 		conversationMap[otherUserID] = &Conversation{
 			UserID:        user.ID,
 			UserName:      user.Name,
-			UserAvatar:    avatar,
+			UserAvatar:    user.AvatarURL(),
 			LastMessage:   msg.Content,
 			LastMessageAt: msg.CreatedAt,
 			UnreadCount:   0, // TODO: 实现未读计数
@@ -1265,3 +1361,51 @@ func InvalidateAllTodayLearnTime() error {
 		Update("duration", -1).Error
 	return err
 }
+
+// 记录一次flag完成
+func AddFlagCompletion(userID uint, flagID uint, label int) error {
+	return DB.Create(&model.FlagCompletion{
+		UserID:    userID,
+		FlagID:    flagID,
+		Label:     label,
+		CreatedAt: time.Now(),
+	}).Error
+}
+
+// 获取若干flag在某时间之后的完成记录
+func GetFlagCompletionsSince(flagIDs []uint, since time.Time) ([]model.FlagCompletion, error) {
+	var completions []model.FlagCompletion
+	if len(flagIDs) == 0 {
+		return completions, nil
+	}
+	err := DB.Where("flag_id IN ? AND created_at >= ?", flagIDs, since).Order("created_at").Find(&completions).Error
+	return completions, err
+}
+
+// 获取用户某时间之后的学习时长记录（不含被作废的记录）
+func GetLearnTimesSince(userID uint, since time.Time) ([]model.LearnTime, error) {
+	var learnTimes []model.LearnTime
+	err := DB.Where("user_id = ? AND created_at >= ? AND duration > 0", userID, since).Order("created_at").Find(&learnTimes).Error
+	return learnTimes, err
+}
+
+// 获取用户角色
+func GetUserRole(userID uint) (string, error) {
+	var user model.User
+	err := DB.Select("id, role").First(&user, userID).Error
+	return user.Role, err
+}
+
+// 把指定邮箱的用户设为管理员
+func PromoteAdmins(emails []string) (int64, error) {
+	if len(emails) == 0 {
+		return 0, nil
+	}
+	result := DB.Model(&model.User{}).Where("email IN ? AND role <> ?", emails, model.UserRoleAdmin).Update("role", model.UserRoleAdmin)
+	return result.RowsAffected, result.Error
+}
+
+// 更新用户的自定义头像（key 为空表示恢复内置头像）
+func UpdateUserAvatarKey(userID uint, key string) error {
+	return DB.Model(&model.User{}).Where("id = ?", userID).Update("avatar_key", key).Error
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+)
+
+// 获取时间区间内的学习时长记录
+func GetLearnTimesBetween(userID uint, start, end time.Time) ([]model.LearnTime, error) {
+	var records []model.LearnTime
+	err := DB.Where("user_id = ? AND created_at >= ? AND created_at < ? AND duration > 0", userID, start, end).
+		Order("created_at asc").
+		Find(&records).Error
+	return records, err
+}
+
+// 统计时间区间内的打卡天数
+func CountDakaDaysBetween(userID uint, start, end time.Time) (int64, error) {
+	var count int64
+	err := DB.Model(&model.Daka_number{}).Scopes(dakaDoneScope).
+		Where("user_id = ? AND daka_date >= ? AND daka_date < ?", userID, start, end).
+		Select("COUNT(DISTINCT DATE(daka_date))").
+		Scan(&count).Error
+	return count, err
+}
+
+// 统计时间区间内完成flag的次数
+func CountFlagCompletionsBetween(userID uint, start, end time.Time) (int64, error) {
+	var count int64
+	err := DB.Model(&model.FlagCompletion{}).
+		Where("user_id = ? AND created_at >= ? AND created_at < ?", userID, start, end).
+		Count(&count).Error
+	return count, err
+}
+
+// 统计时间区间内获得的积分
+func SumPointsBetween(userID uint, start, end time.Time) (int, error) {
+	var total int
+	err := DB.Model(&model.PointsLog{}).
+		Where("user_id = ? AND created_at >= ? AND created_at < ?", userID, start, end).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// 保存周报，同一用户同一周已存在时覆盖
+func SaveWeeklyReport(report *model.WeeklyReport) error {
+	var existing model.WeeklyReport
+	err := DB.Where("user_id = ? AND week_start = ?", report.UserID, report.WeekStart).First(&existing).Error
+	if err == nil {
+		report.ID = existing.ID
+		report.CreatedAt = existing.CreatedAt
+		report.EmailedAt = existing.EmailedAt
+	}
+	return DB.Save(report).Error
+}
+
+// 获取用户某一周的周报
+func GetWeeklyReportByWeek(userID uint, weekStart time.Time) (model.WeeklyReport, error) {
+	var report model.WeeklyReport
+	err := DB.Where("user_id = ? AND week_start = ?", userID, weekStart).First(&report).Error
+	return report, err
+}
+
+// 根据ID获取周报
+func GetWeeklyReportByID(reportID uint) (model.WeeklyReport, error) {
+	var report model.WeeklyReport
+	err := DB.First(&report, reportID).Error
+	return report, err
+}
+
+// 分页获取用户的周报（最新在前）
+func GetWeeklyReportsByUserID(userID uint, offset, limit int) ([]model.WeeklyReport, int64, error) {
+	var reports []model.WeeklyReport
+	var total int64
+	query := DB.Model(&model.WeeklyReport{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("week_start desc").Offset(offset).Limit(limit).Find(&reports).Error
+	return reports, total, err
+}
+
+// 标记周报已发送邮件
+func UpdateWeeklyReportEmailedAt(reportID uint, emailedAt time.Time) error {
+	return DB.Model(&model.WeeklyReport{}).Where("id = ?", reportID).Update("emailed_at", emailedAt).Error
+}
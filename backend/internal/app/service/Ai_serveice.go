@@ -1,14 +1,11 @@
 package service
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
 	"strings"
-	"time"
 
 	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
 	"github.com/gin-gonic/gin"
@@ -27,35 +24,36 @@ type LearningPlanResponse struct {
 	Flag    string `json:"flag"`
 	Count   int    `json:"difficulty"` // 难度评分: 1,2,3
 	Plan    string `json:"plan"`
+	PlanID  uint   `json:"plan_id,omitempty"` // 历史记录ID
+	Cached  bool   `json:"cached,omitempty"`  // 是否命中缓存
 	Error   string `json:"error,omitempty"`
 }
 
 // 太傅AI学习
 type TaiFuLearningPlanner struct {
-	APIKey  string
-	BaseURL string
+	Provider LLMProvider
 }
 
 var planner *TaiFuLearningPlanner
 
+// 使用指定 provider 创建 planner
+func NewTaiFuLearningPlanner(provider LLMProvider) *TaiFuLearningPlanner {
+	return &TaiFuLearningPlanner{Provider: provider}
+}
+
 // 初始化 planner（延迟初始化，等待 .env 加载）
 func initPlanner() {
 	if planner == nil {
-		apiKey := os.Getenv("APIKEY")
-		if apiKey == "" {
-			fmt.Printf("❌ 警告：APIKEY环境变量未设置\n")
-		} else {
-			fmt.Printf("✅ API Key已加载，前缀: %s...\n", apiKey[:min(10, len(apiKey))])
-		}
-
-		planner = &TaiFuLearningPlanner{
-			APIKey:  apiKey,
-			BaseURL: "https://api.siliconflow.cn/v1/chat/completions",
-		}
-		fmt.Printf("✅ planner配置完成\n")
+		planner = NewTaiFuLearningPlanner(NewLLMProviderFromEnv())
+		fmt.Printf("✅ planner配置完成，provider: %s\n", planner.Provider.Name())
 	}
 }
 
+// 替换全局 planner 使用的 provider（本地开发或测试时注入 fake）
+func SetLLMProvider(provider LLMProvider) {
+	planner = NewTaiFuLearningPlanner(provider)
+}
+
 // 检测输入是否为有效的学习目标
 func isValidLearningGoal(input string) bool {
 	// 去除空格
@@ -113,10 +111,8 @@ func isRepeatingChars(s string) bool {
 	return true
 }
 
-func GenerateLearningPlan(c *gin.Context) {
-	// 确保 planner 已初始化
-	initPlanner()
-	id, _ := getCurrentUserID(c)
+// 绑定并校验学习计划请求，失败时直接写回错误响应
+func bindLearningPlanRequest(c *gin.Context) (LearningPlanRequest, bool) {
 	var req LearningPlanRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		fmt.Printf("❌ 请求格式错误: %v\n", err)
@@ -124,7 +120,7 @@ func GenerateLearningPlan(c *gin.Context) {
 			Success: false,
 			Error:   fmt.Sprintf("请求格式错误: %v", err),
 		})
-		return
+		return req, false
 	}
 
 	// 输入合法性检测
@@ -134,14 +130,57 @@ func GenerateLearningPlan(c *gin.Context) {
 			Success: false,
 			Error:   "输入内容无效，请输入有意义的学习目标（如：学习Python编程、提升英语口语等）",
 		})
+		return req, false
+	}
+
+	// 内容审核（学习目标和个人背景）
+	id, _ := getCurrentUserID(c)
+	for _, field := range []*string{&req.Flag, &req.Background} {
+		result := moderateText(c.Request.Context(), sceneAIGoal, id, *field)
+		if result.Action == ModerationBlock {
+			c.JSON(http.StatusBadRequest, LearningPlanResponse{
+				Success: false,
+				Error:   "输入内容包含违规信息，请修改后再试",
+			})
+			return req, false
+		}
+		*field = result.Text
+	}
+	return req, true
+}
+
+func GenerateLearningPlan(c *gin.Context) {
+	// 确保 planner 已初始化
+	initPlanner()
+	id, _ := getCurrentUserID(c)
+	req, ok := bindLearningPlanRequest(c)
+	if !ok {
+		return
+	}
+
+	// 相同请求直接返回缓存结果，不消耗配额
+	if cached, hit := serveCachedAIPlan(id, req); hit {
+		c.JSON(http.StatusOK, LearningPlanResponse{
+			Success: true,
+			Flag:    cached.Flag,
+			Count:   cached.Difficulty,
+			Plan:    cached.Plan,
+			PlanID:  cached.ID,
+			Cached:  true,
+		})
+		return
+	}
+	record := newAIPlanRecord(id, req)
+	if !reserveAIQuota(c, record) {
 		return
 	}
 
 	fmt.Printf("📝 收到学习计划请求: %+v\n", req)
 
 	// 生成学习计划
-	flag, plan, difficulty, err := planner.GenerateLearningPlan(req)
+	flag, plan, difficulty, err := planner.GenerateLearningPlan(c.Request.Context(), req)
 	if err != nil {
+		releaseAIQuota(record)
 		fmt.Printf("❌ 生成学习计划失败: %v\n", err)
 		c.JSON(http.StatusInternalServerError, LearningPlanResponse{
 			Success: false,
@@ -150,7 +189,8 @@ func GenerateLearningPlan(c *gin.Context) {
 		return
 	}
 
-	// 埋点：生成学习计划（不添加Flag，让前端决定）
+	// 保存到历史（不添加Flag，让前端决定）
+	saveGeneratedAIPlan(record, req, flag, plan, difficulty)
 	repository.AddTrackPointToDB(id, "生成学习计划")
 	fmt.Printf("✅ 成功生成学习计划，难度: %d，计划长度: %d\n", difficulty, len(plan))
 	c.JSON(http.StatusOK, LearningPlanResponse{
@@ -158,7 +198,75 @@ func GenerateLearningPlan(c *gin.Context) {
 		Flag:    flag,
 		Count:   difficulty,
 		Plan:    plan,
+		PlanID:  record.ID,
+	})
+}
+
+// 流式生成学习计划（SSE）
+// 事件：token（增量文本）、done（解析后的 flag/difficulty/plan）、error（失败原因）
+func GenerateLearningPlanStream(c *gin.Context) {
+	initPlanner()
+	id, _ := getCurrentUserID(c)
+	req, ok := bindLearningPlanRequest(c)
+	if !ok {
+		return
+	}
+
+	cached, hit := serveCachedAIPlan(id, req)
+	record := newAIPlanRecord(id, req)
+	if !hit && !reserveAIQuota(c, record) {
+		return
+	}
+
+	fmt.Printf("📝 收到流式学习计划请求: %+v\n", req)
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no") // 关闭 Nginx 缓冲
+	c.Status(http.StatusOK)
+
+	// 命中缓存时直接发送结果
+	if hit {
+		c.SSEvent("done", LearningPlanResponse{
+			Success: true,
+			Flag:    cached.Flag,
+			Count:   cached.Difficulty,
+			Plan:    cached.Plan,
+			PlanID:  cached.ID,
+			Cached:  true,
+		})
+		c.Writer.Flush()
+		return
+	}
+
+	flag, plan, difficulty, err := planner.GenerateLearningPlanStream(c.Request.Context(), req, func(delta string) error {
+		c.SSEvent("token", gin.H{"content": delta})
+		c.Writer.Flush()
+		return c.Request.Context().Err()
+	})
+	if err != nil {
+		releaseAIQuota(record)
+		fmt.Printf("❌ 流式生成学习计划失败: %v\n", err)
+		c.SSEvent("error", LearningPlanResponse{
+			Success: false,
+			Error:   fmt.Sprintf("生成学习计划失败: %v", err),
+		})
+		c.Writer.Flush()
+		return
+	}
+
+	saveGeneratedAIPlan(record, req, flag, plan, difficulty)
+	repository.AddTrackPointToDB(id, "流式生成学习计划")
+	fmt.Printf("✅ 成功流式生成学习计划，难度: %d，计划长度: %d\n", difficulty, len(plan))
+	c.SSEvent("done", LearningPlanResponse{
+		Success: true,
+		Flag:    flag,
+		Count:   difficulty,
+		Plan:    plan,
+		PlanID:  record.ID,
 	})
+	c.Writer.Flush()
 }
 
 // CORS中间件
@@ -177,10 +285,8 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
-// 生成学习计划的核心方法
-func (p *TaiFuLearningPlanner) GenerateLearningPlan(req LearningPlanRequest) (string, string, int, error) {
-	// 构建系统提示词
-	systemPrompt := `你是"太傅AI学习计划生成器"，专门为用户制定科学、合理、可执行的学习路径。
+// 学习计划系统提示词
+const learningPlanSystemPrompt = `你是"太傅AI学习计划生成器"，专门为用户制定科学、合理、可执行的学习路径。
 
 【核心要求】
 1. 必须返回标准JSON格式（不要包含markdown代码块标记）
@@ -282,6 +388,8 @@ func (p *TaiFuLearningPlanner) GenerateLearningPlan(req LearningPlanRequest) (st
 ✅ plan第一行必须是：【目标概述】...
 ✅ 严格按照标准示例格式`
 
+// 构建学习计划的模型请求
+func buildLearningPlanLLMRequest(req LearningPlanRequest) LLMRequest {
 	// 构建用户提示词
 	userPrompt := fmt.Sprintf("学习目标: %s\n", req.Flag)
 	if req.Background != "" {
@@ -292,16 +400,40 @@ func (p *TaiFuLearningPlanner) GenerateLearningPlan(req LearningPlanRequest) (st
 	}
 	userPrompt += "\n请根据以上信息生成学习计划,返回标准JSON格式。"
 
-	fmt.Printf("📋 系统提示: %s\n", systemPrompt)
 	fmt.Printf("📋 用户提示: %s\n", userPrompt)
 
+	return LLMRequest{
+		Task: taskLearningPlan,
+		Messages: []LLMMessage{
+			{Role: "system", Content: learningPlanSystemPrompt},
+			{Role: "user", Content: userPrompt},
+		},
+	}
+}
+
+// 生成学习计划的核心方法
+func (p *TaiFuLearningPlanner) GenerateLearningPlan(ctx context.Context, req LearningPlanRequest) (string, string, int, error) {
 	// 调用AI
-	response, err := p.callOpenAI(systemPrompt, userPrompt)
+	response, err := p.Provider.ChatCompletion(ctx, buildLearningPlanLLMRequest(req))
 	if err != nil {
 		fmt.Printf("❌ AI调用失败: %v\n", err)
 		return "", "", 0, err
 	}
+	return p.finishLearningPlan(req, response)
+}
+
+// 流式生成学习计划，增量文本通过 onDelta 回调，结束后返回解析结果
+func (p *TaiFuLearningPlanner) GenerateLearningPlanStream(ctx context.Context, req LearningPlanRequest, onDelta LLMDeltaHandler) (string, string, int, error) {
+	response, err := p.Provider.ChatCompletionStream(ctx, buildLearningPlanLLMRequest(req), onDelta)
+	if err != nil {
+		fmt.Printf("❌ AI流式调用失败: %v\n", err)
+		return "", "", 0, err
+	}
+	return p.finishLearningPlan(req, response)
+}
 
+// 解析并校验AI返回的完整文本
+func (p *TaiFuLearningPlanner) finishLearningPlan(req LearningPlanRequest, response string) (string, string, int, error) {
 	fmt.Printf("✅ AI返回成功,原始响应长度: %d\n", len(response))
 
 	// 解析AI响应
@@ -329,7 +461,7 @@ func (p *TaiFuLearningPlanner) GenerateLearningPlan(req LearningPlanRequest) (st
 // 解析AI响应
 func (p *TaiFuLearningPlanner) parseAIResponse(response string) (string, string, int, error) {
 	// 清理响应（移除可能的markdown代码块标记）
-	cleanResponse := response
+	cleanResponse := strings.TrimSpace(response)
 	cleanResponse = strings.TrimPrefix(cleanResponse, "```json")
 	cleanResponse = strings.TrimPrefix(cleanResponse, "```")
 	cleanResponse = strings.TrimSuffix(cleanResponse, "```")
@@ -370,128 +502,3 @@ func min(a, b int) int {
 	}
 	return b
 }
-
-// 调用OpenAI API
-func (p *TaiFuLearningPlanner) callOpenAI(systemPrompt, userPrompt string) (string, error) {
-	// 检查API密钥
-	fmt.Printf("🔍 检查API密钥...\n")
-	fmt.Printf("API密钥: %s\n", p.APIKey)
-	fmt.Printf("BaseURL: %s\n", p.BaseURL)
-
-	if p.APIKey == "" {
-		fmt.Printf("❌ API密钥为空\n")
-		return "", fmt.Errorf("❌ API密钥未配置，请检查环境变量 APIKEY")
-	}
-
-	// 准备请求数据 - 使用标准格式
-	type Message struct {
-		Role    string `json:"role"`
-		Content string `json:"content"`
-	}
-
-	requestData := map[string]interface{}{
-		"model": "Qwen/Qwen2.5-7B-Instruct", // 使用更稳定的模型
-		"messages": []Message{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: userPrompt},
-		},
-		"max_tokens":  4000, // 增加token数，确保完整输出
-		"temperature": 0.7,
-		"stream":      false,
-	}
-
-	requestBody, err := json.Marshal(requestData)
-	if err != nil {
-		fmt.Printf("❌ 序列化请求失败: %v\n", err)
-		return "", fmt.Errorf("序列化请求失败: %v", err)
-	}
-
-	fmt.Printf("📤 发送请求到: %s\n", p.BaseURL)
-	fmt.Printf("📄 请求体: %s\n", string(requestBody))
-
-	req, err := http.NewRequest("POST", p.BaseURL, bytes.NewBuffer(requestBody))
-	if err != nil {
-		fmt.Printf("❌ 创建请求失败: %v\n", err)
-		return "", fmt.Errorf("创建请求失败: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.APIKey)
-
-	// 调试：打印 Authorization header
-	fmt.Printf("📍 Authorization Header: %s\n", req.Header.Get("Authorization"))
-
-	// 创建带 60 秒超时的客户端（给 AI 充足时间响应）
-	client := &http.Client{Timeout: 60 * time.Second}
-	fmt.Printf("⏱️ 开始调用 SiliconFlow API...\n")
-	resp, err := client.Do(req)
-	if err != nil {
-		fmt.Printf("❌ 发送请求失败: %v\n", err)
-		return "", fmt.Errorf("❌ 发送请求失败: %v", err)
-	}
-	defer resp.Body.Close()
-
-	fmt.Printf("📥 收到响应，状态码: %d\n", resp.StatusCode)
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("❌ 读取响应失败: %v\n", err)
-		return "", fmt.Errorf("读取响应失败: %v", err)
-	}
-
-	// ✅ 打印原始响应
-	fmt.Printf("📝 API原始响应: %s\n", string(body))
-
-	var response struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-		Data    struct {
-			Choices []struct {
-				Message struct {
-					Content string `json:"content"`
-				} `json:"message"`
-			} `json:"choices"`
-		} `json:"data"`
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
-		Error struct {
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-
-	if err := json.Unmarshal(body, &response); err != nil {
-		fmt.Printf("❌ 解析响应失败: %v\n", err)
-		fmt.Printf("原始响应内容: %s\n", string(body))
-		return "", fmt.Errorf("解析响应失败: %v", err)
-	}
-
-	// 检查是否有错误码
-	if response.Code != 0 && response.Code != 200 {
-		fmt.Printf("❌ SiliconFlow API 返回错误码: %d, 消息: %s\n", response.Code, response.Message)
-		return "", fmt.Errorf("❌ SiliconFlow API 错误: %s (错误码: %d)", response.Message, response.Code)
-	}
-
-	if response.Error.Message != "" {
-		fmt.Printf("❌ SiliconFlow API 错误字段: %s\n", response.Error.Message)
-		return "", fmt.Errorf("❌ SiliconFlow API 错误: %s", response.Error.Message)
-	}
-
-	// 优先检查 Data 中的 Choices（某些版本 API）
-	if len(response.Data.Choices) > 0 {
-		content := response.Data.Choices[0].Message.Content
-		fmt.Printf("✅ AI 返回内容 (从 data): %s\n", content)
-		return content, nil
-	}
-
-	// 备选：检查顶级的 Choices
-	if len(response.Choices) > 0 {
-		content := response.Choices[0].Message.Content
-		fmt.Printf("✅ AI 返回内容 (从 choices): %s\n", content)
-		return content, nil
-	}
-
-	fmt.Printf("❌ 未收到有效的 AI 响应，响应结构: %+v\n", response)
-	return "", fmt.Errorf("未收到有效的 AI 响应")
-}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseAIResponse(t *testing.T) {
+	p := NewTaiFuLearningPlanner(NewFakeLLMProvider())
+	tests := []struct {
+		name       string
+		response   string
+		flag       string
+		plan       string
+		difficulty int
+	}{
+		{
+			name:       "plain json",
+			response:   `{"flag":"学Go","difficulty":150,"plan":"【目标概述】掌握Go"}`,
+			flag:       "学Go",
+			plan:       "【目标概述】掌握Go",
+			difficulty: 150,
+		},
+		{
+			name:       "json fence",
+			response:   "```json\n{\"flag\":\"学Go\",\"difficulty\":50,\"plan\":\"计划\"}\n```",
+			flag:       "学Go",
+			plan:       "计划",
+			difficulty: 50,
+		},
+		{
+			name:       "bare fence with surrounding whitespace",
+			response:   "\n  ```\n{\"flag\":\"学Go\",\"difficulty\":200,\"plan\":\"计划\"}\n```\n",
+			flag:       "学Go",
+			plan:       "计划",
+			difficulty: 200,
+		},
+		{
+			name:     "malformed json falls back to raw text",
+			response: "阶段一：基础入门\n1. 阅读资料",
+			plan:     "阶段一：基础入门\n1. 阅读资料",
+		},
+		{
+			name:     "truncated json falls back to raw text",
+			response: "```json\n{\"flag\":\"学Go\",\"plan\":\"计划",
+			plan:     "{\"flag\":\"学Go\",\"plan\":\"计划",
+		},
+		{
+			name:       "empty plan keeps raw response",
+			response:   `{"flag":"学Go","difficulty":50,"plan":""}`,
+			flag:       "学Go",
+			plan:       `{"flag":"学Go","difficulty":50,"plan":""}`,
+			difficulty: 50,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag, plan, difficulty, err := p.parseAIResponse(tt.response)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if flag != tt.flag || plan != tt.plan || difficulty != tt.difficulty {
+				t.Errorf("got (%q, %q, %d), want (%q, %q, %d)", flag, plan, difficulty, tt.flag, tt.plan, tt.difficulty)
+			}
+		})
+	}
+}
+
+func TestGenerateLearningPlanWithFakeProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   string
+		req        LearningPlanRequest
+		flag       string
+		plan       string
+		difficulty int
+		wantErr    bool
+	}{
+		{
+			name:       "valid json",
+			response:   `{"flag":"学Go","difficulty":150,"plan":"【目标概述】掌握Go"}`,
+			req:        LearningPlanRequest{Flag: "Go"},
+			flag:       "学Go",
+			plan:       "【目标概述】掌握Go",
+			difficulty: 150,
+		},
+		{
+			name:       "fenced json",
+			response:   "```json\n{\"flag\":\"学Go\",\"difficulty\":200,\"plan\":\"计划\"}\n```",
+			req:        LearningPlanRequest{Flag: "Go"},
+			flag:       "学Go",
+			plan:       "计划",
+			difficulty: 200,
+		},
+		{
+			name:       "missing difficulty uses requested difficulty",
+			response:   `{"flag":"学Go","plan":"计划"}`,
+			req:        LearningPlanRequest{Flag: "Go", Difficulty: 50},
+			flag:       "学Go",
+			plan:       "计划",
+			difficulty: 50,
+		},
+		{
+			name:       "malformed json uses raw text and default difficulty",
+			response:   "先学语法，再做项目",
+			req:        LearningPlanRequest{Flag: "Go"},
+			plan:       "先学语法，再做项目",
+			difficulty: 150,
+		},
+		{
+			name:     "empty response is an error",
+			response: "   ",
+			req:      LearningPlanRequest{Flag: "Go"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewFakeLLMProvider(tt.response)
+			p := NewTaiFuLearningPlanner(provider)
+			flag, plan, difficulty, err := p.GenerateLearningPlan(context.Background(), tt.req)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got plan %q", plan)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if flag != tt.flag || plan != tt.plan || difficulty != tt.difficulty {
+				t.Errorf("got (%q, %q, %d), want (%q, %q, %d)", flag, plan, difficulty, tt.flag, tt.plan, tt.difficulty)
+			}
+			if len(provider.Calls) != 1 {
+				t.Fatalf("provider called %d times, want 1", len(provider.Calls))
+			}
+			call := provider.Calls[0]
+			if call.Task != taskLearningPlan {
+				t.Errorf("task = %q, want %q", call.Task, taskLearningPlan)
+			}
+			if !strings.Contains(lastUserContent(call.Messages), "学习目标: "+tt.req.Flag) {
+				t.Errorf("user prompt does not contain the goal: %q", lastUserContent(call.Messages))
+			}
+		})
+	}
+}
+
+func TestGenerateLearningPlanStreamWithFakeProvider(t *testing.T) {
+	response := "```json\n{\"flag\":\"学Go\",\"difficulty\":150,\"plan\":\"【目标概述】从基础到实践系统掌握Go语言\"}\n```"
+	p := NewTaiFuLearningPlanner(NewFakeLLMProvider(response))
+
+	var streamed strings.Builder
+	flag, plan, difficulty, err := p.GenerateLearningPlanStream(context.Background(), LearningPlanRequest{Flag: "Go"}, func(delta string) error {
+		streamed.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if streamed.String() != response {
+		t.Errorf("streamed %q, want %q", streamed.String(), response)
+	}
+	if flag != "学Go" || plan != "【目标概述】从基础到实践系统掌握Go语言" || difficulty != 150 {
+		t.Errorf("got (%q, %q, %d)", flag, plan, difficulty)
+	}
+}
+
+func TestFakeProviderDefaultLearningPlan(t *testing.T) {
+	p := NewTaiFuLearningPlanner(NewFakeLLMProvider())
+	flag, plan, difficulty, err := p.GenerateLearningPlan(context.Background(), LearningPlanRequest{Flag: "线性代数"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if flag != "线性代数学习计划" {
+		t.Errorf("flag = %q", flag)
+	}
+	if !strings.HasPrefix(plan, "【目标概述】") || !strings.Contains(plan, "（每日完成：1次）") {
+		t.Errorf("plan does not follow the expected format: %q", plan)
+	}
+	if difficulty != 100 {
+		t.Errorf("difficulty = %d, want 100", difficulty)
+	}
+}
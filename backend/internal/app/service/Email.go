@@ -107,7 +107,11 @@ func LoginWithOTP() gin.HandlerFunc {
 			c.JSON(400, gin.H{"error": "该邮箱尚未注册,请先注册账号"})
 			utils.LogError("验证码登录失败-用户不存在", logrus.Fields{"user_email": req.Email})
 			return
-		} // 生成JWT token
+		}
+		if rejectIfSuspended(c, user.SuspendedUntil) {
+			return
+		}
+		// 生成JWT token
 		token, err := utils.GenerateToken(user.ID, user.Name, user.Email)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "生成token失败,请重新再试..."})
@@ -1,6 +1,10 @@
 package service
 
 import (
+	"math"
+	"sort"
+	"time"
+
 	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
 	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
 	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
@@ -8,99 +12,187 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func InitAchievementTable(user model.User) model.User {
-	achievements := []model.Achievement{
-		{UserID: user.ID, Name: "首次完成", Description: "第一次设置flag", HadDone: false},
-		{UserID: user.ID, Name: "7天连卡", Description: "连续打卡7天", HadDone: false},
-		{UserID: user.ID, Name: "任务大师", Description: "完成50个flag", HadDone: false},
-		{UserID: user.ID, Name: "目标达成", Description: "积分超过1000", HadDone: false},
-		{UserID: user.ID, Name: "学习之星", Description: "累计学习时间超过1000分钟", HadDone: false},
-		{UserID: user.ID, Name: "坚持不懈", Description: "累计打卡30天", HadDone: false},
-		{UserID: user.ID, Name: "效率达人", Description: "单日完成5个flag", HadDone: false},
-		{UserID: user.ID, Name: "专注大师", Description: "单日学习时长超过4小时", HadDone: false},
-		{UserID: user.ID, Name: "早起鸟", Description: "早上6点前打卡5次", HadDone: false},
-		{UserID: user.ID, Name: "夜猫子", Description: "晚上10点后打卡5次", HadDone: false},
-		{UserID: user.ID, Name: "完美主义", Description: "连续10次满分完成flag", HadDone: false},
-		{UserID: user.ID, Name: "全能选手", Description: "完成5种不同标签的flag", HadDone: false},
-		{UserID: user.ID, Name: "学习狂人", Description: "累计学习时间超过5000分钟", HadDone: false},
-		{UserID: user.ID, Name: "社交达人", Description: "发布10条动态", HadDone: false},
-		{UserID: user.ID, Name: "时间管理者", Description: "连续30天完成至少1个flag", HadDone: false},
-		{UserID: user.ID, Name: "成就收集者", Description: "解锁10个徽章", HadDone: false},
+// 指标的一条原始记录
+type metricSample struct {
+	At    time.Time
+	Value int
+	Key   int // distinct 规则使用（如flag标签）
+}
+
+// 一次成就评估中按需加载并复用的用户数据
+type achievementContext struct {
+	user     model.User
+	samples  map[string][]metricSample
+	unlocked int // 已解锁的成就数
+}
+
+// 会影响成就进度的事件
+var achievementTriggerEvents = []string{
+	EventFlagCreated,
+	EventFlagCompleted,
+	EventDaka,
+	EventLearnTimeRecorded,
+	EventPostCreated,
+	EventPointsAwarded,
+}
+
+func init() {
+	for _, eventType := range achievementTriggerEvents {
+		SubscribeEvent(eventType, onAchievementTrigger)
+	}
+	SubscribeEvent(EventAchievementUnlocked, notifyAchievementUnlocked)
+	SubscribeEvent(EventAchievementUnlocked, notifyAchievementNotification)
+}
+
+// 收到相关事件后重新评估该用户的成就
+func onAchievementTrigger(event DomainEvent) {
+	if _, err := EvaluateAchievements(event.UserID); err != nil {
+		utils.LogError("评估用户成就失败", logrus.Fields{"user_id": event.UserID, "event": event.Type, "error": err.Error()})
 	}
-	user.Achievements = achievements
-	utils.LogInfo("初始化用户成就表成功", nil)
-	return user
+}
+
+// 通过 WebSocket 推送成就解锁通知（用户不在线时忽略）
+func notifyAchievementUnlocked(event DomainEvent) {
+	manager.SendToUser(event.UserID, gin.H{
+		"type": "achievement_unlocked",
+		"data": event.Payload,
+	})
 }
 
 // 调取用户成就
 func GetUserAchievement() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, _ := getCurrentUserID(c)
-		AchievementCheckAll(id)
-		achievements, err := repository.GetAchievementsByUserID(id)
+		achievements, created, err := ensureUserAchievements(id)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "获取用户成就失败,请重新再试..."})
 			utils.LogError("获取用户成就失败", nil)
 			return
 		}
-
-		// 补全缺失的成就
-		allAchievementList := []struct{ Name, Description string }{
-			{"首次完成", "第一次设置flag"},
-			{"7天连卡", "连续打卡7天"},
-			{"任务大师", "完成50个flag"},
-			{"目标达成", "积分超过1000"},
-			{"学习之星", "累计学习时间超过1000分钟"},
-			{"坚持不懈", "累计打卡30天"},
-			{"效率达人", "单日完成5个flag"},
-			{"专注大师", "单日学习时长超过4小时"},
-			{"早起鸟", "早上6点前打卡5次"},
-			{"夜猫子", "晚上10点后打卡5次"},
-			{"完美主义", "连续10次满分完成flag"},
-			{"全能选手", "完成5种不同标签的flag"},
-			{"学习狂人", "累计学习时间超过5000分钟"},
-			{"社交达人", "发布10条动态"},
-			{"时间管理者", "连续30天完成至少1个flag"},
-			{"成就收集者", "解锁10个徽章"},
-		}
-		existMap := make(map[string]bool)
-		for _, a := range achievements {
-			existMap[a.Name] = true
-		}
-		for _, item := range allAchievementList {
-			if !existMap[item.Name] {
-				// 插入缺失的成就
-				_ = repository.InsertAchievement(id, item.Name, item.Description)
+		// 成就由事件驱动评估；首次补建记录时同步评估一次，保证进度不是空的
+		if created > 0 {
+			if _, err := EvaluateAchievements(id); err != nil {
+				utils.LogError("评估用户成就失败", logrus.Fields{"user_id": id, "error": err.Error()})
 			}
+			achievements, _, _ = ensureUserAchievements(id)
+		}
+
+		status := c.DefaultQuery("status", "all") // all / locked / unlocked
+		if status != "all" && status != "locked" && status != "unlocked" {
+			c.JSON(400, gin.H{"error": "status 只能是 all/locked/unlocked"})
+			return
+		}
+		sortBy := c.DefaultQuery("sort", "default") // default / progress / rarity / unlocked_at
+		desc := c.DefaultQuery("order", "desc") != "asc"
+
+		// 稀有度：已解锁人数占总用户数的百分比
+		unlocks, err := repository.CountAchievementUnlocks()
+		if err != nil {
+			utils.LogError("统计成就解锁人数失败", logrus.Fields{"error": err.Error()})
 		}
-		// 重新查询补全后的成就
-		achievements, _ = repository.GetAchievementsByUserID(id)
+		totalUsers, _ := repository.CountUsers()
 
 		// 转换为前端期望的格式
+		type TierResponse struct {
+			Name    string `json:"name"`
+			Target  int    `json:"target"`
+			Points  int    `json:"points"`
+			Reached bool   `json:"reached"`
+		}
 		type AchievementResponse struct {
-			ID          uint   `json:"id"`
-			Name        string `json:"name"`
-			Description string `json:"description"`
-			IsUnlocked  bool   `json:"isUnlocked"`
+			ID          uint           `json:"id"`
+			Name        string         `json:"name"`
+			Description string         `json:"description"`
+			Icon        string         `json:"icon"`
+			Points      int            `json:"points"`
+			IsUnlocked  bool           `json:"isUnlocked"`
+			Current     int            `json:"current"`         // 当前进度
+			Target      int            `json:"target"`          // 下一等级的目标值（已满级时为最高等级目标）
+			Percent     float64        `json:"percent"`         // 完成百分比
+			UnlockedAt  *time.Time     `json:"unlockedAt"`      // 解锁时间
+			Rarity      float64        `json:"rarity"`          // 拥有该成就的用户百分比
+			Tier        int            `json:"tier"`            // 已达到的等级，0 表示未解锁
+			TierName    string         `json:"tierName"`        // 铜/银/金，单级成就为空
+			Tiers       []TierResponse `json:"tiers,omitempty"` // 分级成就的各等级
+			Hidden      bool           `json:"hidden"`          // 是否为隐藏成就
 		}
-
-		// 去重：使用map确保成就名称唯一
-		uniqueAchievements := make(map[string]model.Achievement)
-		for _, a := range achievements {
-			// 如果成就名称已存在，保留ID较小的记录（通常是较早创建的）
-			if existing, exists := uniqueAchievements[a.Name]; !exists || a.ID < existing.ID {
-				uniqueAchievements[a.Name] = a
+		result := make([]AchievementResponse, 0, len(model.AchievementDefs))
+		for _, def := range model.AchievementDefs {
+			a := achievements[def.Name]
+			if (status == "locked" && a.HadDone) || (status == "unlocked" && !a.HadDone) {
+				continue
+			}
+			levels := def.Levels()
+			tier := achievementTier(a)
+			item := AchievementResponse{
+				ID:         a.ID,
+				Name:       def.Name,
+				Icon:       def.Icon,
+				IsUnlocked: a.HadDone,
+				Tier:       tier,
+				Hidden:     def.Hidden,
+			}
+			for i, level := range levels {
+				item.Points += level.Points
+				if len(def.Tiers) > 0 {
+					item.Tiers = append(item.Tiers, TierResponse{Name: level.Name, Target: level.Threshold, Points: level.Points, Reached: i < tier})
+				}
+			}
+			if tier > 0 {
+				item.TierName = levels[tier-1].Name
+			}
+			// 目标为下一等级，已满级时为最高等级
+			item.Target = levels[min(tier, len(levels)-1)].Threshold
+			item.Current = min(a.Progress, item.Target)
+			if tier == len(levels) {
+				item.Current = item.Target
+			}
+			if item.Target > 0 {
+				item.Percent = roundTo1(float64(item.Current) * 100 / float64(item.Target))
+			}
+			if a.HadDone && !a.GotTime.IsZero() {
+				gotTime := a.GotTime
+				item.UnlockedAt = &gotTime
+			}
+			item.Description = def.Description
+			// 隐藏成就解锁前不展示描述和进度
+			if def.Hidden && !a.HadDone {
+				item.Description = "隐藏成就，解锁后可见"
+				item.Current, item.Target, item.Percent = 0, 0, 0
 			}
+			if totalUsers > 0 {
+				item.Rarity = roundTo1(float64(unlocks[def.Name]) * 100 / float64(totalUsers))
+			}
+			result = append(result, item)
 		}
 
-		// 转换为切片
-		result := make([]AchievementResponse, 0, len(uniqueAchievements))
-		for _, a := range uniqueAchievements {
-			result = append(result, AchievementResponse{
-				ID:          a.ID,
-				Name:        a.Name,
-				Description: a.Description,
-				IsUnlocked:  a.HadDone,
+		// 未指定排序时保持注册表顺序
+		switch sortBy {
+		case "progress":
+			sort.SliceStable(result, func(i, j int) bool {
+				if desc {
+					return result[i].Percent > result[j].Percent
+				}
+				return result[i].Percent < result[j].Percent
+			})
+		case "rarity":
+			sort.SliceStable(result, func(i, j int) bool {
+				if desc {
+					return result[i].Rarity > result[j].Rarity
+				}
+				return result[i].Rarity < result[j].Rarity
+			})
+		case "unlocked_at":
+			// 未解锁的始终排在最后
+			sort.SliceStable(result, func(i, j int) bool {
+				ti, tj := result[i].UnlockedAt, result[j].UnlockedAt
+				if ti == nil || tj == nil {
+					return ti != nil && tj == nil
+				}
+				if desc {
+					return ti.After(*tj)
+				}
+				return ti.Before(*tj)
 			})
 		}
 
@@ -109,309 +201,258 @@ func GetUserAchievement() gin.HandlerFunc {
 	}
 }
 
-// 成就检测合集
-func AchievementCheckAll(userID uint) {
-	AchievementCheckFirstFlag(userID)            // 首次完成
-	AchievementCheckFirstKeepFlag(userID)        // 7天连卡
-	AchievementCheckLearn50Days(userID)          // 任务大师
-	AchievementCheckCompleteFlag100Times(userID) // 目标达成
-	AchievementCheckLearn1000Min(userID)         // 学习之星
-	AchievementCheckDaka30Days(userID)           // 坚持不懈
-	AchievementCheckDailyFlag5(userID)           // 效率达人
-	AchievementCheckDailyLearn4Hours(userID)     // 专注大师
-	AchievementCheckEarlyBird(userID)            // 早起鸟
-	AchievementCheckNightOwl(userID)             // 夜猫子
-	AchievementCheckPerfectStreak(userID)        // 完美主义
-	AchievementCheckAllRounder(userID)           // 全能选手
-	AchievementCheckLearn5000Min(userID)         // 学习狂人
-	AchievementCheckPost10Times(userID)          // 社交达人
-	AchievementCheckDailyFlag30Days(userID)      // 时间管理者
-	AchievementCheckUnlock10Badges(userID)       // 成就收集者
-}
-
-// 成就检测：首次完成
-func AchievementCheckFirstFlag(userID uint) {
-	user, err := repository.GetUserByID(userID)
-	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
-	}
-	if len(user.Flags) >= 1 {
-		err := repository.UpdateAchievementHadDone(userID, "首次完成")
-		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "首次完成"})
-			return
-		}
+// 成就记录当前的等级；分级上线前已解锁的记录视为达到第一级
+func achievementTier(row model.Achievement) int {
+	if row.HadDone && row.Tier == 0 {
+		return 1
 	}
+	return row.Tier
 }
 
-// 成就检测：7天连卡
-func AchievementCheckFirstKeepFlag(userID uint) {
-	user, err := repository.GetUserByID(userID)
-	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
-	}
-	// TODO: 实现连续打卡7天的检测逻辑
-	if user.Daka >= 7 {
-		err := repository.UpdateAchievementHadDone(userID, "7天连卡")
-		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "7天连卡"})
-			return
-		}
-	}
+// 保留一位小数
+func roundTo1(v float64) float64 {
+	return math.Round(v*10) / 10
 }
 
-// 成就检测：任务大师
-func AchievementCheckLearn50Days(userID uint) {
-	user, err := repository.GetUserByID(userID)
+// 获取用户成就记录，缺失的按注册表补建（返回补建数量）；同名重复记录保留ID最小的一条
+func ensureUserAchievements(userID uint) (map[string]model.Achievement, int, error) {
+	achievements, err := repository.GetAchievementsByUserID(userID)
 	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
+		return nil, 0, err
 	}
-	if user.FlagNumber >= 50 {
-		err := repository.UpdateAchievementHadDone(userID, "任务大师")
-		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "任务大师"})
-			return
+	created := 0
+	byName := make(map[string]model.Achievement, len(achievements))
+	for _, a := range achievements {
+		if existing, ok := byName[a.Name]; !ok || a.ID < existing.ID {
+			byName[a.Name] = a
 		}
 	}
-}
-
-// 成就检测：目标达成
-func AchievementCheckCompleteFlag100Times(userID uint) {
-	user, err := repository.GetUserByID(userID)
-	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
-	}
-	if user.Count >= 1000 {
-		err := repository.UpdateAchievementHadDone(userID, "目标达成")
+	for _, def := range model.AchievementDefs {
+		if _, ok := byName[def.Name]; ok {
+			continue
+		}
+		a := model.Achievement{UserID: userID, Name: def.Name, Description: def.Description}
+		if err := repository.AddAchievementToDB(a); err != nil {
+			return nil, created, err
+		}
+		row, err := repository.GetAchievementByName(userID, def.Name)
 		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "目标达成"})
-			return
+			return nil, created, err
 		}
+		byName[def.Name] = row
+		created++
 	}
+	return byName, created, nil
 }
 
-// 成就检测：学习之星
-func AchievementCheckLearn1000Min(userID uint) {
-	user, err := repository.GetUserByID(userID)
+// 按注册表评估用户所有成就：更新进度、解锁并发放奖励积分，返回本次新解锁的成就
+func EvaluateAchievements(userID uint) ([]model.AchievementDef, error) {
+	rows, _, err := ensureUserAchievements(userID)
 	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
-	}
-	var totalLearnTime int
-	for _, learnTime := range user.LearnTimes {
-		totalLearnTime += learnTime.Duration
-	}
-	if totalLearnTime >= 1000 {
-		err := repository.UpdateAchievementHadDone(userID, "学习之星")
-		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "学习之星"})
-			return
-		}
+		return nil, err
 	}
-}
-
-// 成就检测：坚持不懈（累计打卡30天）
-func AchievementCheckDaka30Days(userID uint) {
 	user, err := repository.GetUserByID(userID)
 	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
+		return nil, err
 	}
-	if user.Daka >= 30 {
-		err := repository.UpdateAchievementHadDone(userID, "坚持不懈")
-		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "坚持不懈"})
-			return
+	ctx := &achievementContext{user: user, samples: make(map[string][]metricSample)}
+	// 只统计注册表中的成就，已合并下线的旧记录（如学习狂人）不计入
+	for name, row := range rows {
+		if _, ok := model.FindAchievementDef(name); ok && row.HadDone {
+			ctx.unlocked++
 		}
 	}
-}
 
-// 成就检测：效率达人（单日完成5个flag）
-func AchievementCheckDailyFlag5(userID uint) {
-	user, err := repository.GetUserByID(userID)
-	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
-	}
-	// TODO: 实现单日完成flag数量统计
-	// 暂时使用总完成数作为判断条件
-	if user.FlagNumber >= 5 {
-		err := repository.UpdateAchievementHadDone(userID, "效率达人")
+	// 依赖“已解锁成就数”的定义放在最后评估
+	defs := make([]model.AchievementDef, len(model.AchievementDefs))
+	copy(defs, model.AchievementDefs)
+	sort.SliceStable(defs, func(i, j int) bool {
+		return defs[i].Metric != model.AchievementMetricAchievements && defs[j].Metric == model.AchievementMetricAchievements
+	})
+
+	var newlyUnlocked []model.AchievementDef
+	for _, def := range defs {
+		row := rows[def.Name]
+		value, err := ctx.value(def)
 		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "效率达人"})
-			return
+			utils.LogError("计算成就进度失败", logrus.Fields{"user_id": userID, "achievement": def.Name, "error": err.Error()})
+			continue
 		}
-	}
-}
-
-// 成就检测：专注大师（单日学习时长超过4小时=240分钟）
-func AchievementCheckDailyLearn4Hours(userID uint) {
-	user, err := repository.GetUserByID(userID)
-	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
-	}
-	// TODO: 实现单日学习时长统计
-	// 暂时使用本月学习时长作为判断条件
-	if user.MonthLearntime >= 240 {
-		err := repository.UpdateAchievementHadDone(userID, "专注大师")
+		progress := min(value, def.MaxThreshold())
+		currentTier := achievementTier(row)
+		reached := def.TierFor(value)
+		if reached <= currentTier {
+			if progress != row.Progress {
+				if err := repository.UpdateAchievementProgress(row.ID, progress); err != nil {
+					utils.LogError("更新成就进度失败", logrus.Fields{"user_id": userID, "achievement": def.Name, "error": err.Error()})
+				}
+			}
+			continue
+		}
+		upgraded, err := repository.UpgradeAchievementTier(row.ID, row.Tier, reached, progress, !row.HadDone, time.Now())
 		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "专注大师"})
-			return
+			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": def.Name, "error": err.Error()})
+			continue
 		}
-	}
-}
+		if !upgraded {
+			continue
+		}
+		if !row.HadDone {
+			ctx.unlocked++
+		}
+		newlyUnlocked = append(newlyUnlocked, def)
 
-// 成就检测：早起鸟（早上6点前打卡5次）
-func AchievementCheckEarlyBird(userID uint) {
-	user, err := repository.GetUserByID(userID)
-	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
-	}
-	// TODO: 实现早上6点前打卡次数统计
-	// 暂时使用打卡总次数作为判断条件
-	if user.Daka >= 5 {
-		err := repository.UpdateAchievementHadDone(userID, "早起鸟")
-		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "早起鸟"})
-			return
+		// 跨越多个等级时逐级发放奖励
+		levels := def.Levels()
+		points := 0
+		for _, level := range levels[currentTier:reached] {
+			points += level.Points
 		}
-	}
+		if points > 0 {
+			if err := AwardPoints(userID, points); err != nil {
+				utils.LogError("发放成就奖励积分失败", logrus.Fields{"user_id": userID, "achievement": def.Name, "error": err.Error()})
+			}
+		}
+		PublishEvent(EventAchievementUnlocked, userID, map[string]interface{}{
+			"id":          row.ID,
+			"name":        def.Name,
+			"description": def.Description,
+			"icon":        def.Icon,
+			"points":      points,
+			"tier":        reached,
+			"tier_name":   levels[reached-1].Name,
+		})
+		utils.LogInfo("解锁成就", logrus.Fields{"user_id": userID, "achievement": def.Name, "tier": reached, "points": points})
+	}
+	return newlyUnlocked, nil
 }
 
-// 成就检测：夜猫子（晚上10点后打卡5次）
-func AchievementCheckNightOwl(userID uint) {
-	user, err := repository.GetUserByID(userID)
-	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
-	}
-	// TODO: 实现晚上10点后打卡次数统计
-	// 暂时使用打卡总次数作为判断条件
-	if user.Daka >= 5 {
-		err := repository.UpdateAchievementHadDone(userID, "夜猫子")
-		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "夜猫子"})
-			return
+// 按规则计算成就的当前数值
+func (ctx *achievementContext) value(def model.AchievementDef) (int, error) {
+	// 累计值优先使用用户表上的计数器，包含明细表建立之前的历史数据
+	if def.Rule == model.AchievementRuleTotal {
+		switch def.Metric {
+		case model.AchievementMetricPoints:
+			return ctx.user.Count, nil
+		case model.AchievementMetricDaka:
+			return ctx.user.Daka, nil
+		case model.AchievementMetricAchievements:
+			return ctx.unlocked, nil
+		case model.AchievementMetricFlagCompletions:
+			samples, err := ctx.load(def.Metric)
+			return max(ctx.user.FlagNumber, len(samples)), err
 		}
 	}
-}
 
-// 成就检测：完美主义（连续10次满分完成flag）
-func AchievementCheckPerfectStreak(userID uint) {
-	user, err := repository.GetUserByID(userID)
+	samples, err := ctx.load(def.Metric)
 	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
+		return 0, err
 	}
-	// TODO: 实现连续满分完成flag次数统计
-	// 暂时使用完成flag总数作为判断条件
-	if user.FlagNumber >= 10 {
-		err := repository.UpdateAchievementHadDone(userID, "完美主义")
-		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "完美主义"})
-			return
+	switch def.Rule {
+	case model.AchievementRuleTotal:
+		total := 0
+		for _, s := range samples {
+			total += s.Value
+		}
+		return total, nil
+	case model.AchievementRuleDailyMax:
+		daily := make(map[string]int)
+		best := 0
+		for _, s := range samples {
+			day := s.At.Format("2006-01-02")
+			daily[day] += s.Value
+			best = max(best, daily[day])
 		}
+		return best, nil
+	case model.AchievementRuleStreak:
+		return longestDayStreak(samples), nil
+	case model.AchievementRuleDistinct:
+		keys := make(map[int]bool)
+		for _, s := range samples {
+			keys[s.Key] = true
+		}
+		return len(keys), nil
+	case model.AchievementRuleHourWindow:
+		days := make(map[string]bool)
+		for _, s := range samples {
+			if h := s.At.Hour(); h >= def.HourFrom && h < def.HourTo {
+				days[s.At.Format("2006-01-02")] = true
+			}
+		}
+		return len(days), nil
+	default:
+		return 0, nil
 	}
 }
 
-// 成就检测：全能选手（完成5种不同标签的flag）
-func AchievementCheckAllRounder(userID uint) {
-	user, err := repository.GetUserByID(userID)
-	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
-	}
-	// TODO: 实现不同标签flag统计
-	// 暂时使用完成flag总数作为判断条件
-	if user.FlagNumber >= 5 {
-		err := repository.UpdateAchievementHadDone(userID, "全能选手")
+// 加载指标的原始记录（同一次评估内只查询一次）
+func (ctx *achievementContext) load(metric string) ([]metricSample, error) {
+	if samples, ok := ctx.samples[metric]; ok {
+		return samples, nil
+	}
+	var samples []metricSample
+	userID := ctx.user.ID
+	switch metric {
+	case model.AchievementMetricFlagsCreated:
+		for _, f := range ctx.user.Flags {
+			samples = append(samples, metricSample{At: f.CreatedAt, Value: 1})
+		}
+	case model.AchievementMetricFlagCompletions:
+		completions, err := repository.GetFlagCompletionsByUserID(userID)
 		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "全能选手"})
-			return
+			return nil, err
 		}
-	}
-}
-
-// 成就检测：学习狂人（累计学习时间超过5000分钟）
-func AchievementCheckLearn5000Min(userID uint) {
-	user, err := repository.GetUserByID(userID)
-	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
-	}
-	var totalLearnTime int
-	for _, learnTime := range user.LearnTimes {
-		totalLearnTime += learnTime.Duration
-	}
-	if totalLearnTime >= 5000 {
-		err := repository.UpdateAchievementHadDone(userID, "学习狂人")
+		for _, c := range completions {
+			samples = append(samples, metricSample{At: c.CreatedAt, Value: 1, Key: c.Label})
+		}
+	case model.AchievementMetricLearnMinutes:
+		learnTimes, err := repository.GetLearnTimesSince(userID, time.Time{})
 		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "学习狂人"})
-			return
+			return nil, err
 		}
-	}
-}
-
-// 成就检测：社交达人（发布10条动态）
-func AchievementCheckPost10Times(userID uint) {
-	user, err := repository.GetUserByID(userID)
-	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
-	}
-	// TODO: 实现动态发布次数统计
-	// 暂时使用flag总数作为判断条件
-	if user.FlagNumber >= 10 {
-		err := repository.UpdateAchievementHadDone(userID, "社交达人")
+		for _, lt := range learnTimes {
+			samples = append(samples, metricSample{At: lt.CreatedAt, Value: lt.Duration})
+		}
+	case model.AchievementMetricDaka:
+		records, err := repository.GetDakaDaysByUserID(userID)
 		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "社交达人"})
-			return
+			return nil, err
 		}
-	}
-}
-
-// 成就检测：时间管理者（连续30天完成至少1个flag）
-func AchievementCheckDailyFlag30Days(userID uint) {
-	user, err := repository.GetUserByID(userID)
-	if err != nil {
-		utils.LogError("获取用户信息失败", logrus.Fields{"user_id": userID})
-		return
-	}
-	// TODO: 实现连续30天完成flag的检测逻辑
-	// 暂时使用打卡总次数作为判断条件
-	if user.Daka >= 30 {
-		err := repository.UpdateAchievementHadDone(userID, "时间管理者")
+		for _, r := range records {
+			samples = append(samples, metricSample{At: r.DaKaDate, Value: 1})
+		}
+	case model.AchievementMetricPosts:
+		times, err := repository.GetPostTimesByUserID(userID)
 		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "时间管理者"})
-			return
+			return nil, err
+		}
+		for _, t := range times {
+			samples = append(samples, metricSample{At: t, Value: 1})
 		}
 	}
+	ctx.samples[metric] = samples
+	return samples, nil
 }
 
-// 成就检测：成就收集者（解锁10个徽章）
-func AchievementCheckUnlock10Badges(userID uint) {
-	achievements, err := repository.GetAchievementsByUserID(userID)
-	if err != nil {
-		utils.LogError("获取用户成就失败", logrus.Fields{"user_id": userID})
-		return
-	}
-	unlockedCount := 0
-	for _, achievement := range achievements {
-		if achievement.HadDone {
-			unlockedCount++
+// 计算记录覆盖的最长连续天数
+func longestDayStreak(samples []metricSample) int {
+	days := make(map[string]bool)
+	for _, s := range samples {
+		days[s.At.Format("2006-01-02")] = true
+	}
+	best := 0
+	for day := range days {
+		t, _ := time.ParseInLocation("2006-01-02", day, time.Local)
+		// 只从连续段的第一天开始计数
+		if days[t.AddDate(0, 0, -1).Format("2006-01-02")] {
+			continue
 		}
-	}
-	if unlockedCount >= 10 {
-		err := repository.UpdateAchievementHadDone(userID, "成就收集者")
-		if err != nil {
-			utils.LogError("更新成就状态失败", logrus.Fields{"user_id": userID, "achievement": "成就收集者"})
-			return
+		streak := 0
+		for days[t.Format("2006-01-02")] {
+			streak++
+			t = t.AddDate(0, 0, 1)
 		}
+		best = max(best, streak)
 	}
+	return best
 }
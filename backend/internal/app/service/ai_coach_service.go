@@ -0,0 +1,437 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	taskPlanRevision     = "plan_revision"
+	planReviewWindowDays = 14 // 回看最近14天的执行情况
+	maxAdjustedDaily     = 20 // 调整后每日次数上限
+)
+
+// 计划中单个flag的执行情况
+type planFlagProgress struct {
+	FlagID        uint      `json:"flag_id"`
+	Title         string    `json:"title"`
+	DailyTotal    int       `json:"daily_total"`
+	CompletedDays int       `json:"completed_days"` // 窗口内达成每日目标的天数
+	EndTime       time.Time `json:"end_time"`
+}
+
+// 计划执行情况快照
+type planProgressSnapshot struct {
+	WindowDays   int                `json:"window_days"`
+	Flags        []planFlagProgress `json:"flags"`
+	DailyMinutes map[string]int     `json:"daily_minutes"`
+	TotalMinutes int                `json:"total_minutes"`
+	MissedDays   []string           `json:"missed_days"` // 没有完成任何计划flag的日期
+}
+
+// 单个flag的调整建议
+type PlanAdjustment struct {
+	FlagID        uint   `json:"flag_id"`
+	Title         string `json:"title,omitempty"`
+	OldDailyTotal int    `json:"old_daily_total,omitempty"`
+	DailyTotal    int    `json:"daily_total,omitempty"` // 新的每日次数，0 表示不变
+	EndDate       string `json:"end_date,omitempty"`    // 新的截止日期（2006-01-02），空表示不变
+	Reason        string `json:"reason,omitempty"`
+}
+
+// 调整版计划中保存的建议内容
+type planRevisionPayload struct {
+	Summary     string           `json:"summary"`
+	Adjustments []PlanAdjustment `json:"adjustments"`
+}
+
+const planRevisionSystemPrompt = `你是"太傅AI学习教练"，根据用户真实的执行数据调整学习计划。
+
+【输入】原学习计划、计划创建的每个flag（含flag_id、每日目标次数、最近完成天数、截止日期）、每日学习时长、缺勤日期。
+
+【调整原则】
+1. 完成率低、缺勤多：降低每日次数或延长阶段时间，先保证能坚持
+2. 完成率高、学习时长充足：适当提高每日次数或缩短阶段时间
+3. 只调整确有必要的flag，每条调整都要给出简短理由
+4. 每日次数范围1-20，截止日期格式YYYY-MM-DD
+
+【返回JSON格式】（不要包含markdown代码块标记）
+{
+    "summary": "对最近执行情况的简短评价（2-3句话）",
+    "plan": "调整后的完整计划文本，格式与原计划一致",
+    "adjustments": [
+        {"flag_id": 1, "daily_total": 2, "end_date": "2025-12-31", "reason": "调整理由"}
+    ]
+}`
+
+// 收集计划关联flag、学习时长和缺勤情况
+func collectPlanProgress(userID uint, root model.AIPlan, flags []model.Flag) (planProgressSnapshot, error) {
+	today := startOfToday()
+	windowStart := today.AddDate(0, 0, -(planReviewWindowDays - 1))
+	planStart := time.Date(root.CreatedAt.Year(), root.CreatedAt.Month(), root.CreatedAt.Day(), 0, 0, 0, 0, today.Location())
+	if planStart.After(windowStart) {
+		windowStart = planStart
+	}
+
+	snapshot := planProgressSnapshot{
+		WindowDays:   int(today.Sub(windowStart).Hours()/24) + 1,
+		DailyMinutes: make(map[string]int),
+		MissedDays:   []string{},
+	}
+
+	flagIDs := make([]uint, 0, len(flags))
+	for _, f := range flags {
+		flagIDs = append(flagIDs, f.ID)
+	}
+	completions, err := repository.GetFlagCompletionsSince(flagIDs, windowStart)
+	if err != nil {
+		return snapshot, err
+	}
+	completedDays := make(map[uint]map[string]bool)
+	activeDays := make(map[string]bool)
+	for _, c := range completions {
+		day := c.CreatedAt.Format("2006-01-02")
+		if completedDays[c.FlagID] == nil {
+			completedDays[c.FlagID] = make(map[string]bool)
+		}
+		completedDays[c.FlagID][day] = true
+		activeDays[day] = true
+	}
+	for _, f := range flags {
+		snapshot.Flags = append(snapshot.Flags, planFlagProgress{
+			FlagID:        f.ID,
+			Title:         f.Title,
+			DailyTotal:    f.DailyTotal,
+			CompletedDays: len(completedDays[f.ID]),
+			EndTime:       f.EndTime,
+		})
+	}
+
+	learnTimes, err := repository.GetLearnTimesSince(userID, windowStart)
+	if err != nil {
+		return snapshot, err
+	}
+	for _, lt := range learnTimes {
+		snapshot.DailyMinutes[lt.CreatedAt.Format("2006-01-02")] += lt.Duration
+		snapshot.TotalMinutes += lt.Duration
+	}
+
+	// 今天还没结束，不算缺勤
+	for d := windowStart; d.Before(today); d = d.AddDate(0, 0, 1) {
+		day := d.Format("2006-01-02")
+		if !activeDays[day] {
+			snapshot.MissedDays = append(snapshot.MissedDays, day)
+		}
+	}
+	return snapshot, nil
+}
+
+// 构建计划调整的模型请求
+func buildPlanRevisionLLMRequest(root model.AIPlan, snapshot planProgressSnapshot) LLMRequest {
+	var b strings.Builder
+	fmt.Fprintf(&b, "学习目标: %s\n", root.Goal)
+	if root.Background != "" {
+		fmt.Fprintf(&b, "个人背景: %s\n", root.Background)
+	}
+	fmt.Fprintf(&b, "原计划难度: %d\n", root.Difficulty)
+	fmt.Fprintf(&b, "原计划:\n%s\n\n", root.Plan)
+
+	fmt.Fprintf(&b, "最近%d天的flag执行情况:\n", snapshot.WindowDays)
+	for _, f := range snapshot.Flags {
+		endDate := "无"
+		if !f.EndTime.IsZero() {
+			endDate = f.EndTime.Format("2006-01-02")
+		}
+		fmt.Fprintf(&b, "- [flag_id=%d] %s | 每日目标 %d 次 | 近%d天完成 %d 天 | 截止 %s\n",
+			f.FlagID, f.Title, f.DailyTotal, snapshot.WindowDays, f.CompletedDays, endDate)
+	}
+
+	days := make([]string, 0, len(snapshot.DailyMinutes))
+	for day := range snapshot.DailyMinutes {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	fmt.Fprintf(&b, "\n每日学习时长（分钟，合计 %d）:\n", snapshot.TotalMinutes)
+	for _, day := range days {
+		fmt.Fprintf(&b, "- %s: %d\n", day, snapshot.DailyMinutes[day])
+	}
+	if len(snapshot.MissedDays) > 0 {
+		fmt.Fprintf(&b, "\n未完成任何计划任务的日期（共%d天）: %s\n", len(snapshot.MissedDays), strings.Join(snapshot.MissedDays, ", "))
+	}
+	b.WriteString("\n请根据以上执行数据调整学习计划,返回标准JSON格式。")
+
+	return LLMRequest{
+		Task: taskPlanRevision,
+		Messages: []LLMMessage{
+			{Role: "system", Content: planRevisionSystemPrompt},
+			{Role: "user", Content: b.String()},
+		},
+	}
+}
+
+// 解析计划调整响应
+func parsePlanRevisionResponse(response string) (string, string, []PlanAdjustment, error) {
+	clean := strings.TrimSpace(response)
+	clean = strings.TrimPrefix(clean, "```json")
+	clean = strings.TrimPrefix(clean, "```")
+	clean = strings.TrimSuffix(clean, "```")
+	clean = strings.TrimSpace(clean)
+
+	var result struct {
+		Summary     string           `json:"summary"`
+		Plan        string           `json:"plan"`
+		Adjustments []PlanAdjustment `json:"adjustments"`
+	}
+	if err := json.Unmarshal([]byte(clean), &result); err != nil {
+		return "", "", nil, fmt.Errorf("解析调整结果失败: %v", err)
+	}
+	return result.Summary, result.Plan, result.Adjustments, nil
+}
+
+// 过滤模型给出的调整：只保留计划内的flag，次数和日期必须合法
+func sanitizePlanAdjustments(adjustments []PlanAdjustment, flags []model.Flag) []PlanAdjustment {
+	flagMap := make(map[uint]model.Flag, len(flags))
+	for _, f := range flags {
+		flagMap[f.ID] = f
+	}
+	today := startOfToday()
+	valid := make([]PlanAdjustment, 0, len(adjustments))
+	for _, adj := range adjustments {
+		flag, ok := flagMap[adj.FlagID]
+		if !ok {
+			continue
+		}
+		if adj.DailyTotal < 0 || adj.DailyTotal > maxAdjustedDaily || adj.DailyTotal == flag.DailyTotal {
+			adj.DailyTotal = 0
+		}
+		if adj.EndDate != "" {
+			endDate, err := time.ParseInLocation("2006-01-02", adj.EndDate, today.Location())
+			if err != nil || endDate.Before(today) {
+				adj.EndDate = ""
+			}
+		}
+		if adj.DailyTotal == 0 && adj.EndDate == "" {
+			continue
+		}
+		adj.Title = flag.Title
+		adj.OldDailyTotal = flag.DailyTotal
+		valid = append(valid, adj)
+	}
+	return valid
+}
+
+// 根据真实执行数据调整学习计划
+func RevisePlan() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		initPlanner()
+		id, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		planID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "无效的计划ID"})
+			return
+		}
+		plan, err := repository.GetAIPlanByID(uint(planID))
+		if err != nil || plan.UserID != id {
+			c.JSON(404, gin.H{"error": "学习计划不存在"})
+			return
+		}
+		// 调整版计划继续调整时，始终以原计划创建的flag为准
+		root := plan
+		if plan.ParentID != nil {
+			root, err = repository.GetAIPlanByID(*plan.ParentID)
+			if err != nil {
+				c.JSON(404, gin.H{"error": "原学习计划不存在"})
+				return
+			}
+			root.Plan = plan.Plan
+		}
+
+		flags, err := repository.GetFlagsByAIPlanID(id, root.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取计划flag失败,请重新再试..."})
+			utils.LogError("获取计划flag失败", logrus.Fields{"user_id": id, "plan_id": root.ID, "error": err.Error()})
+			return
+		}
+		if len(flags) == 0 {
+			c.JSON(400, gin.H{"error": "该计划还没有创建flag，无法根据执行情况调整"})
+			return
+		}
+		snapshot, err := collectPlanProgress(id, root, flags)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "统计计划执行情况失败,请重新再试..."})
+			utils.LogError("统计计划执行情况失败", logrus.Fields{"user_id": id, "plan_id": root.ID, "error": err.Error()})
+			return
+		}
+
+		revision := &model.AIPlan{
+			UserID:        id,
+			Goal:          root.Goal,
+			Background:    root.Background,
+			ReqDifficulty: root.ReqDifficulty,
+			Flag:          root.Flag,
+			Difficulty:    root.Difficulty,
+			ParentID:      &root.ID,
+		}
+		if !reserveAIQuota(c, revision) {
+			return
+		}
+		response, err := planner.Provider.ChatCompletion(c.Request.Context(), buildPlanRevisionLLMRequest(root, snapshot))
+		if err != nil {
+			releaseAIQuota(revision)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("调整学习计划失败: %v", err)})
+			utils.LogError("AI调整学习计划失败", logrus.Fields{"user_id": id, "plan_id": root.ID, "error": err.Error()})
+			return
+		}
+		summary, revisedPlan, adjustments, err := parsePlanRevisionResponse(response)
+		if err != nil {
+			releaseAIQuota(revision)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			utils.LogError("解析AI调整结果失败", logrus.Fields{"user_id": id, "plan_id": root.ID, "error": err.Error()})
+			return
+		}
+		if revisedPlan == "" {
+			revisedPlan = root.Plan
+		}
+		adjustments = sanitizePlanAdjustments(adjustments, flags)
+
+		payload, _ := json.Marshal(planRevisionPayload{Summary: summary, Adjustments: adjustments})
+		revision.Plan = revisedPlan
+		revision.Adjustments = string(payload)
+		if err := repository.UpdateAIPlan(revision); err != nil {
+			c.JSON(500, gin.H{"error": "保存调整后的计划失败,请重新再试..."})
+			utils.LogError("保存调整后的计划失败", logrus.Fields{"user_id": id, "plan_id": root.ID, "error": err.Error()})
+			return
+		}
+
+		repository.AddTrackPointToDB(id, "AI调整学习计划")
+		utils.LogInfo("AI调整学习计划成功", logrus.Fields{"user_id": id, "plan_id": root.ID, "revision_id": revision.ID, "adjustments": len(adjustments)})
+		c.JSON(200, gin.H{
+			"success":     true,
+			"revision_id": revision.ID,
+			"summary":     summary,
+			"plan":        revisedPlan,
+			"adjustments": adjustments,
+			"progress":    snapshot,
+		})
+	}
+}
+
+// 将调整建议应用到已有flag（可通过 flag_ids 只应用部分）
+func ApplyPlanRevision() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		planID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "无效的计划ID"})
+			return
+		}
+		var req struct {
+			FlagIDs []uint `json:"flag_ids"`
+		}
+		// 请求体可为空，表示应用全部调整
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(400, gin.H{"error": "参数错误,请重新再试..."})
+				return
+			}
+		}
+
+		revision, err := repository.GetAIPlanByID(uint(planID))
+		if err != nil || revision.UserID != id || revision.ParentID == nil {
+			c.JSON(404, gin.H{"error": "调整后的学习计划不存在"})
+			return
+		}
+		var payload planRevisionPayload
+		if err := json.Unmarshal([]byte(revision.Adjustments), &payload); err != nil {
+			c.JSON(500, gin.H{"error": "读取调整建议失败"})
+			utils.LogError("解析调整建议失败", logrus.Fields{"revision_id": revision.ID, "error": err.Error()})
+			return
+		}
+
+		selected := make(map[uint]bool, len(req.FlagIDs))
+		for _, fid := range req.FlagIDs {
+			selected[fid] = true
+		}
+
+		applied := make([]PlanAdjustment, 0, len(payload.Adjustments))
+		for _, adj := range payload.Adjustments {
+			if len(selected) > 0 && !selected[adj.FlagID] {
+				continue
+			}
+			flag, err := repository.GetFlagByID(adj.FlagID)
+			if err != nil || flag.UserID != id || flag.AIPlanID == nil || *flag.AIPlanID != *revision.ParentID {
+				continue
+			}
+			updates := map[string]interface{}{}
+			if adj.DailyTotal > 0 {
+				updates["daily_total"] = adj.DailyTotal
+			}
+			if adj.EndDate != "" {
+				if endDate, err := time.ParseInLocation("2006-01-02", adj.EndDate, time.Local); err == nil {
+					updates["end_time"] = time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 59, 59, 0, endDate.Location())
+				}
+			}
+			if len(updates) == 0 {
+				continue
+			}
+			if err := repository.UpdateFlag(flag.ID, updates); err != nil {
+				utils.LogError("应用计划调整失败", logrus.Fields{"flag_id": flag.ID, "error": err.Error()})
+				continue
+			}
+			applied = append(applied, adj)
+		}
+
+		if len(applied) > 0 {
+			if err := repository.UpdateAIPlanAppliedAt(revision.ID, time.Now()); err != nil {
+				utils.LogError("更新计划应用时间失败", logrus.Fields{"revision_id": revision.ID, "error": err.Error()})
+			}
+		}
+		utils.LogInfo("应用计划调整成功", logrus.Fields{"user_id": id, "revision_id": revision.ID, "applied": len(applied)})
+		c.JSON(200, gin.H{"success": true, "applied": applied})
+	}
+}
+
+var fakeRevisionFlagPattern = regexp.MustCompile(`\[flag_id=(\d+)\][^|]*\| 每日目标 (\d+) 次 \| 近\d+天完成 (\d+) 天`)
+
+// fake provider 的计划调整响应：最近完成不足3天的flag每日次数减1
+func fakePlanRevisionResponse(userPrompt string) string {
+	adjustments := []PlanAdjustment{}
+	for _, m := range fakeRevisionFlagPattern.FindAllStringSubmatch(userPrompt, -1) {
+		flagID, _ := strconv.Atoi(m[1])
+		daily, _ := strconv.Atoi(m[2])
+		done, _ := strconv.Atoi(m[3])
+		if done < 3 && daily > 1 {
+			adjustments = append(adjustments, PlanAdjustment{
+				FlagID:     uint(flagID),
+				DailyTotal: daily - 1,
+				Reason:     "最近完成天数较少，先降低每日次数保证坚持",
+			})
+		}
+	}
+	out, _ := json.Marshal(map[string]interface{}{
+		"summary":     "最近的执行情况已汇总，建议先保证每天都能完成任务，再逐步提高强度。",
+		"plan":        "",
+		"adjustments": adjustments,
+	})
+	return string(out)
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAIDailyQuota      = 10
+	defaultAIPlanCacheTTLHrs = 168 // 7天
+)
+
+// 每人每日可真实调用模型的次数，学习计划、计划调整和手动生成周报共用（AI_DAILY_QUOTA，<=0 表示不限制）
+func aiDailyQuota() int {
+	if v, err := strconv.Atoi(os.Getenv("AI_DAILY_QUOTA")); err == nil {
+		return v
+	}
+	return defaultAIDailyQuota
+}
+
+// 相同请求的缓存有效期（AI_PLAN_CACHE_TTL_HOURS，<=0 表示关闭缓存）
+func aiPlanCacheTTL() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("AI_PLAN_CACHE_TTL_HOURS")); err == nil {
+		return time.Duration(v) * time.Hour
+	}
+	return defaultAIPlanCacheTTLHrs * time.Hour
+}
+
+// 归一化请求后计算哈希，大小写和首尾空白不同的请求视为相同
+func aiPlanRequestHash(req LearningPlanRequest) string {
+	normalized := fmt.Sprintf("%s\n%s\n%d",
+		strings.ToLower(strings.TrimSpace(req.Flag)),
+		strings.ToLower(strings.TrimSpace(req.Background)),
+		req.Difficulty)
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// 今日零点
+func startOfToday() time.Time {
+	now := time.Now()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// 计算用户今日剩余生成次数，quota<=0 时返回 -1 表示不限
+func remainingAIQuota(userID uint) (int, error) {
+	quota := aiDailyQuota()
+	if quota <= 0 {
+		return -1, nil
+	}
+	used, err := repository.CountUserAIUsageSince(userID, startOfToday())
+	if err != nil {
+		return 0, err
+	}
+	remaining := quota - int(used)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, nil
+}
+
+// 调用模型前预占一次每日配额：先写入占位记录，并发请求不会同时通过检查；用完时写回 429
+// 生成成功后用 saveGeneratedAIPlan 写回内容，失败时用 releaseAIQuota 退还
+func reserveAIQuota(c *gin.Context, record *model.AIPlan) bool {
+	reserved, err := repository.ReserveAIPlan(record, startOfToday(), aiDailyQuota())
+	return checkAIQuotaReserved(c, record.UserID, reserved, err)
+}
+
+// 预占一次每日配额并写入调用记录（学习计划以外的场景），失败时用 releaseAIUsage 退还
+func reserveAIUsage(c *gin.Context, usage *model.AIUsage) bool {
+	reserved, err := repository.ReserveAIUsage(usage, startOfToday(), aiDailyQuota())
+	return checkAIQuotaReserved(c, usage.UserID, reserved, err)
+}
+
+// 预占失败时写回 500，配额用完时写回 429
+func checkAIQuotaReserved(c *gin.Context, userID uint, reserved bool, err error) bool {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, LearningPlanResponse{
+			Success: false,
+			Error:   "AI生成失败,请重新再试...",
+		})
+		utils.LogError("预占AI生成配额失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+		return false
+	}
+	if !reserved {
+		c.JSON(http.StatusTooManyRequests, LearningPlanResponse{
+			Success: false,
+			Error:   fmt.Sprintf("今日AI生成次数已用完（每日%d次），请明天再试", aiDailyQuota()),
+		})
+		return false
+	}
+	return true
+}
+
+// 退还调用记录占用的配额
+func releaseAIUsage(usage *model.AIUsage) {
+	if err := repository.DeleteAIUsage(usage.ID); err != nil {
+		utils.LogError("退还AI生成配额失败", logrus.Fields{"user_id": usage.UserID, "usage_id": usage.ID, "error": err.Error()})
+	}
+}
+
+// 生成失败时删除占位记录，退还配额
+func releaseAIQuota(record *model.AIPlan) {
+	if err := repository.DeleteAIPlan(record.ID); err != nil {
+		utils.LogError("退还AI生成配额失败", logrus.Fields{"user_id": record.UserID, "plan_id": record.ID, "error": err.Error()})
+	}
+}
+
+// 新生成计划的占位记录
+func newAIPlanRecord(userID uint, req LearningPlanRequest) *model.AIPlan {
+	return &model.AIPlan{
+		UserID:        userID,
+		Goal:          req.Flag,
+		Background:    req.Background,
+		ReqDifficulty: req.Difficulty,
+	}
+}
+
+// 查找缓存中相同请求的计划，命中时为当前用户写一条历史记录
+func serveCachedAIPlan(userID uint, req LearningPlanRequest) (model.AIPlan, bool) {
+	ttl := aiPlanCacheTTL()
+	if ttl <= 0 {
+		return model.AIPlan{}, false
+	}
+	cached, err := repository.FindCachedAIPlan(aiPlanRequestHash(req), time.Now().Add(-ttl))
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.LogError("查询AI计划缓存失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+		}
+		return model.AIPlan{}, false
+	}
+	record := model.AIPlan{
+		UserID:        userID,
+		Goal:          req.Flag,
+		Background:    req.Background,
+		ReqDifficulty: req.Difficulty,
+		RequestHash:   cached.RequestHash,
+		Flag:          cached.Flag,
+		Difficulty:    cached.Difficulty,
+		Plan:          cached.Plan,
+		Cached:        true,
+	}
+	if err := repository.SaveAIPlan(&record); err != nil {
+		utils.LogError("保存缓存AI计划记录失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+	}
+	utils.LogInfo("AI计划命中缓存", logrus.Fields{"user_id": userID, "source_plan_id": cached.ID})
+	return record, true
+}
+
+// 把新生成的计划写回占位记录；解析失败（无标题）的计划不参与缓存
+func saveGeneratedAIPlan(record *model.AIPlan, req LearningPlanRequest, flag, plan string, difficulty int) {
+	record.Flag = flag
+	record.Difficulty = difficulty
+	record.Plan = plan
+	if flag != "" {
+		record.RequestHash = aiPlanRequestHash(req)
+	}
+	if err := repository.UpdateAIPlan(record); err != nil {
+		utils.LogError("保存AI计划失败", logrus.Fields{"user_id": record.UserID, "error": err.Error()})
+	}
+}
+
+// 获取AI学习计划历史
+func GetAIPlanHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		page, limit := parsePagination(c)
+		plans, total, err := repository.GetAIPlansByUserID(id, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取学习计划历史失败,请重新再试..."})
+			utils.LogError("获取AI计划历史失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+
+		// 列表只返回摘要，完整计划通过详情接口获取
+		type planSummary struct {
+			ID         uint      `json:"id"`
+			Goal       string    `json:"goal"`
+			Flag       string    `json:"flag"`
+			Difficulty int       `json:"difficulty"`
+			Preview    string    `json:"preview"`
+			Cached     bool      `json:"cached"`
+			CreatedAt  time.Time `json:"created_at"`
+		}
+		items := make([]planSummary, 0, len(plans))
+		for _, p := range plans {
+			preview := []rune(p.Plan)
+			if len(preview) > 80 {
+				preview = preview[:80]
+			}
+			items = append(items, planSummary{
+				ID:         p.ID,
+				Goal:       p.Goal,
+				Flag:       p.Flag,
+				Difficulty: p.Difficulty,
+				Preview:    string(preview),
+				Cached:     p.Cached,
+				CreatedAt:  p.CreatedAt,
+			})
+		}
+
+		remaining, _ := remainingAIQuota(id)
+		c.JSON(200, gin.H{
+			"success": true,
+			"plans":   items,
+			"total":   total,
+			"page":    page,
+			"limit":   limit,
+			"quota": gin.H{
+				"daily":     aiDailyQuota(),
+				"remaining": remaining,
+			},
+		})
+	}
+}
+
+// 重新打开某条历史学习计划
+func GetAIPlanDetail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		planID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "无效的计划ID"})
+			return
+		}
+		plan, err := repository.GetAIPlanByID(uint(planID))
+		if err != nil || plan.UserID != id {
+			c.JSON(404, gin.H{"error": "学习计划不存在"})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "plan": plan})
+	}
+}
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultUploadMaxMB    = 5
+	maxImagePixels        = 16_000_000 // 防止解码超大图片耗尽内存（解码后每像素最多占 4 字节）
+	thumbnailMaxSide      = 320
+	maxAttachmentsPerItem = 9
+	attachmentKeyPrefix   = "attachments/"
+	staleAttachmentAge    = 24 * time.Hour
+)
+
+// 允许上传的图片类型及扩展名
+var attachmentExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// 单个文件大小上限（UPLOAD_MAX_MB，默认 5MB）
+func uploadMaxBytes() int64 {
+	mb, err := strconv.Atoi(os.Getenv("UPLOAD_MAX_MB"))
+	if err != nil || mb <= 0 {
+		mb = defaultUploadMaxMB
+	}
+	return int64(mb) << 20
+}
+
+func randomFileName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// 检查客户端传来的附件ID：去重、数量上限、必须是自己上传且未被引用的
+func validateAttachmentIDs(c *gin.Context, userID uint, ids []uint) ([]uint, bool) {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) > maxAttachmentsPerItem {
+		c.JSON(400, gin.H{"error": "最多只能添加" + strconv.Itoa(maxAttachmentsPerItem) + "张图片"})
+		return nil, false
+	}
+	count, err := repository.CountUnboundAttachments(userID, unique)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "校验附件失败,请重新再试..."})
+		utils.LogError("校验附件失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+		return nil, false
+	}
+	if int(count) != len(unique) {
+		c.JSON(400, gin.H{"error": "附件不存在或已被使用"})
+		return nil, false
+	}
+	return unique, true
+}
+
+// 把附件关联到内容上，返回关联后的附件列表
+func bindAttachments(userID uint, ids []uint, ownerType string, ownerID uint) []model.Attachment {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := repository.BindAttachments(userID, ids, ownerType, ownerID); err != nil {
+		utils.LogError("关联附件失败", logrus.Fields{"owner_type": ownerType, "owner_id": ownerID, "error": err.Error()})
+		return nil
+	}
+	attachments, err := repository.GetAttachmentsByOwners(ownerType, []uint{ownerID})
+	if err != nil {
+		utils.LogError("获取附件失败", logrus.Fields{"owner_type": ownerType, "owner_id": ownerID, "error": err.Error()})
+	}
+	return attachments[ownerID]
+}
+
+// 内容删除后解除附件关联
+func releaseAttachments(ownerType string, ownerIDs ...uint) {
+	if err := repository.ReleaseAttachments(ownerType, ownerIDs); err != nil {
+		utils.LogError("解除附件关联失败", logrus.Fields{"owner_type": ownerType, "owner_ids": ownerIDs, "error": err.Error()})
+	}
+}
+
+// 读取 multipart 字段 file 中的图片：校验大小、类型和分辨率并解码，失败时已写好响应
+func readImageUpload(c *gin.Context) ([]byte, string, image.Image, bool) {
+	maxBytes := uploadMaxBytes()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+1<<20)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(400, gin.H{"error": "请选择要上传的图片（字段名 file），且不超过" + strconv.FormatInt(maxBytes>>20, 10) + "MB"})
+		return nil, "", nil, false
+	}
+	defer file.Close()
+	if header.Size > maxBytes {
+		c.JSON(400, gin.H{"error": "图片不能超过" + strconv.FormatInt(maxBytes>>20, 10) + "MB"})
+		return nil, "", nil, false
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "读取图片失败"})
+		return nil, "", nil, false
+	}
+	if int64(len(data)) > maxBytes {
+		c.JSON(400, gin.H{"error": "图片不能超过" + strconv.FormatInt(maxBytes>>20, 10) + "MB"})
+		return nil, "", nil, false
+	}
+
+	// 按文件内容判断类型，不信任客户端声明的 Content-Type 和扩展名
+	contentType := http.DetectContentType(data)
+	if _, allowed := attachmentExtensions[contentType]; !allowed {
+		c.JSON(400, gin.H{"error": "只支持 JPEG、PNG、GIF 图片"})
+		return nil, "", nil, false
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "图片已损坏或格式不正确"})
+		return nil, "", nil, false
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		c.JSON(400, gin.H{"error": "图片分辨率过大"})
+		return nil, "", nil, false
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(400, gin.H{"error": "图片已损坏或格式不正确"})
+		return nil, "", nil, false
+	}
+	return data, contentType, img, true
+}
+
+// 上传图片：校验类型和大小，保存原图并生成缩略图
+func UploadAttachment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		data, contentType, img, ok := readImageUpload(c)
+		if !ok {
+			return
+		}
+		var thumb bytes.Buffer
+		if err := jpeg.Encode(&thumb, utils.Thumbnail(img, thumbnailMaxSide), &jpeg.Options{Quality: 80}); err != nil {
+			c.JSON(500, gin.H{"error": "上传图片失败,请重新再试..."})
+			utils.LogError("生成缩略图失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+			return
+		}
+
+		name, err := randomFileName()
+		if err != nil {
+			c.JSON(500, gin.H{"error": "上传图片失败,请重新再试..."})
+			utils.LogError("生成文件名失败", logrus.Fields{"error": err.Error()})
+			return
+		}
+		attachment := model.Attachment{
+			UserID:      userID,
+			Key:         name + attachmentExtensions[contentType],
+			ThumbKey:    name + "_thumb.jpg",
+			ContentType: contentType,
+			Size:        int64(len(data)),
+			Width:       img.Bounds().Dx(),
+			Height:      img.Bounds().Dy(),
+		}
+		store := getBlobStore()
+		ctx := c.Request.Context()
+		if err := store.Put(ctx, attachmentKeyPrefix+attachment.Key, data, contentType); err != nil {
+			c.JSON(500, gin.H{"error": "上传图片失败,请重新再试..."})
+			utils.LogError("保存图片失败", logrus.Fields{"user_id": userID, "store": store.Name(), "error": err.Error()})
+			return
+		}
+		if err := store.Put(ctx, attachmentKeyPrefix+attachment.ThumbKey, thumb.Bytes(), "image/jpeg"); err != nil {
+			store.Delete(ctx, attachmentKeyPrefix+attachment.Key)
+			c.JSON(500, gin.H{"error": "上传图片失败,请重新再试..."})
+			utils.LogError("保存缩略图失败", logrus.Fields{"user_id": userID, "store": store.Name(), "error": err.Error()})
+			return
+		}
+		if err := repository.SaveAttachment(&attachment); err != nil {
+			store.Delete(ctx, attachmentKeyPrefix+attachment.Key)
+			store.Delete(ctx, attachmentKeyPrefix+attachment.ThumbKey)
+			c.JSON(500, gin.H{"error": "上传图片失败,请重新再试..."})
+			utils.LogError("保存附件记录失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+			return
+		}
+		utils.LogInfo("用户上传图片成功", logrus.Fields{"user_id": userID, "attachment_id": attachment.ID, "size": attachment.Size})
+		c.JSON(200, gin.H{"success": true, "attachment": attachment})
+	}
+}
+
+// 附件所属内容是否对 viewerID（未登录为 0）可见：内容被隐藏时不可见，私信和小组聊天图片只有能看到消息的人可见
+func attachmentVisible(attachment model.Attachment, viewerID uint) bool {
+	switch attachment.OwnerType {
+	case model.AttachmentOwnerPost:
+		post, err := repository.GetPostVisibility(attachment.OwnerID)
+		return err == nil && !post.Hidden
+	case model.AttachmentOwnerPostComment:
+		comment, err := repository.GetCommentByID(attachment.OwnerID)
+		if err != nil || comment.Hidden {
+			return false
+		}
+		post, err := repository.GetPostVisibility(comment.PostID)
+		return err == nil && !post.Hidden
+	case model.AttachmentOwnerFlagComment:
+		comment, err := repository.GetFlagCommentByID(attachment.OwnerID)
+		if err != nil || comment.Hidden {
+			return false
+		}
+		flag, err := repository.GetFlagByID(comment.FlagID)
+		return err == nil && !flag.Hidden && (flag.IsPublic || (viewerID != 0 && flag.UserID == viewerID))
+	case model.AttachmentOwnerChatMessage:
+		message, err := repository.GetChatMessageByID(attachment.OwnerID)
+		return err == nil && !message.Hidden && canSeeChatMessage(message, viewerID)
+	}
+	// 尚未引用的图片只有上传者在编辑时使用
+	return true
+}
+
+// 读取图片（文件名随机生成，公开内容的图片不需要登录，便于直接用于 img 标签）
+// 私信、小组聊天和未公开flag的图片需要带 token（Authorization 头或 ?token=）；内容被隐藏后返回 404
+func ServeAttachmentFile() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.Param("key")
+		attachment, err := repository.GetAttachmentByKey(key)
+		if err != nil || !attachmentVisible(attachment, optionalUserID(c)) {
+			c.JSON(404, gin.H{"error": "文件不存在"})
+			return
+		}
+		contentType := attachment.ContentType
+		if key == attachment.ThumbKey {
+			contentType = "image/jpeg"
+		}
+		body, err := getBlobStore().Get(c.Request.Context(), attachmentKeyPrefix+key)
+		if errors.Is(err, ErrBlobNotFound) {
+			c.JSON(404, gin.H{"error": "文件不存在"})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "读取文件失败,请重新再试..."})
+			utils.LogError("读取附件失败", logrus.Fields{"key": key, "error": err.Error()})
+			return
+		}
+		defer body.Close()
+		// 内容可能随时被隐藏，只允许浏览器短时间缓存，不让共享缓存长期保留
+		c.Header("Cache-Control", "private, max-age=600")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.DataFromReader(200, -1, contentType, body, nil)
+	}
+}
+
+// 删除自己上传的图片
+func DeleteAttachment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		id, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+		attachment, err := repository.GetAttachmentByID(id)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "附件不存在"})
+			return
+		}
+		if attachment.UserID != userID {
+			c.JSON(403, gin.H{"error": "无权删除此附件"})
+			return
+		}
+		if err := removeAttachment(c.Request.Context(), attachment); err != nil {
+			c.JSON(500, gin.H{"error": "删除附件失败,请重新再试..."})
+			utils.LogError("删除附件失败", logrus.Fields{"attachment_id": id, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// 删除存储中的原图、缩略图和附件记录
+func removeAttachment(ctx context.Context, attachment model.Attachment) error {
+	store := getBlobStore()
+	if err := store.Delete(ctx, attachmentKeyPrefix+attachment.Key); err != nil {
+		return err
+	}
+	if attachment.ThumbKey != "" {
+		if err := store.Delete(ctx, attachmentKeyPrefix+attachment.ThumbKey); err != nil {
+			return err
+		}
+	}
+	return repository.DeleteAttachment(attachment.ID)
+}
+
+// 定时清理上传后一直未被引用（或所属内容已删除）的附件
+func CleanupStaleAttachments() {
+	attachments, err := repository.GetStaleAttachments(time.Now().Add(-staleAttachmentAge), 500)
+	if err != nil {
+		utils.LogError("获取待清理附件失败", logrus.Fields{"error": err.Error()})
+		return
+	}
+	removed := 0
+	for _, a := range attachments {
+		if err := removeAttachment(context.Background(), a); err != nil {
+			utils.LogError("清理附件失败", logrus.Fields{"attachment_id": a.ID, "error": err.Error()})
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		utils.LogInfo("清理未引用附件完成", logrus.Fields{"count": removed})
+	}
+}
@@ -1,14 +1,31 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
+const avatarKeyPrefix = "avatars/"
+
+// 自定义头像生成的尺寸，AvatarURL 默认使用大图
+var avatarSizes = []int{utils.AvatarSizeLarge, utils.AvatarSizeSmall}
+
+// 头像文件名：<用户ID>_<随机串>_<尺寸>.jpg
+var avatarFilePattern = regexp.MustCompile(`^[0-9]+_[0-9a-f]{32}_(256|64)\.jpg$`)
+
 // ServeAvatar 返回用户头像图片，路径按优先级查找：
 // 1. ../frontend/src/assets/head/<file>
 // 2. ./assets/head/<file>
@@ -83,3 +100,145 @@ func ServeAvatar() gin.HandlerFunc {
 		c.JSON(http.StatusNotFound, gin.H{"error": "avatar not found"})
 	}
 }
+
+// 上传自定义头像：按 x/y/size 裁成正方形（不传时居中裁剪），缩放为标准尺寸后保存
+func UploadAvatar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		_, _, img, ok := readImageUpload(c)
+		if !ok {
+			return
+		}
+		bounds := img.Bounds()
+		crop := utils.CenterSquare(bounds)
+		if c.PostForm("size") != "" {
+			x, errX := strconv.Atoi(c.PostForm("x"))
+			y, errY := strconv.Atoi(c.PostForm("y"))
+			size, errS := strconv.Atoi(c.PostForm("size"))
+			crop = image.Rect(x, y, x+size, y+size).Add(bounds.Min)
+			if errX != nil || errY != nil || errS != nil || size <= 0 || !crop.In(bounds) {
+				c.JSON(400, gin.H{"error": "裁剪区域无效，需在图片范围内"})
+				return
+			}
+		}
+		square := utils.Crop(img, crop)
+
+		name, err := randomFileName()
+		if err != nil {
+			c.JSON(500, gin.H{"error": "上传头像失败,请重新再试..."})
+			utils.LogError("生成文件名失败", logrus.Fields{"error": err.Error()})
+			return
+		}
+		key := strconv.FormatUint(uint64(userID), 10) + "_" + name
+		store := getBlobStore()
+		ctx := c.Request.Context()
+		urls := gin.H{}
+		for _, size := range avatarSizes {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, utils.Resize(square, size, size), &jpeg.Options{Quality: 85}); err != nil {
+				removeAvatarFiles(ctx, key)
+				c.JSON(500, gin.H{"error": "上传头像失败,请重新再试..."})
+				utils.LogError("生成头像失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+				return
+			}
+			if err := store.Put(ctx, avatarKeyPrefix+avatarFileName(key, size), buf.Bytes(), "image/jpeg"); err != nil {
+				removeAvatarFiles(ctx, key)
+				c.JSON(500, gin.H{"error": "上传头像失败,请重新再试..."})
+				utils.LogError("保存头像失败", logrus.Fields{"user_id": userID, "store": store.Name(), "error": err.Error()})
+				return
+			}
+			urls[strconv.Itoa(size)] = utils.GetCustomAvatarPath(key, size)
+		}
+
+		user, err := repository.GetUserByID(userID)
+		if err != nil {
+			removeAvatarFiles(ctx, key)
+			c.JSON(500, gin.H{"error": "上传头像失败,请重新再试..."})
+			utils.LogError("获取用户失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+			return
+		}
+		if err := repository.UpdateUserAvatarKey(userID, key); err != nil {
+			removeAvatarFiles(ctx, key)
+			c.JSON(500, gin.H{"error": "上传头像失败,请重新再试..."})
+			utils.LogError("更新用户头像失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+			return
+		}
+		removeAvatarFiles(ctx, user.AvatarKey)
+		user.AvatarKey = key
+		utils.LogInfo("用户上传头像成功", logrus.Fields{"user_id": userID})
+		c.JSON(200, gin.H{"success": true, "avatar": user.AvatarURL(), "sizes": urls})
+	}
+}
+
+// 删除自定义头像，恢复为 head_show 对应的内置头像
+func DeleteAvatar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		user, err := repository.GetUserByID(userID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "恢复头像失败,请重新再试..."})
+			utils.LogError("获取用户失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+			return
+		}
+		if user.AvatarKey != "" {
+			if err := repository.UpdateUserAvatarKey(userID, ""); err != nil {
+				c.JSON(500, gin.H{"error": "恢复头像失败,请重新再试..."})
+				utils.LogError("更新用户头像失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+				return
+			}
+			removeAvatarFiles(c.Request.Context(), user.AvatarKey)
+			user.AvatarKey = ""
+		}
+		c.JSON(200, gin.H{"success": true, "avatar": user.AvatarURL()})
+	}
+}
+
+// 读取自定义头像（文件名每次上传都会变化，可以长期缓存）
+func ServeCustomAvatar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if !avatarFilePattern.MatchString(name) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "avatar not found"})
+			return
+		}
+		body, err := getBlobStore().Get(c.Request.Context(), avatarKeyPrefix+name)
+		if errors.Is(err, ErrBlobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "avatar not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "读取头像失败,请重新再试..."})
+			utils.LogError("读取头像失败", logrus.Fields{"name": name, "error": err.Error()})
+			return
+		}
+		defer body.Close()
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.DataFromReader(200, -1, "image/jpeg", body, nil)
+	}
+}
+
+func avatarFileName(key string, size int) string {
+	return key + "_" + strconv.Itoa(size) + ".jpg"
+}
+
+// 删除某次上传生成的全部尺寸（替换或恢复头像后调用，失败只记录日志）
+func removeAvatarFiles(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	store := getBlobStore()
+	for _, size := range avatarSizes {
+		if err := store.Delete(ctx, avatarKeyPrefix+avatarFileName(key, size)); err != nil {
+			utils.LogError("删除旧头像失败", logrus.Fields{"key": key, "size": size, "error": err.Error()})
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/sirupsen/logrus"
+)
+
+var ErrBlobNotFound = errors.New("文件不存在")
+
+// 对象存储抽象：本地目录或 S3 兼容服务（AWS S3、MinIO 等）
+type BlobStore interface {
+	Name() string
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// 不存在时返回 ErrBlobNotFound
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+var (
+	blobStore     BlobStore
+	blobStoreOnce sync.Once
+)
+
+// 全局存储（延迟初始化，等待 .env 加载）
+func getBlobStore() BlobStore {
+	blobStoreOnce.Do(func() {
+		if blobStore == nil {
+			blobStore = NewBlobStoreFromEnv()
+		}
+		utils.LogInfo("附件存储初始化完成", logrus.Fields{"store": blobStore.Name()})
+	})
+	return blobStore
+}
+
+// 替换全局存储（本地开发或测试时注入）
+func SetBlobStore(store BlobStore) {
+	blobStore = store
+}
+
+// 根据环境变量创建存储
+// STORAGE_DRIVER=s3 时使用 S3 兼容存储，其余情况使用本地目录 UPLOAD_DIR（默认 ./uploads）
+func NewBlobStoreFromEnv() BlobStore {
+	if strings.EqualFold(os.Getenv("STORAGE_DRIVER"), "s3") {
+		pathStyle := true
+		if v, err := strconv.ParseBool(os.Getenv("S3_USE_PATH_STYLE")); err == nil {
+			pathStyle = v
+		}
+		region := os.Getenv("S3_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		return NewS3BlobStore(os.Getenv("S3_ENDPOINT"), region, os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"), pathStyle)
+	}
+	dir := os.Getenv("UPLOAD_DIR")
+	if dir == "" {
+		dir = "./uploads"
+	}
+	return NewLocalBlobStore(dir)
+}
+
+// 本地目录存储
+type LocalBlobStore struct {
+	Root string
+}
+
+func NewLocalBlobStore(root string) *LocalBlobStore {
+	return &LocalBlobStore{Root: root}
+}
+
+func (s *LocalBlobStore) Name() string {
+	return "local"
+}
+
+func (s *LocalBlobStore) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if clean == "." || filepath.IsAbs(clean) || strings.HasPrefix(clean, "..") {
+		return "", fmt.Errorf("非法的文件名: %s", key)
+	}
+	return filepath.Join(s.Root, clean), nil
+}
+
+// 先写临时文件再重命名，避免读到写了一半的文件
+func (s *LocalBlobStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *LocalBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+	return f, err
+}
+
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// S3 兼容存储，使用 AWS Signature V4 签名；MinIO 等自建服务一般需要 path-style 地址
+type S3BlobStore struct {
+	Endpoint  *url.URL
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool
+	Client    *http.Client
+}
+
+func NewS3BlobStore(endpoint, region, bucket, accessKey, secretKey string, pathStyle bool) *S3BlobStore {
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil || u.Host == "" {
+		utils.LogError("S3_ENDPOINT 配置无效", logrus.Fields{"endpoint": endpoint})
+		u = &url.URL{Scheme: "https", Host: "s3." + region + ".amazonaws.com"}
+	}
+	return &S3BlobStore{
+		Endpoint:  u,
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PathStyle: pathStyle,
+		Client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *S3BlobStore) Name() string {
+	return "s3"
+}
+
+// 对象地址：path-style 为 endpoint/bucket/key，否则为 bucket.endpoint/key
+func (s *S3BlobStore) objectURL(key string) *url.URL {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	u := *s.Endpoint
+	if s.PathStyle {
+		u.RawPath = u.Path + "/" + url.PathEscape(s.Bucket) + "/" + strings.Join(segments, "/")
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.RawPath = u.Path + "/" + strings.Join(segments, "/")
+	}
+	u.Path, _ = url.PathUnescape(u.RawPath)
+	return &u
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// 按 SigV4 给请求签名（不带查询参数）
+func (s *S3BlobStore) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	names := []string{"host"}
+	values := map[string]string{"host": req.URL.Host}
+	if ct := req.Header.Get("Content-Type"); ct != "" {
+		names = append([]string{"content-type"}, names...)
+		values["content-type"] = ct
+	}
+	names = append(names, "x-amz-content-sha256", "x-amz-date")
+	values["x-amz-content-sha256"] = payloadHash
+	values["x-amz-date"] = amzDate
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(values[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	signingKey := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, s.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+func (s *S3BlobStore) do(ctx context.Context, method, key string, data []byte, contentType string) (*http.Response, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	s.sign(req, sha256Hex(data), time.Now())
+	return s.Client.Do(req)
+}
+
+// 读取错误响应的前一段内容，便于排查签名或权限问题
+func s3Error(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("S3 请求失败: %s %s", resp.Status, strings.TrimSpace(string(msg)))
+}
+
+func (s *S3BlobStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if data == nil {
+		data = []byte{}
+	}
+	resp, err := s.do(ctx, http.MethodPut, key, data, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+func (s *S3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrBlobNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, s3Error(resp)
+	}
+}
+
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3Error(resp)
+	}
+	return nil
+}
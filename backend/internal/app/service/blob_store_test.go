@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocalBlobStorePath(t *testing.T) {
+	root := t.TempDir()
+	s := NewLocalBlobStore(root)
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "attachments/a.jpg", want: filepath.Join(root, "attachments", "a.jpg")},
+		{key: "attachments/../b.jpg", want: filepath.Join(root, "b.jpg")},
+		{key: "./a.jpg", want: filepath.Join(root, "a.jpg")},
+		{key: "", wantErr: true},
+		{key: ".", wantErr: true},
+		{key: "..", wantErr: true},
+		{key: "../secret", wantErr: true},
+		{key: "attachments/../../secret", wantErr: true},
+		{key: "/etc/passwd", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := s.path(tt.key)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("path(%q) = %q, want error", tt.key, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("path(%q) = %q, %v; want %q", tt.key, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalBlobStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := NewLocalBlobStore(t.TempDir())
+	if err := s.Put(ctx, "attachments/x.png", []byte("data"), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	rc, err := s.Get(ctx, "attachments/x.png")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "data" {
+		t.Errorf("Get = %q, want %q", data, "data")
+	}
+	if err := s.Delete(ctx, "attachments/x.png"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Get(ctx, "attachments/x.png"); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrBlobNotFound", err)
+	}
+	if err := s.Delete(ctx, "attachments/x.png"); err != nil {
+		t.Errorf("Delete of missing file = %v, want nil", err)
+	}
+	if err := s.Put(ctx, "../escape", []byte("x"), ""); err == nil {
+		t.Error("Put outside root succeeded, want error")
+	}
+}
+
+func TestS3ObjectURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoint  string
+		pathStyle bool
+		key       string
+		want      string
+	}{
+		{name: "path style", endpoint: "http://minio:9000", pathStyle: true, key: "attachments/a.jpg", want: "http://minio:9000/uploads/attachments/a.jpg"},
+		{name: "virtual host", endpoint: "https://s3.us-east-1.amazonaws.com", key: "attachments/a.jpg", want: "https://uploads.s3.us-east-1.amazonaws.com/attachments/a.jpg"},
+		{name: "escaped key", endpoint: "http://minio:9000/", pathStyle: true, key: "a b/头像+1.png", want: "http://minio:9000/uploads/a%20b/%E5%A4%B4%E5%83%8F+1.png"},
+		{name: "endpoint with path", endpoint: "http://gw/s3", pathStyle: true, key: "k", want: "http://gw/s3/uploads/k"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewS3BlobStore(tt.endpoint, "us-east-1", "uploads", "AK", "SK", tt.pathStyle)
+			if got := s.objectURL(tt.key).String(); got != tt.want {
+				t.Errorf("objectURL(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+// 独立按 SigV4 规范计算签名，与 sign 的结果对照
+func expectedS3Signature(secret, region, amzDate, canonicalRequest string) string {
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	date := amzDate[:8]
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + date + "/" + region + "/s3/aws4_request\n" + hex.EncodeToString(hash[:])
+	key := mac(mac(mac(mac([]byte("AWS4"+secret), date), region), "s3"), "aws4_request")
+	return hex.EncodeToString(mac(key, stringToSign))
+}
+
+func TestS3Sign(t *testing.T) {
+	s := NewS3BlobStore("http://minio:9000", "cn-north-1", "uploads", "AKIDEXAMPLE", "secret", true)
+	now := time.Date(2024, 3, 5, 6, 7, 8, 0, time.FixedZone("CST", 8*3600))
+	body := []byte("hello")
+	payloadHash := sha256Hex(body)
+
+	tests := []struct {
+		name             string
+		method           string
+		key              string
+		contentType      string
+		payloadHash      string
+		signedHeaders    string
+		canonicalRequest string
+	}{
+		{
+			name:          "put with content type",
+			method:        http.MethodPut,
+			key:           "attachments/a b.png",
+			contentType:   "image/png",
+			payloadHash:   payloadHash,
+			signedHeaders: "content-type;host;x-amz-content-sha256;x-amz-date",
+			canonicalRequest: "PUT\n/uploads/attachments/a%20b.png\n\n" +
+				"content-type:image/png\nhost:minio:9000\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:20240304T220708Z\n\n" +
+				"content-type;host;x-amz-content-sha256;x-amz-date\n" + payloadHash,
+		},
+		{
+			name:          "get without content type",
+			method:        http.MethodGet,
+			key:           "attachments/a.png",
+			payloadHash:   sha256Hex(nil),
+			signedHeaders: "host;x-amz-content-sha256;x-amz-date",
+			canonicalRequest: "GET\n/uploads/attachments/a.png\n\n" +
+				"host:minio:9000\nx-amz-content-sha256:" + sha256Hex(nil) + "\nx-amz-date:20240304T220708Z\n\n" +
+				"host;x-amz-content-sha256;x-amz-date\n" + sha256Hex(nil),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, s.objectURL(tt.key).String(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			s.sign(req, tt.payloadHash, now)
+
+			if got := req.Header.Get("X-Amz-Date"); got != "20240304T220708Z" {
+				t.Errorf("X-Amz-Date = %q, want UTC time", got)
+			}
+			if got := req.Header.Get("X-Amz-Content-Sha256"); got != tt.payloadHash {
+				t.Errorf("X-Amz-Content-Sha256 = %q, want %q", got, tt.payloadHash)
+			}
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20240304/cn-north-1/s3/aws4_request, SignedHeaders=" + tt.signedHeaders +
+				", Signature=" + expectedS3Signature("secret", "cn-north-1", "20240304T220708Z", tt.canonicalRequest)
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
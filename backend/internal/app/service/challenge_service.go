@@ -0,0 +1,476 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 挑战赛状态
+const (
+	challengeStatusUpcoming = "upcoming"
+	challengeStatusActive   = "active"
+	challengeStatusEnded    = "ended"
+)
+
+const defaultBadgeIcon = "🏅"
+
+// 挑战赛当前状态
+func challengeStatus(ch model.Challenge, now time.Time) string {
+	switch {
+	case now.Before(ch.StartDate):
+		return challengeStatusUpcoming
+	case now.Before(ch.Deadline()):
+		return challengeStatusActive
+	default:
+		return challengeStatusEnded
+	}
+}
+
+// 挑战赛只支持打卡天数、学习时长和flag完成次数
+func isValidChallengeMetric(metric string) bool {
+	return metric == model.GoalMetricDakaDays || metric == model.GoalMetricLearnMinutes || metric == model.GoalMetricFlagCompletions
+}
+
+// 统计区间：开始日期到 min(现在, 结束日期次日)
+func challengeWindowEnd(ch model.Challenge, now time.Time) time.Time {
+	if now.Before(ch.Deadline()) {
+		return now
+	}
+	return ch.Deadline()
+}
+
+// 查询一组参与者当前的进度
+func challengeScores(ch model.Challenge, userIDs []uint, now time.Time) (map[uint]int, error) {
+	return repository.GetGoalMetricScores(ch.Metric, ch.Label, userIDs, ch.StartDate, challengeWindowEnd(ch, now))
+}
+
+// 挑战赛展示信息
+func challengeView(ch model.Challenge, participants int64, mine *model.ChallengeParticipant, now time.Time) gin.H {
+	view := gin.H{
+		"challenge":    ch,
+		"status":       challengeStatus(ch, now),
+		"participants": participants,
+		"joined":       mine != nil,
+	}
+	if mine != nil {
+		view["progress"] = mine.Progress
+		view["completed_at"] = mine.CompletedAt
+	}
+	return view
+}
+
+// 解析路径中的挑战赛
+func loadChallenge(c *gin.Context) (model.Challenge, bool) {
+	challengeID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "无效的挑战赛ID"})
+		return model.Challenge{}, false
+	}
+	ch, err := repository.GetChallengeByID(uint(challengeID))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "挑战赛不存在"})
+		return model.Challenge{}, false
+	}
+	return ch, true
+}
+
+// 挑战赛列表：status=upcoming|active|ended，默认返回未结束的
+func GetChallenges() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		status := c.Query("status")
+		if status != "" && status != challengeStatusUpcoming && status != challengeStatusActive && status != challengeStatusEnded {
+			c.JSON(400, gin.H{"error": "status 只能是 upcoming/active/ended"})
+			return
+		}
+		page, limit := parsePagination(c)
+		now := time.Now()
+		challenges, total, err := repository.GetChallenges(status, now, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取挑战赛失败,请重新再试..."})
+			utils.LogError("获取挑战赛列表失败", logrus.Fields{"error": err.Error()})
+			return
+		}
+		ids := make([]uint, 0, len(challenges))
+		for _, ch := range challenges {
+			ids = append(ids, ch.ID)
+		}
+		counts, _ := repository.CountParticipantsByChallenges(ids)
+		joined, _ := repository.GetUserParticipations(id, ids)
+		items := make([]gin.H, 0, len(challenges))
+		for _, ch := range challenges {
+			var mine *model.ChallengeParticipant
+			if p, ok := joined[ch.ID]; ok {
+				mine = &p
+			}
+			items = append(items, challengeView(ch, counts[ch.ID], mine, now))
+		}
+		c.JSON(200, gin.H{"success": true, "data": items, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// 挑战赛详情（已参加时返回实时进度）
+func GetChallenge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		ch, ok := loadChallenge(c)
+		if !ok {
+			return
+		}
+		now := time.Now()
+		counts, _ := repository.CountParticipantsByChallenges([]uint{ch.ID})
+		var mine *model.ChallengeParticipant
+		if p, err := repository.GetChallengeParticipant(ch.ID, id); err == nil {
+			if challengeStatus(ch, now) != challengeStatusUpcoming && p.CompletedAt == nil {
+				if scores, err := challengeScores(ch, []uint{id}, now); err == nil {
+					p.Progress = scores[id]
+				}
+			}
+			mine = &p
+		}
+		c.JSON(200, gin.H{"success": true, "data": challengeView(ch, counts[ch.ID], mine, now)})
+	}
+}
+
+// 参加挑战赛（结束前都可以参加）
+func JoinChallenge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		ch, ok := loadChallenge(c)
+		if !ok {
+			return
+		}
+		now := time.Now()
+		if challengeStatus(ch, now) == challengeStatusEnded {
+			c.JSON(400, gin.H{"error": "挑战赛已结束"})
+			return
+		}
+		if _, err := repository.GetChallengeParticipant(ch.ID, id); err == nil {
+			c.JSON(400, gin.H{"error": "你已参加该挑战赛"})
+			return
+		}
+		participant := model.ChallengeParticipant{ChallengeID: ch.ID, UserID: id}
+		if challengeStatus(ch, now) == challengeStatusActive {
+			if scores, err := challengeScores(ch, []uint{id}, now); err == nil {
+				participant.Progress = scores[id]
+			}
+		}
+		if err := repository.SaveChallengeParticipant(&participant); err != nil {
+			c.JSON(500, gin.H{"error": "参加挑战赛失败,请重新再试..."})
+			utils.LogError("参加挑战赛失败", logrus.Fields{"challenge_id": ch.ID, "user_id": id, "error": err.Error()})
+			return
+		}
+		utils.LogInfo("参加挑战赛", logrus.Fields{"challenge_id": ch.ID, "user_id": id})
+		c.JSON(200, gin.H{"success": true, "progress": participant.Progress})
+	}
+}
+
+// 退出挑战赛（已完成的不能退出）
+func LeaveChallenge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		ch, ok := loadChallenge(c)
+		if !ok {
+			return
+		}
+		p, err := repository.GetChallengeParticipant(ch.ID, id)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "你没有参加该挑战赛"})
+			return
+		}
+		if p.CompletedAt != nil {
+			c.JSON(400, gin.H{"error": "已完成的挑战赛不能退出"})
+			return
+		}
+		if err := repository.DeleteChallengeParticipant(ch.ID, id); err != nil {
+			c.JSON(500, gin.H{"error": "退出挑战赛失败,请重新再试..."})
+			utils.LogError("退出挑战赛失败", logrus.Fields{"challenge_id": ch.ID, "user_id": id, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// 挑战赛参与者排行（进度为每日评估结果）
+func GetChallengeParticipants() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ch, ok := loadChallenge(c)
+		if !ok {
+			return
+		}
+		page, limit := parsePagination(c)
+		offset := (page - 1) * limit
+		participants, total, err := repository.GetChallengeParticipants(ch.ID, offset, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取参与者失败,请重新再试..."})
+			utils.LogError("获取挑战赛参与者失败", logrus.Fields{"challenge_id": ch.ID, "error": err.Error()})
+			return
+		}
+		ids := make([]uint, 0, len(participants))
+		for _, p := range participants {
+			ids = append(ids, p.UserID)
+		}
+		briefs, _ := loadUserBriefs(ids)
+		items := make([]gin.H, 0, len(participants))
+		for i, p := range participants {
+			items = append(items, gin.H{
+				"rank":         offset + i + 1,
+				"user":         briefs[p.UserID],
+				"progress":     p.Progress,
+				"target":       ch.Target,
+				"completed_at": p.CompletedAt,
+			})
+		}
+		c.JSON(200, gin.H{"success": true, "data": items, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// 我获得的挑战赛徽章
+func GetMyChallengeBadges() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		badges, err := repository.GetChallengeBadgesByUserID(id)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取徽章失败,请重新再试..."})
+			utils.LogError("获取挑战赛徽章失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "data": badges})
+	}
+}
+
+// ---------- 管理员接口 ----------
+
+type challengeRequest struct {
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	Metric       string `json:"metric"`
+	Label        int    `json:"label"`
+	Target       int    `json:"target"`
+	StartDate    string `json:"start_date"` // 2006-01-02
+	EndDate      string `json:"end_date"`   // 2006-01-02，当天计入
+	RewardPoints int    `json:"reward_points"`
+	BadgeName    string `json:"badge_name"`
+	BadgeIcon    string `json:"badge_icon"`
+}
+
+// 校验请求并写入挑战赛
+func (req challengeRequest) applyTo(c *gin.Context, ch *model.Challenge) bool {
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" || len([]rune(req.Title)) > 100 {
+		c.JSON(400, gin.H{"error": "标题不能为空且不超过100字"})
+		return false
+	}
+	if !isValidChallengeMetric(req.Metric) {
+		c.JSON(400, gin.H{"error": "metric 只能是 daka_days/learn_minutes/flag_completions"})
+		return false
+	}
+	if req.Label < 0 || req.Label > 5 || (req.Label > 0 && req.Metric != model.GoalMetricFlagCompletions) {
+		c.JSON(400, gin.H{"error": "label 只能用于 flag_completions，取值 1-5"})
+		return false
+	}
+	if req.Target <= 0 || req.RewardPoints < 0 {
+		c.JSON(400, gin.H{"error": "目标值必须大于0，奖励积分不能为负"})
+		return false
+	}
+	start, err := time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "开始日期格式应为 YYYY-MM-DD"})
+		return false
+	}
+	end, err := time.ParseInLocation("2006-01-02", req.EndDate, time.Local)
+	if err != nil || end.Before(start) {
+		c.JSON(400, gin.H{"error": "结束日期格式应为 YYYY-MM-DD 且不早于开始日期"})
+		return false
+	}
+	ch.Title = req.Title
+	ch.Description = req.Description
+	ch.Metric = req.Metric
+	ch.Label = req.Label
+	ch.Target = req.Target
+	ch.StartDate = start
+	ch.EndDate = end
+	ch.RewardPoints = req.RewardPoints
+	ch.BadgeName = strings.TrimSpace(req.BadgeName)
+	if ch.BadgeName == "" {
+		ch.BadgeName = req.Title
+	}
+	ch.BadgeIcon = req.BadgeIcon
+	if ch.BadgeIcon == "" {
+		ch.BadgeIcon = defaultBadgeIcon
+	}
+	// 修改结束日期后需要重新结算
+	if ch.SettledAt != nil && time.Now().Before(ch.Deadline()) {
+		ch.SettledAt = nil
+	}
+	return true
+}
+
+// 创建挑战赛（管理员）
+func CreateChallenge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		var req challengeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		ch := model.Challenge{CreatedBy: id}
+		if !req.applyTo(c, &ch) {
+			return
+		}
+		if err := repository.SaveChallenge(&ch); err != nil {
+			c.JSON(500, gin.H{"error": "创建挑战赛失败,请重新再试..."})
+			utils.LogError("创建挑战赛失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		utils.LogInfo("创建挑战赛成功", logrus.Fields{"challenge_id": ch.ID, "user_id": id})
+		c.JSON(200, gin.H{"success": true, "data": ch})
+	}
+}
+
+// 修改挑战赛（管理员）
+func UpdateChallenge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ch, ok := loadChallenge(c)
+		if !ok {
+			return
+		}
+		var req challengeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		if !req.applyTo(c, &ch) {
+			return
+		}
+		if err := repository.SaveChallenge(&ch); err != nil {
+			c.JSON(500, gin.H{"error": "修改挑战赛失败,请重新再试..."})
+			utils.LogError("修改挑战赛失败", logrus.Fields{"challenge_id": ch.ID, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "data": ch})
+	}
+}
+
+// 删除挑战赛（管理员）
+func DeleteChallenge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ch, ok := loadChallenge(c)
+		if !ok {
+			return
+		}
+		if err := repository.DeleteChallenge(ch.ID); err != nil {
+			c.JSON(500, gin.H{"error": "删除挑战赛失败,请重新再试..."})
+			utils.LogError("删除挑战赛失败", logrus.Fields{"challenge_id": ch.ID, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// 立即评估挑战赛进度（管理员）
+func EvaluateChallenge() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ch, ok := loadChallenge(c)
+		if !ok {
+			return
+		}
+		completed, err := evaluateChallenge(ch, time.Now())
+		if err != nil {
+			c.JSON(500, gin.H{"error": "评估挑战赛失败,请重新再试..."})
+			utils.LogError("评估挑战赛失败", logrus.Fields{"challenge_id": ch.ID, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "completed": completed})
+	}
+}
+
+// ---------- 定时评估 ----------
+
+// 评估挑战赛所有参与者的进度，达标者发放积分和徽章；挑战结束后标记为已结算
+func evaluateChallenge(ch model.Challenge, now time.Time) (int, error) {
+	participants, err := repository.GetAllChallengeParticipants(ch.ID)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]uint, 0, len(participants))
+	for _, p := range participants {
+		ids = append(ids, p.UserID)
+	}
+	scores, err := challengeScores(ch, ids, now)
+	if err != nil {
+		return 0, err
+	}
+
+	completed := 0
+	for _, p := range participants {
+		progress := scores[p.UserID]
+		if p.CompletedAt != nil {
+			continue
+		}
+		if progress < ch.Target {
+			if progress != p.Progress {
+				if err := repository.UpdateChallengeProgress(p.ID, progress); err != nil {
+					utils.LogError("更新挑战赛进度失败", logrus.Fields{"challenge_id": ch.ID, "user_id": p.UserID, "error": err.Error()})
+				}
+			}
+			continue
+		}
+		p.Progress = progress
+		badge := model.ChallengeBadge{UserID: p.UserID, ChallengeID: ch.ID, Name: ch.BadgeName, Icon: ch.BadgeIcon}
+		done, err := repository.CompleteChallenge(p, &badge, now)
+		if err != nil {
+			utils.LogError("发放挑战赛徽章失败", logrus.Fields{"challenge_id": ch.ID, "user_id": p.UserID, "error": err.Error()})
+			continue
+		}
+		if !done {
+			continue
+		}
+		completed++
+		if ch.RewardPoints > 0 {
+			if err := AwardPoints(p.UserID, ch.RewardPoints); err != nil {
+				utils.LogError("发放挑战赛积分失败", logrus.Fields{"challenge_id": ch.ID, "user_id": p.UserID, "error": err.Error()})
+			}
+		}
+		manager.SendToUser(p.UserID, gin.H{"type": "challenge_completed", "data": gin.H{
+			"challenge_id": ch.ID,
+			"title":        ch.Title,
+			"badge":        badge,
+			"points":       ch.RewardPoints,
+		}})
+		utils.LogInfo("完成挑战赛", logrus.Fields{"challenge_id": ch.ID, "user_id": p.UserID, "progress": progress})
+	}
+
+	if !now.Before(ch.Deadline()) {
+		if err := repository.MarkChallengeSettled(ch.ID, now); err != nil {
+			return completed, err
+		}
+	}
+	return completed, nil
+}
+
+// 每日评估所有进行中和刚结束的挑战赛
+func RunChallengeJob() {
+	now := time.Now()
+	challenges, err := repository.GetChallengesToEvaluate(now)
+	if err != nil {
+		utils.LogError("获取待评估挑战赛失败", logrus.Fields{"error": err.Error()})
+		return
+	}
+	for _, ch := range challenges {
+		completed, err := evaluateChallenge(ch, now)
+		if err != nil {
+			utils.LogError("评估挑战赛失败", logrus.Fields{"challenge_id": ch.ID, "error": err.Error()})
+			continue
+		}
+		utils.LogInfo("评估挑战赛完成", logrus.Fields{"challenge_id": ch.ID, "completed": completed})
+	}
+}
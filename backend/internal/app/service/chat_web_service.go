@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -30,13 +31,17 @@ type Client struct {
 }
 
 type Message struct {
-	FromID     uint      `json:"from"`
-	ToID       uint      `json:"to"`
-	Content    string    `json:"content"`
-	CreatedAt  time.Time `json:"created_at"`
-	RoomID     string    `json:"room_id"`
-	UserName   string    `json:"user_name"`
-	UserAvatar string    `json:"user_avatar"`
+	FromID        uint               `json:"from"`
+	ToID          uint               `json:"to"`
+	Content       string             `json:"content"`
+	CreatedAt     time.Time          `json:"created_at"`
+	RoomID        string             `json:"room_id"`
+	UserName      string             `json:"user_name"`
+	UserAvatar    string             `json:"user_avatar"`
+	AttachmentIDs []uint             `json:"attachment_ids,omitempty"` // 发送时携带已上传的图片附件ID
+	Attachments   []model.Attachment `json:"attachments,omitempty"`
+	Mentions      []UserBrief        `json:"mentions,omitempty"` // 消息中 @ 到的用户
+	Tags          []string           `json:"tags,omitempty"`     // 消息中的话题（聊天消息不进入话题索引）
 }
 
 type ChatRoom struct {
@@ -115,6 +120,12 @@ func WsHandler() gin.HandlerFunc {
 				return
 			}
 
+			// 小组聊天室只允许小组成员进入
+			if isGroupRoom(roomID) && !canJoinGroupRoom(roomID, id) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "只有小组成员可以进入小组聊天室"})
+				return
+			}
+
 			// 检查房间人数限制
 			manager.mu.RLock()
 			roomFull := len(room.Clients) >= room.MaxUsers
@@ -254,7 +265,70 @@ func (manager *Manager) Start() {
 	}
 }
 
-// 清理空房间（10小时无人则删除，默认房间除外）
+// 用户当前是否有 WebSocket 连接
+func (manager *Manager) IsOnline(userID uint) bool {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	_, ok := manager.GlobalClients[userID]
+	return ok
+}
+
+// 向在线用户推送一条消息（用户不在线或发送队列已满时返回 false）
+func (manager *Manager) SendToUser(userID uint, payload interface{}) bool {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return false
+	}
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	client, ok := manager.GlobalClients[userID]
+	if !ok {
+		return false
+	}
+	select {
+	case client.Send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+// 向房间内所有在线用户推送一条消息
+func (manager *Manager) SendToRoom(roomID string, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	room, ok := manager.Rooms[roomID]
+	if !ok {
+		return
+	}
+	for _, client := range room.Clients {
+		select {
+		case client.Send <- data:
+		default:
+		}
+	}
+}
+
+// 把用户移出房间并断开其连接（如被移出小组），断开后由 ReadPump 完成注销
+func (manager *Manager) EvictFromRoom(roomID string, userID uint) {
+	manager.mu.Lock()
+	var client *Client
+	if room, ok := manager.Rooms[roomID]; ok {
+		if client, ok = room.Clients[userID]; ok {
+			delete(room.Clients, userID)
+		}
+	}
+	manager.mu.Unlock()
+	if client != nil {
+		client.Conn.Close()
+	}
+}
+
+// 清理空房间（10小时无人则删除，默认房间和小组聊天室除外）
 func (manager *Manager) CleanupEmptyRooms() {
 	ticker := time.NewTicker(30 * time.Minute)
 	defer ticker.Stop()
@@ -264,7 +338,7 @@ func (manager *Manager) CleanupEmptyRooms() {
 		now := time.Now()
 		for roomID, room := range manager.Rooms {
 			// 跳过默认房间
-			if roomID == "room-1" || roomID == "room-2" || roomID == "room-3" {
+			if roomID == "room-1" || roomID == "room-2" || roomID == "room-3" || isGroupRoom(roomID) {
 				continue
 			}
 			// 如果房间为空且超过10小时无活动，删除房间
@@ -299,14 +373,58 @@ func ReadPump(client *Client) {
 		message.RoomID = client.RoomID
 		message.CreatedAt = time.Now()
 
+		// 封禁期间不能发言
+		if until, err := repository.GetUserSuspendedUntil(client.ID); err == nil && isSuspended(until) {
+			notice, _ := json.Marshal(gin.H{"type": "suspended", "error": "账号已被封禁，暂时不能发言", "suspended_until": until})
+			select {
+			case client.Send <- notice:
+			default:
+			}
+			continue
+		}
+
+		// 内容审核：拒绝的消息只通知发送者，不保存也不广播
+		moderation := moderateText(context.Background(), sceneChat, client.ID, message.Content)
+		if moderation.Action == ModerationBlock {
+			notice, _ := json.Marshal(gin.H{"type": "moderation", "error": "消息包含违规信息，未发送"})
+			select {
+			case client.Send <- notice:
+			default:
+			}
+			continue
+		}
+		message.Content = moderation.Text
+
+		// 附件必须是自己上传且未被使用的
+		if len(message.AttachmentIDs) > 0 {
+			count, err := repository.CountUnboundAttachments(client.ID, message.AttachmentIDs)
+			if err != nil || len(message.AttachmentIDs) > maxAttachmentsPerItem || int(count) != len(message.AttachmentIDs) {
+				notice, _ := json.Marshal(gin.H{"type": "attachment", "error": "附件不存在或已被使用，未发送"})
+				select {
+				case client.Send <- notice:
+				default:
+				}
+				continue
+			}
+		}
+
+		// 私信：任意一方拉黑对方时不发送
+		if message.ToID != 0 {
+			if blocked, err := repository.IsBlockedBetween(client.ID, message.ToID); err == nil && blocked {
+				notice, _ := json.Marshal(gin.H{"type": "blocked", "error": "对方已不接收你的私信"})
+				select {
+				case client.Send <- notice:
+				default:
+				}
+				continue
+			}
+		}
+
 		// 获取发送者用户信息
 		user, err := repository.GetUserByID(client.ID)
 		if err == nil {
 			message.UserName = user.Name
-			if user.HeadShow > 0 && user.HeadShow <= 6 {
-				avatarFiles := []string{"131601", "131629", "131937", "131951", "132014", "133459"}
-				message.UserAvatar = "/src/assets/images/screenshot_20251114_" + avatarFiles[user.HeadShow-1] + ".png"
-			}
+			message.UserAvatar = user.AvatarURL()
 		}
 
 		// 保存消息到数据库
@@ -321,6 +439,27 @@ func ReadPump(client *Client) {
 		if err != nil {
 			utils.LogError("保存聊天消息失败", map[string]interface{}{"error": err.Error(), "from": message.FromID, "to": message.ToID})
 		} else {
+			if moderation.RecordID != 0 {
+				if err := repository.SetModerationRecordTarget([]uint{moderation.RecordID}, model.ContentTypeChatMessage, chatMsg.ID); err != nil {
+					utils.LogError("关联审核记录失败", map[string]interface{}{"error": err.Error(), "message_id": chatMsg.ID})
+				}
+			}
+			message.Attachments = bindAttachments(client.ID, message.AttachmentIDs, model.AttachmentOwnerChatMessage, chatMsg.ID)
+			message.AttachmentIDs = nil
+			message.Tags = utils.ParseHashtags(message.Content)
+			if message.ToID != 0 && !manager.IsOnline(message.ToID) {
+				notifyOfflineMessage(message.ToID, client.ID, chatMsg.ID, message.Content)
+			}
+			// 私信对方本来就会收到消息，只在聊天室里提醒被 @ 的人；小组聊天室只提醒组员
+			if message.ToID == 0 && message.RoomID != "" {
+				var canSee func(uint) bool
+				if isGroupRoom(message.RoomID) {
+					roomID := message.RoomID
+					canSee = func(uid uint) bool { return canJoinGroupRoom(roomID, uid) }
+				}
+				message.Mentions = notifyMentions(model.ContentTypeChatMessage, chatMsg.ID, client.ID, message.Content,
+					gin.H{"room_id": message.RoomID, "message_id": chatMsg.ID}, canSee)
+			}
 			utils.LogInfo("💾 消息已保存", map[string]interface{}{"id": chatMsg.ID, "from": message.FromID, "to": message.ToID, "room": message.RoomID, "content": message.Content})
 		}
 
@@ -355,6 +494,10 @@ func GetChatRooms() gin.HandlerFunc {
 
 		rooms := make([]RoomInfo, 0)
 		for _, room := range manager.Rooms {
+			// 小组聊天室不公开展示
+			if isGroupRoom(room.ID) {
+				continue
+			}
 			rooms = append(rooms, RoomInfo{
 				ID:        room.ID,
 				Name:      room.Name,
@@ -386,8 +529,14 @@ func CreateChatRoom() gin.HandlerFunc {
 		manager.mu.Lock()
 		defer manager.mu.Unlock()
 
-		// 检查聊天室数量限制
-		if len(manager.Rooms) >= 10 {
+		// 检查聊天室数量限制（小组聊天室不计入）
+		publicRooms := 0
+		for roomID := range manager.Rooms {
+			if !isGroupRoom(roomID) {
+				publicRooms++
+			}
+		}
+		if publicRooms >= 10 {
 			c.JSON(http.StatusForbidden, gin.H{"error": "聊天室数量已达上限（最多10个）"})
 			return
 		}
@@ -429,6 +578,11 @@ func DeleteChatRoom() gin.HandlerFunc {
 			c.JSON(http.StatusForbidden, gin.H{"error": "不能删除默认聊天室"})
 			return
 		}
+		// 小组聊天室随小组解散而删除
+		if isGroupRoom(roomID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "小组聊天室不能单独删除"})
+			return
+		}
 
 		manager.mu.Lock()
 		defer manager.mu.Unlock()
@@ -462,6 +616,10 @@ func DeleteChatRoom() gin.HandlerFunc {
 func GetChatHistory() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		roomID := c.Param("room_id")
+		if userID, _ := getCurrentUserID(c); isGroupRoom(roomID) && !canJoinGroupRoom(roomID, userID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "只有小组成员可以查看小组聊天记录"})
+			return
+		}
 		limit := 30
 		if limitParam := c.Query("limit"); limitParam != "" {
 			var l int
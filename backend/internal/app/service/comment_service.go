@@ -0,0 +1,191 @@
+package service
+
+import (
+	"strconv"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 带回复数的一级帖子评论
+type PostCommentThread struct {
+	model.PostComment
+	ReplyCount int64 `json:"reply_count"`
+}
+
+// 带回复数的一级flag评论
+type FlagCommentThread struct {
+	model.FlagComment
+	ReplyCount int64 `json:"reply_count"`
+}
+
+// 回复只挂两层：回复一条回复时挂到它所在的一级评论下
+func threadRootID(parentID uint, parentParentID *uint) *uint {
+	if parentParentID != nil {
+		return parentParentID
+	}
+	return &parentID
+}
+
+// 评论者本人、内容主人和管理员可以删除评论
+func canDeleteComment(userID, authorID, ownerID uint) bool {
+	if userID == authorID || userID == ownerID {
+		return true
+	}
+	role, err := repository.GetUserRole(userID)
+	return err == nil && role == model.UserRoleAdmin
+}
+
+func parseIDParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(400, gin.H{"error": "无效的ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// 分页获取帖子的一级评论（附回复数）
+func GetPostComments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		postID, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+		userID, _ := getCurrentUserID(c)
+		if _, ok := loadVisiblePost(c, userID, postID); !ok {
+			return
+		}
+		page, limit := parsePagination(c)
+		comments, total, err := repository.GetPostComments(postID, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取评论失败,请重新再试..."})
+			utils.LogError("获取帖子评论失败", logrus.Fields{"post_id": postID, "error": err.Error()})
+			return
+		}
+		ids := make([]uint, 0, len(comments))
+		for _, cm := range comments {
+			ids = append(ids, cm.ID)
+		}
+		counts, err := repository.CountPostCommentReplies(ids)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取评论失败,请重新再试..."})
+			utils.LogError("统计评论回复数失败", logrus.Fields{"post_id": postID, "error": err.Error()})
+			return
+		}
+		threads := make([]PostCommentThread, 0, len(comments))
+		for _, cm := range comments {
+			threads = append(threads, PostCommentThread{PostComment: cm, ReplyCount: counts[cm.ID]})
+		}
+		c.JSON(200, gin.H{"success": true, "data": threads, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// 分页获取帖子某条评论的回复
+func GetPostCommentReplies() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		postID, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+		commentID, ok := parseIDParam(c, "commentId")
+		if !ok {
+			return
+		}
+		userID, _ := getCurrentUserID(c)
+		if _, ok := loadVisiblePost(c, userID, postID); !ok {
+			return
+		}
+		root, err := repository.GetCommentByID(commentID)
+		if err != nil || root.PostID != postID || root.ParentID != nil || root.Hidden {
+			c.JSON(404, gin.H{"error": "评论不存在"})
+			return
+		}
+		page, limit := parsePagination(c)
+		replies, total, err := repository.GetPostCommentReplies(commentID, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取回复失败,请重新再试..."})
+			utils.LogError("获取帖子评论回复失败", logrus.Fields{"comment_id": commentID, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "data": replies, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// 公开的flag或自己的flag才能查看评论，被隐藏的flag不可见
+func loadVisibleFlag(c *gin.Context, userID uint) (model.Flag, bool) {
+	flagID, ok := parseIDParam(c, "id")
+	if !ok {
+		return model.Flag{}, false
+	}
+	flag, err := repository.GetFlagByID(flagID)
+	if err != nil || flag.Hidden || (!flag.IsPublic && flag.UserID != userID) {
+		c.JSON(404, gin.H{"error": "flag不存在"})
+		return model.Flag{}, false
+	}
+	return flag, true
+}
+
+// 分页获取flag的一级评论（附回复数）
+func GetFlagComments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := getCurrentUserID(c)
+		flag, ok := loadVisibleFlag(c, userID)
+		if !ok {
+			return
+		}
+		page, limit := parsePagination(c)
+		comments, total, err := repository.GetFlagComments(flag.ID, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取评论失败,请重新再试..."})
+			utils.LogError("获取flag评论失败", logrus.Fields{"flag_id": flag.ID, "error": err.Error()})
+			return
+		}
+		ids := make([]uint, 0, len(comments))
+		for _, cm := range comments {
+			ids = append(ids, cm.ID)
+		}
+		counts, err := repository.CountFlagCommentReplies(ids)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取评论失败,请重新再试..."})
+			utils.LogError("统计评论回复数失败", logrus.Fields{"flag_id": flag.ID, "error": err.Error()})
+			return
+		}
+		threads := make([]FlagCommentThread, 0, len(comments))
+		for _, cm := range comments {
+			threads = append(threads, FlagCommentThread{FlagComment: cm, ReplyCount: counts[cm.ID]})
+		}
+		c.JSON(200, gin.H{"success": true, "data": threads, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// 分页获取flag某条评论的回复
+func GetFlagCommentReplies() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := getCurrentUserID(c)
+		flag, ok := loadVisibleFlag(c, userID)
+		if !ok {
+			return
+		}
+		commentID, ok := parseIDParam(c, "commentId")
+		if !ok {
+			return
+		}
+		root, err := repository.GetFlagCommentByID(commentID)
+		if err != nil || root.FlagID != flag.ID || root.ParentID != nil || root.Hidden {
+			c.JSON(404, gin.H{"error": "评论不存在"})
+			return
+		}
+		page, limit := parsePagination(c)
+		replies, total, err := repository.GetFlagCommentReplies(commentID, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取回复失败,请重新再试..."})
+			utils.LogError("获取flag评论回复失败", logrus.Fields{"comment_id": commentID, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "data": replies, "total": total, "page": page, "limit": limit})
+	}
+}
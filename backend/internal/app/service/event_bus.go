@@ -0,0 +1,91 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/sirupsen/logrus"
+)
+
+// 领域事件类型
+const (
+	EventFlagCreated         = "flag.created"
+	EventFlagCompleted       = "flag.completed"
+	EventDaka                = "daka"
+	EventLearnTimeRecorded   = "learn_time.recorded"
+	EventPostCreated         = "post.created"
+	EventPointsAwarded       = "points.awarded"
+	EventAchievementUnlocked = "achievement.unlocked"
+)
+
+// 领域事件
+type DomainEvent struct {
+	Type    string
+	UserID  uint
+	At      time.Time
+	Payload map[string]interface{}
+}
+
+type EventHandler func(DomainEvent)
+
+// 进程内事件总线：发布方只负责投递，订阅方在后台协程中依次处理
+type eventBus struct {
+	mu       sync.RWMutex
+	handlers map[string][]EventHandler
+	queue    chan DomainEvent
+	once     sync.Once
+}
+
+const eventQueueSize = 1024
+
+var bus = &eventBus{
+	handlers: make(map[string][]EventHandler),
+	queue:    make(chan DomainEvent, eventQueueSize),
+}
+
+// 订阅事件（通常在 init 中调用）
+func SubscribeEvent(eventType string, handler EventHandler) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.handlers[eventType] = append(bus.handlers[eventType], handler)
+}
+
+// 发布事件，不阻塞调用方；队列已满时丢弃并记录日志
+func PublishEvent(eventType string, userID uint, payload map[string]interface{}) {
+	event := DomainEvent{Type: eventType, UserID: userID, At: time.Now(), Payload: payload}
+	select {
+	case bus.queue <- event:
+	default:
+		utils.LogError("事件队列已满，丢弃事件", logrus.Fields{"type": eventType, "user_id": userID})
+	}
+}
+
+// 启动事件分发协程（重复调用无副作用）
+func StartEventBus() {
+	bus.once.Do(func() {
+		go bus.run()
+		utils.LogInfo("事件总线已启动", nil)
+	})
+}
+
+func (b *eventBus) run() {
+	for event := range b.queue {
+		b.mu.RLock()
+		handlers := b.handlers[event.Type]
+		b.mu.RUnlock()
+		for _, handler := range handlers {
+			b.dispatch(handler, event)
+		}
+	}
+}
+
+// 单个订阅方出错不影响其他订阅方
+func (b *eventBus) dispatch(handler EventHandler, event DomainEvent) {
+	defer func() {
+		if r := recover(); r != nil {
+			utils.LogError("处理事件失败", logrus.Fields{"type": event.Type, "user_id": event.UserID, "panic": r})
+		}
+	}()
+	handler(event)
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// 关注流：按时间排序，只看我关注的人
+const feedSortFollowing = "following"
+
+// 信息流条目（帖子或公开flag）
+type FeedItem struct {
+	Kind      string    `json:"kind"`
+	ID        uint      `json:"id"`
+	Author    UserBrief `json:"author"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Likes     int       `json:"likes"`
+	Comments  int64     `json:"comments"`
+	LikedByMe bool      `json:"liked_by_me"`
+	CreatedAt time.Time `json:"created_at"`
+	Edited    bool      `json:"edited"`            // 帖子是否编辑过
+	FlagID    *uint     `json:"flag_id,omitempty"` // 帖子关联的flag
+	Flag      *FeedFlag `json:"flag,omitempty"`    // flag条目的进度信息
+
+	Attachments []model.Attachment `json:"attachments,omitempty"` // 帖子的图片附件
+
+	userID uint
+	score  float64
+}
+
+// flag条目的进度信息
+type FeedFlag struct {
+	Label     int       `json:"label"`
+	Completed bool      `json:"completed"`
+	Count     int       `json:"count"`
+	Total     int       `json:"total"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+// 游标在客户端是不透明的字符串
+type feedCursorPayload struct {
+	Value json.RawMessage `json:"v"`
+	Kind  string          `json:"k"`
+	ID    uint            `json:"i"`
+}
+
+func encodeFeedCursor(item FeedItem, sortBy string) string {
+	var value interface{} = item.CreatedAt
+	if sortBy == repository.FeedSortHot {
+		value = item.score
+	}
+	raw, _ := json.Marshal(value)
+	data, _ := json.Marshal(feedCursorPayload{Value: raw, Kind: item.Kind, ID: item.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeFeedCursor(s, sortBy string) (*repository.FeedCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var payload feedCursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Kind != repository.FeedKindPost && payload.Kind != repository.FeedKindFlag {
+		return nil, errors.New("未知的条目类型")
+	}
+	cursor := &repository.FeedCursor{Kind: payload.Kind, ID: payload.ID}
+	if sortBy == repository.FeedSortHot {
+		var score float64
+		err = json.Unmarshal(payload.Value, &score)
+		cursor.Value = score
+	} else {
+		var t time.Time
+		err = json.Unmarshal(payload.Value, &t)
+		cursor.Value = t
+	}
+	return cursor, err
+}
+
+// a 是否排在 b 前面，与 repository 中的排序规则一致
+func feedItemBefore(a, b FeedItem, sortBy string) bool {
+	if sortBy == repository.FeedSortHot {
+		if a.score != b.score {
+			return a.score > b.score
+		}
+	} else if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	if a.Kind != b.Kind {
+		return a.Kind == repository.FeedKindPost
+	}
+	return a.ID > b.ID
+}
+
+func feedItemsFromPosts(posts []model.Post) []FeedItem {
+	items := make([]FeedItem, 0, len(posts))
+	for _, p := range posts {
+		items = append(items, FeedItem{
+			Kind:      repository.FeedKindPost,
+			ID:        p.ID,
+			Title:     p.Title,
+			Content:   p.Content,
+			Likes:     p.Like,
+			CreatedAt: p.CreatedAt,
+			Edited:    p.Edited,
+			FlagID:    p.FlagID,
+			userID:    p.UserID,
+			score:     p.HotScore,
+		})
+	}
+	return items
+}
+
+func feedItemsFromFlags(flags []model.Flag) []FeedItem {
+	items := make([]FeedItem, 0, len(flags))
+	for _, f := range flags {
+		items = append(items, FeedItem{
+			Kind:      repository.FeedKindFlag,
+			ID:        f.ID,
+			Title:     f.Title,
+			Content:   f.Detail,
+			Likes:     f.Likes,
+			CreatedAt: f.CreatedAt,
+			Flag: &FeedFlag{
+				Label:     f.Label,
+				Completed: f.Completed,
+				Count:     f.Count,
+				Total:     f.DailyTotal,
+				StartTime: f.StartTime,
+				EndTime:   f.EndTime,
+			},
+			userID: f.UserID,
+			score:  f.HotScore,
+		})
+	}
+	return items
+}
+
+// 合并帖子和flag并按排序规则排好
+func mergeFeedItems(posts []model.Post, flags []model.Flag, sortBy string) []FeedItem {
+	items := append(feedItemsFromPosts(posts), feedItemsFromFlags(flags)...)
+	sort.Slice(items, func(i, j int) bool {
+		return feedItemBefore(items[i], items[j], sortBy)
+	})
+	return items
+}
+
+// 批量补全作者、评论数、点赞状态和附件
+func fillFeedItems(userID uint, items []FeedItem) error {
+	var postIDs, flagIDs, authorIDs []uint
+	for _, item := range items {
+		if item.Kind == repository.FeedKindPost {
+			postIDs = append(postIDs, item.ID)
+		} else {
+			flagIDs = append(flagIDs, item.ID)
+		}
+		authorIDs = append(authorIDs, item.userID)
+	}
+	postComments, err := repository.CountPostComments(postIDs)
+	if err != nil {
+		return err
+	}
+	flagComments, err := repository.CountFlagComments(flagIDs)
+	if err != nil {
+		return err
+	}
+	likedPosts, err := repository.FilterLikedPosts(userID, postIDs)
+	if err != nil {
+		return err
+	}
+	likedFlags, err := repository.FilterLikedFlags(userID, flagIDs)
+	if err != nil {
+		return err
+	}
+	attachments, err := repository.GetAttachmentsByOwners(model.AttachmentOwnerPost, postIDs)
+	if err != nil {
+		return err
+	}
+	authors, err := loadUserBriefs(authorIDs)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		item := &items[i]
+		item.Author = authors[item.userID]
+		item.Author.ID = item.userID
+		if item.Kind == repository.FeedKindPost {
+			item.Comments = postComments[item.ID]
+			item.LikedByMe = likedPosts[item.ID]
+			item.Attachments = attachments[item.ID]
+		} else {
+			item.Comments = flagComments[item.ID]
+			item.LikedByMe = likedFlags[item.ID]
+		}
+	}
+	return nil
+}
+
+// 社区信息流：帖子和公开flag按时间或热度合并，游标分页
+func GetFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		sortBy := c.DefaultQuery("sort", repository.FeedSortLatest)
+		_, limit := parsePagination(c)
+
+		// 每类多取一条，用于判断是否还有下一页
+		query := repository.FeedQuery{Sort: repository.FeedSortLatest, Limit: limit + 1}
+		switch sortBy {
+		case repository.FeedSortLatest:
+		case repository.FeedSortHot:
+			query.Sort = repository.FeedSortHot
+		case feedSortFollowing:
+			ids, err := repository.GetFollowingIDs(id)
+			if err != nil {
+				c.JSON(500, gin.H{"error": "获取动态失败,请重新再试..."})
+				utils.LogError("获取关注列表失败", logrus.Fields{"user_id": id, "error": err.Error()})
+				return
+			}
+			if len(ids) == 0 {
+				c.JSON(200, gin.H{"success": true, "sort": sortBy, "data": []FeedItem{}, "next_cursor": "", "has_more": false})
+				return
+			}
+			query.UserIDs = ids
+		default:
+			c.JSON(400, gin.H{"error": "sort 只能是 latest/hot/following"})
+			return
+		}
+		if s := c.Query("cursor"); s != "" {
+			cursor, err := decodeFeedCursor(s, query.Sort)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "无效的游标"})
+				return
+			}
+			query.Cursor = cursor
+		}
+
+		blocked, err := repository.GetBlockedIDsBetween(id)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取动态失败,请重新再试..."})
+			utils.LogError("获取拉黑关系失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		query.ExcludeIDs = blocked
+
+		posts, err := repository.GetFeedPosts(query)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取动态失败,请重新再试..."})
+			utils.LogError("获取动态帖子失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		flags, err := repository.GetFeedFlags(query)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取动态失败,请重新再试..."})
+			utils.LogError("获取动态flag失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+
+		items := mergeFeedItems(posts, flags, query.Sort)
+		hasMore := len(items) > limit
+		if hasMore {
+			items = items[:limit]
+		}
+		if err := fillFeedItems(id, items); err != nil {
+			c.JSON(500, gin.H{"error": "获取动态失败,请重新再试..."})
+			utils.LogError("补全动态信息失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+
+		nextCursor := ""
+		if hasMore {
+			nextCursor = encodeFeedCursor(items[len(items)-1], query.Sort)
+		}
+		c.JSON(200, gin.H{
+			"success":     true,
+			"sort":        sortBy,
+			"data":        items,
+			"next_cursor": nextCursor,
+			"has_more":    hasMore,
+		})
+	}
+}
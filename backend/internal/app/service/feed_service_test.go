@@ -0,0 +1,110 @@
+package service
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+)
+
+func TestFeedCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2025, 3, 14, 15, 9, 26, 535897000, time.FixedZone("CST", 8*3600))
+	tests := []struct {
+		name   string
+		sortBy string
+		item   FeedItem
+		value  interface{}
+	}{
+		{
+			name:   "latest post",
+			sortBy: repository.FeedSortLatest,
+			item:   FeedItem{Kind: repository.FeedKindPost, ID: 42, CreatedAt: createdAt, score: 1.5},
+			value:  createdAt,
+		},
+		{
+			name:   "following flag",
+			sortBy: feedSortFollowing,
+			item:   FeedItem{Kind: repository.FeedKindFlag, ID: 7, CreatedAt: createdAt},
+			value:  createdAt,
+		},
+		{
+			name:   "hot post",
+			sortBy: repository.FeedSortHot,
+			item:   FeedItem{Kind: repository.FeedKindPost, ID: 3, CreatedAt: createdAt, score: 0.0123456789},
+			value:  0.0123456789,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := decodeFeedCursor(encodeFeedCursor(tt.item, tt.sortBy), tt.sortBy)
+			if err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if cursor.Kind != tt.item.Kind || cursor.ID != tt.item.ID {
+				t.Errorf("got kind=%q id=%d, want kind=%q id=%d", cursor.Kind, cursor.ID, tt.item.Kind, tt.item.ID)
+			}
+			switch want := tt.value.(type) {
+			case time.Time:
+				got, ok := cursor.Value.(time.Time)
+				if !ok || !got.Equal(want) {
+					t.Errorf("value = %v, want %v", cursor.Value, want)
+				}
+			case float64:
+				if got, ok := cursor.Value.(float64); !ok || got != want {
+					t.Errorf("value = %v, want %v", cursor.Value, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeFeedCursorRejectsInvalidInput(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name   string
+		cursor string
+		sortBy string
+	}{
+		{name: "not base64", cursor: "!!!", sortBy: repository.FeedSortLatest},
+		{name: "not json", cursor: encode("hello"), sortBy: repository.FeedSortLatest},
+		{name: "unknown kind", cursor: encode(`{"v":"2025-01-01T00:00:00Z","k":"user","i":1}`), sortBy: repository.FeedSortLatest},
+		{name: "time cursor used for hot sort", cursor: encode(`{"v":"2025-01-01T00:00:00Z","k":"post","i":1}`), sortBy: repository.FeedSortHot},
+		{name: "score cursor used for latest sort", cursor: encode(`{"v":1.5,"k":"post","i":1}`), sortBy: repository.FeedSortLatest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeFeedCursor(tt.cursor, tt.sortBy); err == nil {
+				t.Errorf("decodeFeedCursor(%q) succeeded, want error", tt.cursor)
+			}
+		})
+	}
+}
+
+func TestFeedItemBefore(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	post := FeedItem{Kind: repository.FeedKindPost, ID: 1, CreatedAt: t0, score: 2}
+	flag := FeedItem{Kind: repository.FeedKindFlag, ID: 5, CreatedAt: t0, score: 2}
+	newer := FeedItem{Kind: repository.FeedKindFlag, ID: 2, CreatedAt: t0.Add(time.Minute), score: 1}
+	higherID := FeedItem{Kind: repository.FeedKindPost, ID: 9, CreatedAt: t0, score: 2}
+
+	tests := []struct {
+		name   string
+		a, b   FeedItem
+		sortBy string
+		want   bool
+	}{
+		{name: "newer first", a: newer, b: post, sortBy: repository.FeedSortLatest, want: true},
+		{name: "higher score first", a: post, b: newer, sortBy: repository.FeedSortHot, want: true},
+		{name: "posts before flags on ties", a: post, b: flag, sortBy: repository.FeedSortLatest, want: true},
+		{name: "flags after posts on ties", a: flag, b: post, sortBy: repository.FeedSortHot, want: false},
+		{name: "higher id first on ties", a: higherID, b: post, sortBy: repository.FeedSortLatest, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := feedItemBefore(tt.a, tt.b, tt.sortBy); got != tt.want {
+				t.Errorf("feedItemBefore = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -52,6 +52,7 @@ func PostUserFlags() gin.HandlerFunc {
 			IsRecurring bool   `json:"is_recurring"` // 是否循环任务
 			EndTime     string `json:"end_time"`     // 改为string，手动解析
 			StartTime   string `json:"start_time"`   // 改为string，手动解析
+			AIPlanID    *uint  `json:"ai_plan_id"`   // 由AI学习计划创建时传入计划ID（可选）
 		}
 		if err := c.ShouldBindJSON(&flag); err != nil {
 			c.JSON(500, gin.H{"err": "添加flag失败,请重新再试..."})
@@ -122,6 +123,15 @@ func PostUserFlags() gin.HandlerFunc {
 			c.JSON(402, gin.H{"error": "获取用户信息失败,请重新再试..."})
 			return
 		}
+		// 关联AI学习计划（只能关联自己的计划）
+		if flag.AIPlanID != nil {
+			plan, err := repository.GetAIPlanByID(*flag.AIPlanID)
+			if err != nil || plan.UserID != id {
+				c.JSON(400, gin.H{"error": "关联的学习计划不存在"})
+				return
+			}
+			flag_model.AIPlanID = flag.AIPlanID
+		}
 		err := repository.AddFlagToDB(id, flag_model)
 		if err != nil {
 			c.JSON(400, gin.H{"error": "添加flag失败,请重新再试..."})
@@ -129,6 +139,7 @@ func PostUserFlags() gin.HandlerFunc {
 			return
 		}
 		utils.LogInfo("添加用户flag成功", logrus.Fields{"user_id": id, "flag": flag.Title})
+		PublishEvent(EventFlagCreated, id, nil)
 		// 重新查询以获取自动生成的ID
 		flags, _ := repository.GetFlagsByUserID(id)
 		var createdFlag model.Flag
@@ -182,6 +193,10 @@ func DoneUserFlags() gin.HandlerFunc {
 			c.JSON(400, gin.H{"error": "更新flag失败,请重新再试..."})
 			return
 		}
+		if flag.UserID != id {
+			c.JSON(404, gin.H{"error": "flag不存在"})
+			return
+		}
 
 		// 校验flag是否在有效日期范围内
 		today := time.Now()
@@ -206,15 +221,18 @@ func DoneUserFlags() gin.HandlerFunc {
 
 		// 检查Flag是否完成
 		if flag.Count >= flag.DailyTotal && !flag.Completed {
-			// 标记Flag为已完成
-			err = repository.UpdateFlagHadDone(req.ID, true)
+			// 标记Flag为已完成（条件更新，并发打卡只记录一次完成）
+			marked, err := repository.MarkFlagDone(req.ID)
 			if err != nil {
 				utils.LogError("更新Flag完成状态失败", logrus.Fields{"flag_id": req.ID, "error": err.Error()})
 			}
+			if marked {
+				recordFlagCompletion(id, flag)
+			}
 
-			// 更新用户的完成Flag计数
+			// 更新用户的完成Flag计数（只在本次请求实际完成时）
 			user, err := repository.GetUserByID(id)
-			if err == nil {
+			if marked && err == nil {
 				newFlagNumber := user.FlagNumber + 1
 				err = repository.FlagNumberAddDB(id, newFlagNumber)
 				if err != nil {
@@ -225,7 +243,7 @@ func DoneUserFlags() gin.HandlerFunc {
 
 				// 🔧 新增：自动增加积分（根据Flag积分字段）
 				if flag.Points > 0 {
-					err = repository.CountAddDB(id, flag.Points)
+					err = AwardPoints(id, flag.Points)
 					if err != nil {
 						utils.LogError("更新用户积分失败", logrus.Fields{"user_id": id, "error": err.Error()})
 					} else {
@@ -241,6 +259,14 @@ func DoneUserFlags() gin.HandlerFunc {
 	}
 }
 
+// 记录flag完成历史
+func recordFlagCompletion(userID uint, flag model.Flag) {
+	if err := repository.AddFlagCompletion(userID, flag.ID, flag.Label); err != nil {
+		utils.LogError("记录flag完成历史失败", logrus.Fields{"user_id": userID, "flag_id": flag.ID, "error": err.Error()})
+	}
+	PublishEvent(EventFlagCompleted, userID, map[string]interface{}{"flag_id": flag.ID, "label": flag.Label})
+}
+
 // 删除flag
 func DeleteUserFlags() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -277,36 +303,37 @@ func FinshDoneFlag() gin.HandlerFunc {
 			log.Print("Binding error")
 			return
 		}
+		flag, err := repository.GetFlagByID(req.ID)
+		if err != nil || flag.ID == 0 || flag.UserID != id {
+			c.JSON(404, gin.H{"error": "flag不存在"})
+			return
+		}
+		// 条件更新完成状态，重复或并发提交只有一次生效
+		marked, err := repository.MarkFlagDone(flag.ID)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "更新flag失败,请重新再试..."})
+			utils.LogError("数据库更新flag完成状态失败", logrus.Fields{"flag_id": flag.ID, "error": err.Error()})
+			return
+		}
+		if !marked {
+			c.JSON(400, gin.H{"error": "该flag已完成"})
+			return
+		}
 		user, _ := repository.GetUserByID(id)
-		flag, _ := repository.GetFlagByID(req.ID)
 		// 将数字label转换为字符串保存
-		labelMap := map[int]string{
-			1: "生活",
-			2: "学习",
-			3: "工作",
-			4: "兴趣",
-			5: "运动",
-		}
-		labelStr := labelMap[flag.Label]
+		labelStr := model.FlagLabelNames[flag.Label]
 		if labelStr == "" {
 			labelStr = "学习"
 		}
 		repository.SaveLabelToDB(id, labelStr)
-		user.FlagNumber++
-		repository.SaveUserToDB(user)
-		count, _ := strconv.Atoi(level)
-		newcount := user.Count + count
 		repository.FlagNumberAddDB(id, user.FlagNumber+1)
-		err := repository.CountAddDB(id, newcount)
-		if err != nil {
-			log.Printf("[error] 积分更新失败: %v", err)
-		}
-		err = repository.UpdateFlagHadDone(req.ID, true)
-		if err != nil {
-			c.JSON(400, gin.H{"error": "更新flag失败,请重新再试..."})
-			utils.LogError("数据库更新flag完成状态失败", logrus.Fields{})
-			return
+		count, _ := strconv.Atoi(level)
+		if count > 0 {
+			if err := AwardPoints(id, count); err != nil {
+				log.Printf("[error] 积分更新失败: %v", err)
+			}
 		}
+		recordFlagCompletion(id, flag)
 		utils.LogInfo("flag完成状态更新成功", logrus.Fields{"user_id": id, "flag_id": req.ID})
 		c.JSON(200, gin.H{"success": true})
 	}
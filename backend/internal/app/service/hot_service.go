@@ -0,0 +1,133 @@
+package service
+
+import (
+	"math"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	hotScoreGravity       = 1.8                // 时间衰减指数，越大旧内容沉得越快
+	hotScoreCommentWeight = 2                  // 一条评论相当于几个赞
+	hotScoreWindow        = 7 * 24 * time.Hour // 超过一周的内容热度清零
+)
+
+// Hacker News 式热度：(赞 + 评论*权重) / (发布小时数 + 2)^gravity
+func hotScore(stats repository.HotStats, now time.Time) float64 {
+	points := float64(stats.Likes + hotScoreCommentWeight*stats.Comments)
+	hours := math.Max(now.Sub(stats.CreatedAt).Hours(), 0)
+	return points / math.Pow(hours+2, hotScoreGravity)
+}
+
+// 点赞、评论后立即刷新帖子热度
+func refreshPostHotScore(postID uint) {
+	stats, err := repository.GetPostHotStats([]uint{postID}, time.Time{})
+	if err != nil {
+		utils.LogError("获取帖子热度数据失败", logrus.Fields{"post_id": postID, "error": err.Error()})
+		return
+	}
+	now := time.Now()
+	for _, s := range stats {
+		if err := repository.UpdatePostHotScore(s.ID, hotScore(s, now)); err != nil {
+			utils.LogError("更新帖子热度失败", logrus.Fields{"post_id": s.ID, "error": err.Error()})
+		}
+	}
+}
+
+// 点赞、评论后立即刷新flag热度
+func refreshFlagHotScore(flagID uint) {
+	stats, err := repository.GetFlagHotStats([]uint{flagID}, time.Time{})
+	if err != nil {
+		utils.LogError("获取flag热度数据失败", logrus.Fields{"flag_id": flagID, "error": err.Error()})
+		return
+	}
+	now := time.Now()
+	for _, s := range stats {
+		if err := repository.UpdateFlagHotScore(s.ID, hotScore(s, now)); err != nil {
+			utils.LogError("更新flag热度失败", logrus.Fields{"flag_id": s.ID, "error": err.Error()})
+		}
+	}
+}
+
+// 定时重算一周内内容的热度（热度随时间衰减，不能只靠互动时刷新）
+func RefreshHotScores() {
+	now := time.Now()
+	since := now.Add(-hotScoreWindow)
+
+	posts, err := repository.GetPostHotStats(nil, since)
+	if err != nil {
+		utils.LogError("获取帖子热度数据失败", logrus.Fields{"error": err.Error()})
+		return
+	}
+	for _, s := range posts {
+		if err := repository.UpdatePostHotScore(s.ID, hotScore(s, now)); err != nil {
+			utils.LogError("更新帖子热度失败", logrus.Fields{"post_id": s.ID, "error": err.Error()})
+		}
+	}
+	flags, err := repository.GetFlagHotStats(nil, since)
+	if err != nil {
+		utils.LogError("获取flag热度数据失败", logrus.Fields{"error": err.Error()})
+		return
+	}
+	for _, s := range flags {
+		if err := repository.UpdateFlagHotScore(s.ID, hotScore(s, now)); err != nil {
+			utils.LogError("更新flag热度失败", logrus.Fields{"flag_id": s.ID, "error": err.Error()})
+		}
+	}
+	if err := repository.ResetStaleHotScores(since); err != nil {
+		utils.LogError("清零过期热度失败", logrus.Fields{"error": err.Error()})
+		return
+	}
+	utils.LogInfo("热度重算完成", logrus.Fields{"posts": len(posts), "flags": len(flags)})
+}
+
+// 本周热门：一周内发布的帖子和公开flag按热度排序
+func GetTrending() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		_, limit := parsePagination(c)
+
+		blocked, err := repository.GetBlockedIDsBetween(id)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取热门失败,请重新再试..."})
+			utils.LogError("获取拉黑关系失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		query := repository.FeedQuery{
+			Sort:       repository.FeedSortHot,
+			ExcludeIDs: blocked,
+			Since:      time.Now().Add(-hotScoreWindow),
+			Limit:      limit,
+		}
+		posts, err := repository.GetFeedPosts(query)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取热门失败,请重新再试..."})
+			utils.LogError("获取热门帖子失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		flags, err := repository.GetFeedFlags(query)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取热门失败,请重新再试..."})
+			utils.LogError("获取热门flag失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+
+		items := mergeFeedItems(posts, flags, repository.FeedSortHot)
+		if len(items) > limit {
+			items = items[:limit]
+		}
+		if err := fillFeedItems(id, items); err != nil {
+			c.JSON(500, gin.H{"error": "获取热门失败,请重新再试..."})
+			utils.LogError("补全热门信息失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{
+			"success": true,
+			"data":    items,
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+)
+
+func TestHotScore(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		stats repository.HotStats
+		want  float64
+	}{
+		{name: "no interaction", stats: repository.HotStats{CreatedAt: now}, want: 0},
+		{name: "just posted", stats: repository.HotStats{CreatedAt: now, Likes: 3, Comments: 1}, want: 5 / math.Pow(2, hotScoreGravity)},
+		{name: "ten hours old", stats: repository.HotStats{CreatedAt: now.Add(-10 * time.Hour), Likes: 10}, want: 10 / math.Pow(12, hotScoreGravity)},
+		{name: "future timestamp counts as new", stats: repository.HotStats{CreatedAt: now.Add(time.Hour), Likes: 1}, want: 1 / math.Pow(2, hotScoreGravity)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hotScore(tt.stats, now); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("hotScore = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHotScoreOrdering(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	liked := repository.HotStats{CreatedAt: now.Add(-time.Hour), Likes: 4}
+	commented := repository.HotStats{CreatedAt: now.Add(-time.Hour), Comments: 4}
+	if hotScore(commented, now) <= hotScore(liked, now) {
+		t.Errorf("comments should weigh more than likes")
+	}
+	fresh := repository.HotStats{CreatedAt: now.Add(-time.Hour), Likes: 10}
+	stale := repository.HotStats{CreatedAt: now.Add(-48 * time.Hour), Likes: 10}
+	if hotScore(stale, now) >= hotScore(fresh, now) {
+		t.Errorf("older content with the same interactions should rank lower")
+	}
+}
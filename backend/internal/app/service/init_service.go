@@ -39,6 +39,57 @@ func Init() {
 		utils.LogError("添加凌晨4点自动停止学习计时任务失败", logrus.Fields{"error": err.Error()})
 	}
 
+	// ADMIN_EMAILS 中的用户设为管理员
+	promoteConfiguredAdmins()
+
+	// 以点赞关系表为准修正flag点赞数
+	if fixed, err := repository.RecountFlagLikes(); err != nil {
+		utils.LogError("重算flag点赞数失败", logrus.Fields{"error": err.Error()})
+	} else if fixed > 0 {
+		utils.LogInfo("已重算flag点赞数", logrus.Fields{"flags": fixed})
+	}
+
+	// 每天凌晨0点30分评估挑战赛进度并给完成者发放奖励
+	_, err = cronScheduler.AddFunc("0 30 0 * * *", RunChallengeJob)
+	if err != nil {
+		utils.LogError("添加挑战赛评估任务失败", logrus.Fields{"error": err.Error()})
+	}
+
+	// 为所有学习小组创建聊天室
+	LoadGroupRooms()
+
+	// 启动时从数据库重建封神榜，之后每天凌晨重建一次修正偏差
+	RebuildLeaderboards()
+	_, err = cronScheduler.AddFunc("0 10 0 * * *", RebuildLeaderboards)
+	if err != nil {
+		utils.LogError("添加封神榜重建任务失败", logrus.Fields{"error": err.Error()})
+	}
+
+	// 启动时重算一次热度，之后每10分钟重算一周内内容的热度
+	RefreshHotScores()
+	_, err = cronScheduler.AddFunc("0 */10 * * * *", RefreshHotScores)
+	if err != nil {
+		utils.LogError("添加热度重算任务失败", logrus.Fields{"error": err.Error()})
+	}
+
+	// 每小时清理一次上传后未被引用的附件
+	_, err = cronScheduler.AddFunc("0 20 * * * *", CleanupStaleAttachments)
+	if err != nil {
+		utils.LogError("添加附件清理任务失败", logrus.Fields{"error": err.Error()})
+	}
+
+	// 每天凌晨3点删除90天前的已读通知
+	_, err = cronScheduler.AddFunc("0 0 3 * * *", CleanupNotifications)
+	if err != nil {
+		utils.LogError("添加通知清理任务失败", logrus.Fields{"error": err.Error()})
+	}
+
+	// 每周一早上8点生成上周学习周报（WEEKLY_REPORT_EMAIL=true 时同时发送邮件）
+	_, err = cronScheduler.AddFunc("0 0 8 * * 1", RunWeeklyReportJob)
+	if err != nil {
+		utils.LogError("添加周报任务失败", logrus.Fields{"error": err.Error()})
+	}
+
 	for _, u := range users {
 		user := u
 
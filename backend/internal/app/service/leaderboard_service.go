@@ -0,0 +1,226 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/sirupsen/logrus"
+)
+
+// 排行榜存储，按 Redis 有序集合的语义设计（ZINCRBY/ZREM/ZREVRANGE/ZSCORE/ZCOUNT/ZCARD），
+// 以后可以直接换成 Redis 实现
+type LeaderboardStore interface {
+	// 增加分数并返回新分数
+	IncrBy(board string, userID uint, delta int) (int, error)
+	Remove(board string, userID uint) error
+	// 用全量数据替换整个榜单
+	Replace(board string, scores []repository.RankingScore) error
+	// 按分数降序返回从 offset 起的 limit 条
+	Range(board string, offset, limit int) ([]repository.RankingScore, error)
+	Score(board string, userID uint) (int, bool, error)
+	// 分数严格大于 score 的人数，用于计算名次
+	CountAbove(board string, score int) (int, error)
+	Count(board string) (int, error)
+	Drop(board string) error
+}
+
+// 进程内实现：每个榜单一个跳表
+type memoryLeaderboardStore struct {
+	mu     sync.RWMutex
+	boards map[string]*utils.SkipList
+}
+
+func newMemoryLeaderboardStore() *memoryLeaderboardStore {
+	return &memoryLeaderboardStore{boards: make(map[string]*utils.SkipList)}
+}
+
+func (s *memoryLeaderboardStore) IncrBy(board string, userID uint, delta int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sl, ok := s.boards[board]
+	if !ok {
+		sl = utils.NewSkipList()
+		s.boards[board] = sl
+	}
+	return sl.IncrBy(userID, delta), nil
+}
+
+func (s *memoryLeaderboardStore) Remove(board string, userID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sl, ok := s.boards[board]; ok {
+		sl.Remove(userID)
+	}
+	return nil
+}
+
+func (s *memoryLeaderboardStore) Replace(board string, scores []repository.RankingScore) error {
+	sl := utils.NewSkipList()
+	for _, score := range scores {
+		sl.Set(score.UserID, score.Score)
+	}
+	s.mu.Lock()
+	s.boards[board] = sl
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryLeaderboardStore) Range(board string, offset, limit int) ([]repository.RankingScore, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	scores := []repository.RankingScore{}
+	if sl, ok := s.boards[board]; ok {
+		for _, e := range sl.Range(offset, limit) {
+			scores = append(scores, repository.RankingScore{UserID: e.Member, Score: e.Score})
+		}
+	}
+	return scores, nil
+}
+
+func (s *memoryLeaderboardStore) Score(board string, userID uint) (int, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if sl, ok := s.boards[board]; ok {
+		score, found := sl.Score(userID)
+		return score, found, nil
+	}
+	return 0, false, nil
+}
+
+func (s *memoryLeaderboardStore) CountAbove(board string, score int) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if sl, ok := s.boards[board]; ok {
+		return sl.CountAbove(score), nil
+	}
+	return 0, nil
+}
+
+func (s *memoryLeaderboardStore) Count(board string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if sl, ok := s.boards[board]; ok {
+		return sl.Len(), nil
+	}
+	return 0, nil
+}
+
+func (s *memoryLeaderboardStore) Drop(board string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.boards, board)
+	return nil
+}
+
+var (
+	leaderboards LeaderboardStore = newMemoryLeaderboardStore()
+
+	// 每个 指标:时间范围 当前使用的榜单名，周期切换时删除旧榜单
+	boardKeysMu sync.Mutex
+	boardKeys   = make(map[string]string)
+)
+
+var (
+	rankingMetrics = []string{rankingMetricPoints, rankingMetricLearnTime, rankingMetricDaka, rankingMetricFlags}
+	rankingWindows = []string{rankingWindowToday, rankingWindowWeek, rankingWindowMonth, rankingWindowAll}
+)
+
+// 榜单名带上周期，例如 ranking:points:week:2025-11-03
+func leaderboardKey(metric, window string, now time.Time) string {
+	key := "ranking:" + metric + ":" + window
+	switch window {
+	case rankingWindowToday:
+		return key + ":" + now.Format("2006-01-02")
+	case rankingWindowWeek:
+		return key + ":" + weekStartOf(now).Format("2006-01-02")
+	case rankingWindowMonth:
+		return key + ":" + now.Format("2006-01")
+	default:
+		return key
+	}
+}
+
+// 当前周期的榜单名；进入新周期时旧榜单作废，新榜单从空开始累计
+func currentLeaderboard(metric, window string) string {
+	key := leaderboardKey(metric, window, time.Now())
+	slot := metric + ":" + window
+	boardKeysMu.Lock()
+	old, ok := boardKeys[slot]
+	boardKeys[slot] = key
+	boardKeysMu.Unlock()
+	if ok && old != key {
+		leaderboards.Drop(old)
+	}
+	return key
+}
+
+// 从数据库重建所有榜单（启动时和每天凌晨执行，修正增量更新可能出现的偏差）
+func RebuildLeaderboards() {
+	start := time.Now()
+	for _, metric := range rankingMetrics {
+		for _, window := range rankingWindows {
+			scores, err := loadRankingScores(metric, window)
+			if err != nil {
+				utils.LogError("重建封神榜失败", logrus.Fields{"metric": metric, "window": window, "error": err.Error()})
+				continue
+			}
+			leaderboards.Replace(currentLeaderboard(metric, window), scores)
+		}
+	}
+	utils.LogInfo("封神榜重建完成", logrus.Fields{"cost": time.Since(start).String()})
+}
+
+// 把分数变化累加到该指标的所有时间范围
+func incrLeaderboards(metric string, userID uint, delta int) {
+	if userID == 0 || delta == 0 {
+		return
+	}
+	for _, window := range rankingWindows {
+		board := currentLeaderboard(metric, window)
+		score, err := leaderboards.IncrBy(board, userID, delta)
+		if err != nil {
+			utils.LogError("更新封神榜失败", logrus.Fields{"board": board, "user_id": userID, "error": err.Error()})
+			continue
+		}
+		// 与数据库查询保持一致：分数为0的用户不上榜
+		if score <= 0 {
+			leaderboards.Remove(board, userID)
+		}
+	}
+}
+
+// 事件载荷中的整数
+func payloadInt(payload map[string]interface{}, key string) int {
+	switch v := payload[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+func init() {
+	SubscribeEvent(EventPointsAwarded, func(e DomainEvent) {
+		incrLeaderboards(rankingMetricPoints, e.UserID, payloadInt(e.Payload, "amount"))
+	})
+	SubscribeEvent(EventLearnTimeRecorded, func(e DomainEvent) {
+		incrLeaderboards(rankingMetricLearnTime, e.UserID, payloadInt(e.Payload, "duration"))
+	})
+	SubscribeEvent(EventFlagCompleted, func(e DomainEvent) {
+		incrLeaderboards(rankingMetricFlags, e.UserID, 1)
+	})
+	// 打卡支持取消，done=false 时减一
+	SubscribeEvent(EventDaka, func(e DomainEvent) {
+		delta := 1
+		if done, ok := e.Payload["done"].(bool); ok && !done {
+			delta = -1
+		}
+		incrLeaderboards(rankingMetricDaka, e.UserID, delta)
+	})
+}
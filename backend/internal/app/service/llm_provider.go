@@ -0,0 +1,385 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 大模型对话消息
+type LLMMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// 大模型请求
+// Task 标识调用场景（如 learning_plan），真实 provider 忽略它，fake provider 用它挑选预置响应
+type LLMRequest struct {
+	Task        string
+	Messages    []LLMMessage
+	MaxTokens   int
+	Temperature float64
+}
+
+// 流式输出回调，每收到一段增量文本调用一次；返回错误时中止生成
+type LLMDeltaHandler func(delta string) error
+
+// 大模型 provider 抽象，便于切换服务商或在本地/测试中使用 fake
+type LLMProvider interface {
+	Name() string
+	ChatCompletion(ctx context.Context, req LLMRequest) (string, error)
+	// 流式生成，返回拼接后的完整文本
+	ChatCompletionStream(ctx context.Context, req LLMRequest, onDelta LLMDeltaHandler) (string, error)
+}
+
+const (
+	defaultLLMBaseURL = "https://api.siliconflow.cn/v1"
+	defaultLLMModel   = "Qwen/Qwen2.5-7B-Instruct"
+	taskLearningPlan  = "learning_plan"
+)
+
+// 根据环境变量创建 provider
+// LLM_PROVIDER=fake 时使用本地 fake，其余情况使用 OpenAI 兼容接口
+func NewLLMProviderFromEnv() LLMProvider {
+	if strings.EqualFold(os.Getenv("LLM_PROVIDER"), "fake") {
+		fmt.Printf("✅ 使用本地 fake LLM provider\n")
+		return NewFakeLLMProvider()
+	}
+
+	apiKey := os.Getenv("APIKEY")
+	if apiKey == "" {
+		fmt.Printf("❌ 警告：APIKEY环境变量未设置\n")
+	} else {
+		fmt.Printf("✅ API Key已加载，前缀: %s...\n", apiKey[:min(10, len(apiKey))])
+	}
+	baseURL := os.Getenv("LLM_BASE_URL")
+	if baseURL == "" {
+		baseURL = defaultLLMBaseURL
+	}
+	modelName := os.Getenv("LLM_MODEL")
+	if modelName == "" {
+		modelName = defaultLLMModel
+	}
+	return NewOpenAICompatibleProvider(baseURL, apiKey, modelName)
+}
+
+// OpenAI 兼容的 HTTP provider（SiliconFlow、DeepSeek、本地 vLLM 等）
+type OpenAICompatibleProvider struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	Client  *http.Client
+}
+
+func NewOpenAICompatibleProvider(baseURL, apiKey, modelName string) *OpenAICompatibleProvider {
+	return &OpenAICompatibleProvider{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		APIKey:  apiKey,
+		Model:   modelName,
+		// 创建带 60 秒超时的客户端（给 AI 充足时间响应）
+		Client: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+func (p *OpenAICompatibleProvider) Name() string {
+	return "openai-compatible"
+}
+
+// chat/completions 完整地址，兼容直接配置完整路径的写法
+func (p *OpenAICompatibleProvider) endpoint() string {
+	if strings.HasSuffix(p.BaseURL, "/chat/completions") {
+		return p.BaseURL
+	}
+	return p.BaseURL + "/chat/completions"
+}
+
+func (p *OpenAICompatibleProvider) newRequest(ctx context.Context, req LLMRequest, stream bool) (*http.Request, error) {
+	if p.APIKey == "" {
+		return nil, fmt.Errorf("❌ API密钥未配置，请检查环境变量 APIKEY")
+	}
+
+	maxTokens := req.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = 4000
+	}
+	temperature := req.Temperature
+	if temperature == 0 {
+		temperature = 0.7
+	}
+	requestData := map[string]interface{}{
+		"model":       p.Model,
+		"messages":    req.Messages,
+		"max_tokens":  maxTokens,
+		"temperature": temperature,
+		"stream":      stream,
+	}
+	requestBody, err := json.Marshal(requestData)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %v", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", p.endpoint(), bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+p.APIKey)
+	return httpReq, nil
+}
+
+func (p *OpenAICompatibleProvider) ChatCompletion(ctx context.Context, req LLMRequest) (string, error) {
+	httpReq, err := p.newRequest(ctx, req, false)
+	if err != nil {
+		fmt.Printf("❌ 构建请求失败: %v\n", err)
+		return "", err
+	}
+
+	fmt.Printf("📤 发送请求到: %s, 模型: %s\n", httpReq.URL, p.Model)
+	resp, err := p.Client.Do(httpReq)
+	if err != nil {
+		fmt.Printf("❌ 发送请求失败: %v\n", err)
+		return "", fmt.Errorf("❌ 发送请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	fmt.Printf("📥 收到响应，状态码: %d\n", resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		fmt.Printf("❌ 读取响应失败: %v\n", err)
+		return "", fmt.Errorf("读取响应失败: %v", err)
+	}
+
+	var response struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Data    struct {
+			Choices []struct {
+				Message struct {
+					Content string `json:"content"`
+				} `json:"message"`
+			} `json:"choices"`
+		} `json:"data"`
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		fmt.Printf("❌ 解析响应失败: %v\n", err)
+		fmt.Printf("原始响应内容: %s\n", string(body))
+		return "", fmt.Errorf("解析响应失败: %v", err)
+	}
+
+	// 检查是否有错误码
+	if response.Code != 0 && response.Code != 200 {
+		fmt.Printf("❌ LLM API 返回错误码: %d, 消息: %s\n", response.Code, response.Message)
+		return "", fmt.Errorf("❌ LLM API 错误: %s (错误码: %d)", response.Message, response.Code)
+	}
+
+	if response.Error.Message != "" {
+		fmt.Printf("❌ LLM API 错误字段: %s\n", response.Error.Message)
+		return "", fmt.Errorf("❌ LLM API 错误: %s", response.Error.Message)
+	}
+
+	// 优先检查 Data 中的 Choices（某些版本 API）
+	if len(response.Data.Choices) > 0 {
+		return response.Data.Choices[0].Message.Content, nil
+	}
+
+	// 备选：检查顶级的 Choices
+	if len(response.Choices) > 0 {
+		return response.Choices[0].Message.Content, nil
+	}
+
+	fmt.Printf("❌ 未收到有效的 AI 响应，响应结构: %+v\n", response)
+	return "", fmt.Errorf("未收到有效的 AI 响应")
+}
+
+// 流式调用：stream=true，逐行解析 SSE 的 data 帧
+func (p *OpenAICompatibleProvider) ChatCompletionStream(ctx context.Context, req LLMRequest, onDelta LLMDeltaHandler) (string, error) {
+	httpReq, err := p.newRequest(ctx, req, true)
+	if err != nil {
+		fmt.Printf("❌ 构建流式请求失败: %v\n", err)
+		return "", err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	// 流式响应持续时间较长，不使用整体超时，由 ctx 控制取消
+	client := &http.Client{Transport: p.Client.Transport}
+	fmt.Printf("📤 发送流式请求到: %s, 模型: %s\n", httpReq.URL, p.Model)
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		fmt.Printf("❌ 发送流式请求失败: %v\n", err)
+		return "", fmt.Errorf("❌ 发送请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		fmt.Printf("❌ 流式请求返回状态码: %d, 内容: %s\n", resp.StatusCode, string(body))
+		return "", fmt.Errorf("❌ LLM API 错误: 状态码 %d", resp.StatusCode)
+	}
+
+	var full strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			fmt.Printf("⚠️ 跳过无法解析的流式数据: %s\n", data)
+			continue
+		}
+		if chunk.Error.Message != "" {
+			return full.String(), fmt.Errorf("❌ LLM API 错误: %s", chunk.Error.Message)
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		delta := chunk.Choices[0].Delta.Content
+		full.WriteString(delta)
+		if err := onDelta(delta); err != nil {
+			return full.String(), err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return full.String(), fmt.Errorf("读取流式响应失败: %v", err)
+	}
+	if full.Len() == 0 {
+		return "", fmt.Errorf("未收到有效的 AI 响应")
+	}
+	return full.String(), nil
+}
+
+// 本地 fake provider：不访问网络，返回确定性的预置响应
+// Responses 非空时按调用顺序循环返回；否则按 Task 生成默认响应
+type FakeLLMProvider struct {
+	mu        sync.Mutex
+	Responses []string
+	Calls     []LLMRequest
+}
+
+func NewFakeLLMProvider(responses ...string) *FakeLLMProvider {
+	return &FakeLLMProvider{Responses: responses}
+}
+
+func (p *FakeLLMProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeLLMProvider) ChatCompletion(ctx context.Context, req LLMRequest) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Calls = append(p.Calls, req)
+	if len(p.Responses) > 0 {
+		return p.Responses[(len(p.Calls)-1)%len(p.Responses)], nil
+	}
+	return fakeResponseFor(req), nil
+}
+
+// 将完整响应按固定长度切片依次回调，模拟流式输出
+func (p *FakeLLMProvider) ChatCompletionStream(ctx context.Context, req LLMRequest, onDelta LLMDeltaHandler) (string, error) {
+	response, err := p.ChatCompletion(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	runes := []rune(response)
+	const chunkSize = 16
+	for i := 0; i < len(runes); i += chunkSize {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if err := onDelta(string(runes[i:min(i+chunkSize, len(runes))])); err != nil {
+			return "", err
+		}
+	}
+	return response, nil
+}
+
+// 按场景生成默认 fake 响应
+func fakeResponseFor(req LLMRequest) string {
+	switch req.Task {
+	case taskLearningPlan:
+		return fakeLearningPlanResponse(lastUserContent(req.Messages))
+	case taskPlanRevision:
+		return fakePlanRevisionResponse(lastUserContent(req.Messages))
+	case taskWeeklyReport:
+		return fakeWeeklyReportResponse(lastUserContent(req.Messages))
+	case taskModeration:
+		return `{"action":"allow","reason":"fake provider 不做审核"}`
+	default:
+		return "{}"
+	}
+}
+
+func lastUserContent(messages []LLMMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// 从用户提示词中取出学习目标，生成符合解析格式的学习计划
+func fakeLearningPlanResponse(userPrompt string) string {
+	goal := "学习目标"
+	for _, line := range strings.Split(userPrompt, "\n") {
+		if strings.HasPrefix(line, "学习目标:") {
+			goal = strings.TrimSpace(strings.TrimPrefix(line, "学习目标:"))
+			break
+		}
+	}
+	plan := fmt.Sprintf(`【目标概述】系统掌握%s，从基础到实践，逐步建立稳定的学习习惯。
+
+阶段一：基础入门（预计1-3天）
+【阶段目标】了解%s的核心概念，完成入门练习。
+【学习要点】
+- 梳理基础概念和常用术语。
+- 找到一份系统的入门资料。
+【实践建议】
+每天固定时间学习，记录学习笔记。
+【具体任务】
+1. 阅读入门资料并做笔记（每日完成：1次）
+2. 完成基础练习题（每日完成：1次）
+3. 总结当天收获（每日完成：1次）`, goal, goal)
+	out, _ := json.Marshal(map[string]interface{}{
+		"flag":       goal + "学习计划",
+		"difficulty": 100,
+		"plan":       plan,
+	})
+	return string(out)
+}
@@ -0,0 +1,444 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// 审核结果的处理方式，按严重程度递增
+type ModerationAction int
+
+const (
+	ModerationAllow  ModerationAction = iota // 放行
+	ModerationReview                         // 放行并进入人工复核
+	ModerationMask                           // 敏感词替换为*后放行
+	ModerationBlock                          // 拒绝
+)
+
+// 审核场景
+const (
+	sceneAIGoal      = "ai_goal"
+	scenePost        = "post"
+	scenePostComment = "post_comment"
+	sceneFlagComment = "flag_comment"
+	sceneChat        = "chat"
+	sceneGroup       = "group"
+)
+
+const (
+	defaultSensitiveWordsFile = "config/sensitive_words.txt"
+	taskModeration            = "moderation"
+	moderationLLMTimeout      = 5 * time.Second
+	ctxModerationRecordIDs    = "moderation_record_ids" // gin.Context 中本次请求产生的审核记录ID
+)
+
+func (a ModerationAction) String() string {
+	switch a {
+	case ModerationReview:
+		return "review"
+	case ModerationMask:
+		return "mask"
+	case ModerationBlock:
+		return "block"
+	default:
+		return "allow"
+	}
+}
+
+func parseModerationAction(s string) (ModerationAction, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "allow":
+		return ModerationAllow, true
+	case "review":
+		return ModerationReview, true
+	case "mask":
+		return ModerationMask, true
+	case "block":
+		return ModerationBlock, true
+	default:
+		return ModerationAllow, false
+	}
+}
+
+// 审核结果
+type ModerationResult struct {
+	Action   ModerationAction
+	Text     string   // 处理后的文本（mask 时为替换后的内容）
+	Hits     []string // 命中的敏感词
+	Source   string   // dict / llm
+	Reason   string
+	Review   bool // 是否需要人工复核（与 Action 独立，mask 的内容也可能同时需要复核）
+	RecordID uint // 保存的审核记录ID（未命中时为 0）
+}
+
+// 敏感词词典
+type sensitiveDict struct {
+	words   []string
+	actions []ModerationAction
+	matcher *utils.AhoCorasick
+}
+
+var (
+	dictOnce sync.Once
+	dictMu   sync.RWMutex
+	dict     *sensitiveDict
+)
+
+// 读取词典文件：每行 "词,处理方式"，# 开头为注释
+func loadSensitiveDict(path string) (*sensitiveDict, error) {
+	d := &sensitiveDict{}
+	file, err := os.Open(path)
+	if err != nil {
+		d.matcher = utils.NewAhoCorasick(nil)
+		return d, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		word, actionStr, _ := strings.Cut(line, ",")
+		word = strings.TrimSpace(word)
+		// 省略处理方式时默认替换
+		action, ok := ModerationMask, true
+		if strings.TrimSpace(actionStr) != "" {
+			action, ok = parseModerationAction(actionStr)
+		}
+		if word == "" || !ok {
+			utils.LogError("敏感词配置格式错误", logrus.Fields{"line": line})
+			continue
+		}
+		d.words = append(d.words, word)
+		d.actions = append(d.actions, action)
+	}
+	d.matcher = utils.NewAhoCorasick(d.words)
+	return d, scanner.Err()
+}
+
+func sensitiveWordsFile() string {
+	if path := os.Getenv("SENSITIVE_WORDS_FILE"); path != "" {
+		return path
+	}
+	return defaultSensitiveWordsFile
+}
+
+// 重新加载敏感词词典（修改词库文件后调用）
+func ReloadSensitiveWords() error {
+	path := sensitiveWordsFile()
+	d, err := loadSensitiveDict(path)
+	if err != nil {
+		utils.LogError("加载敏感词词典失败", logrus.Fields{"path": path, "error": err.Error()})
+	} else {
+		utils.LogInfo("加载敏感词词典成功", logrus.Fields{"path": path, "words": len(d.words)})
+	}
+	dictMu.Lock()
+	dict = d
+	dictMu.Unlock()
+	return err
+}
+
+func getSensitiveDict() *sensitiveDict {
+	dictOnce.Do(func() { ReloadSensitiveWords() })
+	dictMu.RLock()
+	defer dictMu.RUnlock()
+	return dict
+}
+
+// 是否启用模型辅助审核（MODERATION_USE_LLM=true 开启）
+func moderationUseLLM() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("MODERATION_USE_LLM"))
+	return enabled
+}
+
+// 词典匹配：取命中词中最严重的处理方式，mask 时替换命中的字符；命中复核词时单独标记 Review
+func (d *sensitiveDict) check(text string) ModerationResult {
+	result := ModerationResult{Action: ModerationAllow, Text: text, Source: "dict"}
+	matches := d.matcher.FindAll(text)
+	if len(matches) == 0 {
+		return result
+	}
+
+	masked := make(map[int]bool)
+	seen := make(map[int]bool)
+	for _, m := range matches {
+		action := d.actions[m.Pattern]
+		if action > result.Action {
+			result.Action = action
+		}
+		if action == ModerationReview {
+			result.Review = true
+		}
+		if !seen[m.Pattern] {
+			seen[m.Pattern] = true
+			result.Hits = append(result.Hits, d.words[m.Pattern])
+		}
+		if action == ModerationMask {
+			for i := m.Start; i < m.End; i++ {
+				masked[i] = true
+			}
+		}
+	}
+	if len(masked) > 0 {
+		runes := []rune(text)
+		for i := range masked {
+			runes[i] = '*'
+		}
+		result.Text = string(runes)
+	}
+	return result
+}
+
+const moderationSystemPrompt = `你是社区内容审核员，判断用户发布的内容是否违规（辱骂、色情、违法、广告引流、考试作弊等）。
+
+【返回JSON格式】（不要包含markdown代码块标记）
+{"action": "allow|review|block", "reason": "简短理由"}
+
+不确定时返回 review，正常学习交流内容返回 allow。`
+
+// 调用模型对内容分类，出错时放行
+func classifyWithLLM(ctx context.Context, text string) (ModerationAction, string) {
+	initPlanner()
+	ctx, cancel := context.WithTimeout(ctx, moderationLLMTimeout)
+	defer cancel()
+	response, err := planner.Provider.ChatCompletion(ctx, LLMRequest{
+		Task: taskModeration,
+		Messages: []LLMMessage{
+			{Role: "system", Content: moderationSystemPrompt},
+			{Role: "user", Content: text},
+		},
+		MaxTokens: 100,
+	})
+	if err != nil {
+		utils.LogError("模型审核失败", logrus.Fields{"error": err.Error()})
+		return ModerationAllow, ""
+	}
+	clean := strings.TrimSpace(response)
+	clean = strings.TrimPrefix(clean, "```json")
+	clean = strings.TrimPrefix(clean, "```")
+	clean = strings.TrimSuffix(clean, "```")
+
+	var result struct {
+		Action string `json:"action"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(clean)), &result); err != nil {
+		utils.LogError("解析模型审核结果失败", logrus.Fields{"response": response, "error": err.Error()})
+		return ModerationAllow, ""
+	}
+	// 模型结果不做替换，mask 视为需要复核
+	action, ok := parseModerationAction(result.Action)
+	if !ok {
+		return ModerationAllow, ""
+	}
+	if action == ModerationMask {
+		action = ModerationReview
+	}
+	return action, result.Reason
+}
+
+// 审核文本：先匹配敏感词词典，未被拒绝且开启模型审核时再交给模型判断
+func moderateText(ctx context.Context, scene string, userID uint, text string) ModerationResult {
+	if strings.TrimSpace(text) == "" {
+		return ModerationResult{Action: ModerationAllow, Text: text}
+	}
+	result := getSensitiveDict().check(text)
+	if result.Action != ModerationBlock && moderationUseLLM() {
+		action, reason := classifyWithLLM(ctx, text)
+		if action > result.Action {
+			result.Action = action
+			result.Reason = reason
+			result.Source = "llm"
+			result.Text = text
+		}
+		if action == ModerationReview {
+			result.Review = true
+			if result.Reason == "" {
+				result.Reason = reason
+			}
+		}
+	}
+	if result.Action != ModerationAllow || result.Review {
+		result.RecordID = recordModeration(scene, userID, text, result)
+	}
+	return result
+}
+
+// 保存审核记录并返回记录ID；需要复核且已放行（未被拒绝）的内容进入人工复核队列
+func recordModeration(scene string, userID uint, content string, result ModerationResult) uint {
+	status := model.ModerationStatusDone
+	if result.Review && result.Action != ModerationBlock {
+		status = model.ModerationStatusPending
+	}
+	record := model.ModerationRecord{
+		UserID:  userID,
+		Scene:   scene,
+		Content: content,
+		Action:  result.Action.String(),
+		Hits:    strings.Join(result.Hits, ","),
+		Source:  result.Source,
+		Reason:  result.Reason,
+		Status:  status,
+	}
+	if err := repository.SaveModerationRecord(&record); err != nil {
+		utils.LogError("保存审核记录失败", logrus.Fields{"user_id": userID, "scene": scene, "error": err.Error()})
+	}
+	utils.LogInfo("内容审核命中", logrus.Fields{"user_id": userID, "scene": scene, "action": record.Action, "hits": record.Hits})
+	return record.ID
+}
+
+// 审核请求中的文本，被拒绝时直接返回 400（封禁中的用户返回 403）；通过时返回处理后的文本
+func moderateOrReject(c *gin.Context, scene string, userID uint, text string) (string, bool) {
+	if rejectIfUserSuspended(c, userID) {
+		return "", false
+	}
+	result := moderateText(c.Request.Context(), scene, userID, text)
+	if result.Action == ModerationBlock {
+		c.JSON(400, gin.H{"error": "内容包含违规信息，请修改后再试", "success": false})
+		return "", false
+	}
+	if result.RecordID != 0 {
+		ids, _ := c.Get(ctxModerationRecordIDs)
+		recordIDs, _ := ids.([]uint)
+		c.Set(ctxModerationRecordIDs, append(recordIDs, result.RecordID))
+	}
+	return result.Text, true
+}
+
+// 内容保存后，把本次请求产生的审核记录关联到这条内容，复核判定违规时据此隐藏
+func linkModerationTarget(c *gin.Context, targetType string, targetID uint) {
+	ids, _ := c.Get(ctxModerationRecordIDs)
+	recordIDs, _ := ids.([]uint)
+	if err := repository.SetModerationRecordTarget(recordIDs, targetType, targetID); err != nil {
+		utils.LogError("关联审核记录失败", logrus.Fields{"target_type": targetType, "target_id": targetID, "error": err.Error()})
+	}
+}
+
+// 人工复核队列中的审核记录（附带作者）
+type ModerationItem struct {
+	model.ModerationRecord
+	User UserBrief `json:"user"`
+}
+
+// 人工复核队列（管理员）：status=pending/done/approved/rejected/all（默认 pending），可按 scene 筛选
+func GetModerationQueue() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := c.DefaultQuery("status", model.ModerationStatusPending)
+		switch status {
+		case model.ModerationStatusPending, model.ModerationStatusDone, model.ModerationStatusApproved, model.ModerationStatusRejected:
+		case "all":
+			status = ""
+		default:
+			c.JSON(400, gin.H{"error": "status 只能是 pending/done/approved/rejected/all"})
+			return
+		}
+		page, limit := parsePagination(c)
+		records, total, err := repository.GetModerationRecords(status, c.Query("scene"), (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取审核记录失败,请重新再试..."})
+			utils.LogError("获取审核记录失败", logrus.Fields{"error": err.Error()})
+			return
+		}
+		userIDs := make([]uint, 0, len(records))
+		for _, r := range records {
+			userIDs = append(userIDs, r.UserID)
+		}
+		briefs, err := loadUserBriefs(userIDs)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取审核记录失败,请重新再试..."})
+			utils.LogError("获取审核记录作者失败", logrus.Fields{"error": err.Error()})
+			return
+		}
+		items := make([]ModerationItem, 0, len(records))
+		for _, r := range records {
+			items = append(items, ModerationItem{ModerationRecord: r, User: briefs[r.UserID]})
+		}
+		c.JSON(200, gin.H{"success": true, "data": items, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// 复核审核记录（管理员）：decision=approve 放行，reject 判定违规、隐藏对应内容并警告作者
+func ResolveModeration() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID, _ := getCurrentUserID(c)
+		recordID, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+		var req struct {
+			Decision string `json:"decision"`
+			Note     string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid input"})
+			return
+		}
+		var status string
+		switch req.Decision {
+		case "approve":
+			status = model.ModerationStatusApproved
+		case "reject":
+			status = model.ModerationStatusRejected
+		default:
+			c.JSON(400, gin.H{"error": "decision 只能是 approve/reject"})
+			return
+		}
+		record, err := repository.GetModerationRecordByID(recordID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "审核记录不存在"})
+			return
+		}
+		note := strings.TrimSpace(req.Note)
+		updated, err := repository.ResolveModerationRecord(recordID, status, note, adminID, time.Now())
+		if err != nil {
+			c.JSON(500, gin.H{"error": "处理审核记录失败,请重新再试..."})
+			utils.LogError("处理审核记录失败", logrus.Fields{"record_id": recordID, "error": err.Error()})
+			return
+		}
+		if !updated {
+			c.JSON(409, gin.H{"error": "该记录不需要复核或已处理"})
+			return
+		}
+		if status == model.ModerationStatusRejected {
+			if record.TargetType != "" {
+				err := repository.SetContentHidden(record.TargetType, record.TargetID, true)
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					utils.LogError("隐藏复核违规内容失败", logrus.Fields{"record_id": recordID, "target_type": record.TargetType, "target_id": record.TargetID, "error": err.Error()})
+				}
+			}
+			if err := repository.AddUserWarning(record.UserID); err != nil {
+				utils.LogError("增加用户警告次数失败", logrus.Fields{"user_id": record.UserID, "error": err.Error()})
+			}
+			content := "你发布的内容经人工复核违反社区规范，请遵守社区规范"
+			if note != "" {
+				content += "：" + note
+			}
+			notify(model.Notification{
+				UserID:     record.UserID,
+				Type:       model.NotificationModeration,
+				TargetType: "moderation",
+				TargetID:   record.ID,
+				Content:    content,
+				Data:       gin.H{"scene": record.Scene, "action": "warn"},
+			})
+		}
+		utils.LogInfo("管理员复核审核记录", logrus.Fields{"admin_id": adminID, "record_id": recordID, "status": status})
+		c.JSON(200, gin.H{"success": true, "status": status})
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+)
+
+func newTestSensitiveDict(words map[string]ModerationAction) *sensitiveDict {
+	d := &sensitiveDict{}
+	for word, action := range words {
+		d.words = append(d.words, word)
+		d.actions = append(d.actions, action)
+	}
+	d.matcher = utils.NewAhoCorasick(d.words)
+	return d
+}
+
+func TestSensitiveDictCheck(t *testing.T) {
+	d := newTestSensitiveDict(map[string]ModerationAction{
+		"笨蛋": ModerationMask,
+		"代考": ModerationReview,
+		"枪支": ModerationBlock,
+	})
+	tests := []struct {
+		name   string
+		text   string
+		action ModerationAction
+		review bool
+		masked string
+		hits   []string
+	}{
+		{name: "clean", text: "一起学习", action: ModerationAllow, masked: "一起学习"},
+		{name: "mask only", text: "你这个笨蛋", action: ModerationMask, masked: "你这个**", hits: []string{"笨蛋"}},
+		{name: "review only", text: "找人代考", action: ModerationReview, review: true, masked: "找人代考", hits: []string{"代考"}},
+		{name: "mask and review keeps review flag", text: "笨蛋才代考", action: ModerationMask, review: true, masked: "**才代考", hits: []string{"笨蛋", "代考"}},
+		{name: "block wins", text: "代考卖枪支", action: ModerationBlock, review: true, masked: "代考卖枪支", hits: []string{"代考", "枪支"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := d.check(tt.text)
+			if got.Action != tt.action || got.Review != tt.review || got.Text != tt.masked {
+				t.Errorf("check(%q) = (%v, review=%v, %q), want (%v, review=%v, %q)", tt.text, got.Action, got.Review, got.Text, tt.action, tt.review, tt.masked)
+			}
+			if !reflect.DeepEqual(got.Hits, tt.hits) {
+				t.Errorf("hits = %v, want %v", got.Hits, tt.hits)
+			}
+		})
+	}
+}
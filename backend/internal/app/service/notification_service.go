@@ -0,0 +1,272 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	notificationExcerptLength = 50                  // 通知摘要长度（字）
+	notificationRetention     = 90 * 24 * time.Hour // 已读通知保留时长
+)
+
+// 返回给前端的通知（附带触发者信息）
+type NotificationItem struct {
+	model.Notification
+	Actor *UserBrief `json:"actor,omitempty"`
+}
+
+// 保存通知并推送给在线的接收者；给自己或与触发者存在拉黑关系时不通知
+func notify(n model.Notification) {
+	if n.UserID == 0 || n.UserID == n.ActorID {
+		return
+	}
+	if n.ActorID != 0 {
+		if blocked, err := repository.IsBlockedBetween(n.UserID, n.ActorID); err != nil || blocked {
+			return
+		}
+	}
+	n.Content = excerpt(n.Content, notificationExcerptLength)
+	if err := repository.SaveNotification(&n); err != nil {
+		utils.LogError("保存通知失败", logrus.Fields{"user_id": n.UserID, "type": n.Type, "error": err.Error()})
+		return
+	}
+	pushNotification(n)
+}
+
+// 同一触发者对同一目标只通知一次（如反复点赞、取消点赞）
+func notifyOnce(n model.Notification) {
+	_, err := repository.FindNotification(n.UserID, n.Type, n.ActorID, n.TargetType, n.TargetID, false)
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.LogError("查询通知失败", logrus.Fields{"user_id": n.UserID, "type": n.Type, "error": err.Error()})
+		return
+	}
+	notify(n)
+}
+
+// 通过 WebSocket 推送通知和最新未读数（不在线时忽略）
+func pushNotification(n model.Notification) {
+	if !manager.IsOnline(n.UserID) {
+		return
+	}
+	items, err := buildNotificationItems([]model.Notification{n})
+	if err != nil {
+		utils.LogError("补全通知信息失败", logrus.Fields{"notification_id": n.ID, "error": err.Error()})
+		return
+	}
+	unread, _ := repository.CountUnreadNotifications(n.UserID)
+	manager.SendToUser(n.UserID, gin.H{"type": "notification", "data": items[0], "unread_count": unread})
+}
+
+func buildNotificationItems(notifications []model.Notification) ([]NotificationItem, error) {
+	var actorIDs []uint
+	for _, n := range notifications {
+		if n.ActorID != 0 {
+			actorIDs = append(actorIDs, n.ActorID)
+		}
+	}
+	actors, err := loadUserBriefs(actorIDs)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]NotificationItem, 0, len(notifications))
+	for _, n := range notifications {
+		item := NotificationItem{Notification: n}
+		if actor, ok := actors[n.ActorID]; ok {
+			item.Actor = &actor
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// 新评论：回复时通知被回复的人，同时通知帖子或flag的主人（是同一个人时只通知一次）
+func notifyNewComment(content, targetType string, commentID, authorID, ownerID, parentAuthorID uint, data gin.H) {
+	if parentAuthorID != 0 {
+		notify(model.Notification{
+			UserID:     parentAuthorID,
+			Type:       model.NotificationReply,
+			ActorID:    authorID,
+			TargetType: targetType,
+			TargetID:   commentID,
+			Content:    content,
+			Data:       data,
+		})
+		if parentAuthorID == ownerID {
+			return
+		}
+	}
+	notify(model.Notification{
+		UserID:     ownerID,
+		Type:       model.NotificationComment,
+		ActorID:    authorID,
+		TargetType: targetType,
+		TargetID:   commentID,
+		Content:    content,
+		Data:       data,
+	})
+}
+
+// 离线私信：同一个人发来的未读私信合并成一条通知
+func notifyOfflineMessage(toID, fromID uint, messageID uint, content string) {
+	existing, err := repository.FindNotification(toID, model.NotificationMessage, fromID, model.ContentTypeChatMessage, 0, true)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.LogError("查询通知失败", logrus.Fields{"user_id": toID, "error": err.Error()})
+		return
+	}
+	if err == nil {
+		count, _ := existing.Data["count"].(float64)
+		existing.Content = excerpt(content, notificationExcerptLength)
+		existing.Data = gin.H{"from_id": fromID, "message_id": messageID, "count": int(count) + 1}
+		existing.CreatedAt = time.Now()
+		if err := repository.SaveNotification(&existing); err != nil {
+			utils.LogError("更新通知失败", logrus.Fields{"notification_id": existing.ID, "error": err.Error()})
+		}
+		return
+	}
+	notify(model.Notification{
+		UserID:     toID,
+		Type:       model.NotificationMessage,
+		ActorID:    fromID,
+		TargetType: model.ContentTypeChatMessage,
+		Content:    content,
+		Data:       gin.H{"from_id": fromID, "message_id": messageID, "count": 1},
+	})
+}
+
+// 成就解锁时写入通知（实时弹窗仍由 achievement_unlocked 推送）
+func notifyAchievementNotification(event DomainEvent) {
+	name, _ := event.Payload["name"].(string)
+	id, _ := event.Payload["id"].(uint)
+	notify(model.Notification{
+		UserID:     event.UserID,
+		Type:       model.NotificationAchievement,
+		TargetType: model.NotificationTargetAchievement,
+		TargetID:   id,
+		Content:    "解锁成就「" + name + "」",
+		Data:       event.Payload,
+	})
+}
+
+// 定时删除过期的已读通知
+func CleanupNotifications() {
+	removed, err := repository.DeleteReadNotificationsBefore(time.Now().Add(-notificationRetention))
+	if err != nil {
+		utils.LogError("清理通知失败", logrus.Fields{"error": err.Error()})
+		return
+	}
+	if removed > 0 {
+		utils.LogInfo("清理已读通知完成", logrus.Fields{"count": removed})
+	}
+}
+
+// 通知列表（unread=true 时只看未读，page/limit 分页）
+func GetNotifications() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		page, limit := parsePagination(c)
+		unreadOnly := c.Query("unread") == "true"
+		notifications, total, err := repository.GetNotifications(id, unreadOnly, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取通知失败,请重新再试..."})
+			utils.LogError("获取通知失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		items, err := buildNotificationItems(notifications)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取通知失败,请重新再试..."})
+			utils.LogError("补全通知信息失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		unread, err := repository.CountUnreadNotifications(id)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取通知失败,请重新再试..."})
+			utils.LogError("获取未读通知数失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{
+			"success":      true,
+			"data":         items,
+			"total":        total,
+			"page":         page,
+			"limit":        limit,
+			"unread_count": unread,
+		})
+	}
+}
+
+// 未读通知数
+func GetUnreadNotificationCount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		unread, err := repository.CountUnreadNotifications(id)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取未读通知数失败,请重新再试..."})
+			utils.LogError("获取未读通知数失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "unread_count": unread})
+	}
+}
+
+// 标记一条通知为已读
+func MarkNotificationRead() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		notificationID, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+		if _, err := repository.GetNotificationByID(id, notificationID); err != nil {
+			c.JSON(404, gin.H{"error": "通知不存在"})
+			return
+		}
+		if err := repository.MarkNotificationRead(id, notificationID, time.Now()); err != nil {
+			c.JSON(500, gin.H{"error": "标记已读失败,请重新再试..."})
+			utils.LogError("标记通知已读失败", logrus.Fields{"user_id": id, "notification_id": notificationID, "error": err.Error()})
+			return
+		}
+		unread, _ := repository.CountUnreadNotifications(id)
+		c.JSON(200, gin.H{"success": true, "unread_count": unread})
+	}
+}
+
+// 全部标为已读
+func MarkAllNotificationsRead() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		updated, err := repository.MarkAllNotificationsRead(id, time.Now())
+		if err != nil {
+			c.JSON(500, gin.H{"error": "标记已读失败,请重新再试..."})
+			utils.LogError("全部标记已读失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "updated": updated, "unread_count": 0})
+	}
+}
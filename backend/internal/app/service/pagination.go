@@ -0,0 +1,23 @@
+package service
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 解析分页参数 page/limit（默认 page=1, limit=20，limit 最大 100）
+func parsePagination(c *gin.Context) (page int, limit int) {
+	page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return page, limit
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"time"
+
 	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
 	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
 	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
@@ -14,14 +16,29 @@ func PostUserPost() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, _ := getCurrentUserID(c)
 		var req struct {
-			Title   string `json:"title"`
-			Content string `json:"content"`
-			FlagID  *uint  `json:"flag_id"` // 关联的Flag ID（可选）
+			Title         string `json:"title"`
+			Content       string `json:"content"`
+			FlagID        *uint  `json:"flag_id"`        // 关联的Flag ID（可选）
+			AttachmentIDs []uint `json:"attachment_ids"` // 已上传的图片附件ID（可选）
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(400, gin.H{"error": "Invalid input"})
 			return
 		}
+		// 内容审核
+		title, ok := moderateOrReject(c, scenePost, id, req.Title)
+		if !ok {
+			return
+		}
+		content, ok := moderateOrReject(c, scenePost, id, req.Content)
+		if !ok {
+			return
+		}
+		req.Title, req.Content = title, content
+		attachmentIDs, ok := validateAttachmentIDs(c, id, req.AttachmentIDs)
+		if !ok {
+			return
+		}
 
 		post := model.Post{
 			Title:   req.Title,
@@ -29,23 +46,30 @@ func PostUserPost() gin.HandlerFunc {
 			FlagID:  req.FlagID,
 		}
 
-		err := repository.AddPostToDB(id, post)
+		err := repository.AddPostToDB(id, &post)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to add post"})
 			utils.LogError("数据库添加帖子失败", nil)
 			return
 		}
-		// 获取刚创建的帖子（包含ID和用户信息）
-		posts, _ := repository.GetAllPosts()
-		var createdPost model.Post
-		if len(posts) > 0 {
-			createdPost = posts[0] // 最新的帖子
+		bindAttachments(id, attachmentIDs, model.AttachmentOwnerPost, post.ID)
+		linkModerationTarget(c, model.ContentTypePost, post.ID)
+		text := post.Title + "\n" + post.Content
+		tags := indexContentTags(model.ContentTypePost, post.ID, text)
+		mentions := notifyMentions(model.ContentTypePost, post.ID, id, text, gin.H{"post_id": post.ID}, nil)
+		// 获取刚创建的帖子（包含用户信息和附件）
+		createdPost, err := repository.GetPostByID(post.ID)
+		if err != nil {
+			createdPost = post
 		}
 		utils.LogInfo("用户发布帖子成功", nil)
+		PublishEvent(EventPostCreated, id, nil)
 		c.JSON(200, gin.H{
-			"success": true,
-			"post":    createdPost,
-			"message": "帖子发布成功",
+			"success":  true,
+			"post":     createdPost,
+			"tags":     tags,
+			"mentions": mentions,
+			"message":  "帖子发布成功",
 		})
 	}
 }
@@ -84,11 +108,115 @@ func DeleteUserPost() gin.HandlerFunc {
 			utils.LogError("数据库删除帖子失败", nil)
 			return
 		}
+		releaseAttachments(model.AttachmentOwnerPost, req.PostID)
 		utils.LogInfo("用户删除帖子成功", logrus.Fields{"post_id": req.PostID, "user_id": userID})
 		c.JSON(200, gin.H{"success": true})
 	}
 }
 
+// 编辑帖子（仅作者），编辑前的版本保存到历史记录
+func EditUserPost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		postID, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+		var req struct {
+			Title   string `json:"title"`
+			Content string `json:"content"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid input"})
+			return
+		}
+
+		post, err := repository.GetPostByID(postID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "帖子不存在"})
+			return
+		}
+		if post.UserID != userID {
+			c.JSON(403, gin.H{"error": "无权编辑此帖子"})
+			return
+		}
+
+		// 内容审核
+		title, ok := moderateOrReject(c, scenePost, userID, req.Title)
+		if !ok {
+			return
+		}
+		content, ok := moderateOrReject(c, scenePost, userID, req.Content)
+		if !ok {
+			return
+		}
+		if title == post.Title && content == post.Content {
+			c.JSON(200, gin.H{"success": true, "post": post, "message": "内容没有变化"})
+			return
+		}
+
+		if err := repository.EditPost(&post, title, content, time.Now()); err != nil {
+			c.JSON(500, gin.H{"error": "编辑帖子失败,请重新再试..."})
+			utils.LogError("数据库编辑帖子失败", logrus.Fields{"post_id": postID, "error": err.Error()})
+			return
+		}
+		linkModerationTarget(c, model.ContentTypePost, post.ID)
+		// 重新索引话题，只提醒新增提及的用户
+		text := post.Title + "\n" + post.Content
+		tags := indexContentTags(model.ContentTypePost, post.ID, text)
+		mentions := notifyMentions(model.ContentTypePost, post.ID, userID, text, gin.H{"post_id": post.ID}, nil)
+		utils.LogInfo("用户编辑帖子成功", logrus.Fields{"post_id": postID, "user_id": userID})
+		c.JSON(200, gin.H{
+			"success":  true,
+			"post":     post,
+			"tags":     tags,
+			"mentions": mentions,
+			"message":  "帖子编辑成功",
+		})
+	}
+}
+
+// 分页获取帖子的历史版本
+func GetPostRevisions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		postID, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+		userID, _ := getCurrentUserID(c)
+		if _, ok := loadVisiblePost(c, userID, postID); !ok {
+			return
+		}
+		page, limit := parsePagination(c)
+		revisions, total, err := repository.GetPostRevisions(postID, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取历史版本失败,请重新再试..."})
+			utils.LogError("获取帖子历史版本失败", logrus.Fields{"post_id": postID, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "data": revisions, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// 帖子不存在、被隐藏或与作者存在拉黑关系时返回404
+func loadVisiblePost(c *gin.Context, userID, postID uint) (model.Post, bool) {
+	post, err := repository.GetPostVisibility(postID)
+	visible := err == nil && !post.Hidden
+	if visible && post.UserID != userID {
+		blocked, err := repository.IsBlockedBetween(userID, post.UserID)
+		visible = err == nil && !blocked
+	}
+	if !visible {
+		c.JSON(404, gin.H{"error": "帖子不存在"})
+		return model.Post{}, false
+	}
+	return post, true
+}
+
 // 发表帖子评论
 func CommentOnPost() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -99,13 +227,30 @@ func CommentOnPost() gin.HandlerFunc {
 		}
 
 		var req struct {
-			PostID  uint   `json:"postId"`
-			Content string `json:"content"`
+			PostID        uint   `json:"postId"`
+			ParentID      *uint  `json:"parentId"` // 回复某条评论时传入
+			Content       string `json:"content"`
+			AttachmentIDs []uint `json:"attachmentIds"` // 已上传的图片附件ID（可选）
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(400, gin.H{"error": "Invalid input"})
 			return
 		}
+		post, ok := loadVisiblePost(c, userID, req.PostID)
+		if !ok {
+			return
+		}
+
+		// 内容审核
+		content, ok := moderateOrReject(c, scenePostComment, userID, req.Content)
+		if !ok {
+			return
+		}
+		req.Content = content
+		attachmentIDs, ok := validateAttachmentIDs(c, userID, req.AttachmentIDs)
+		if !ok {
+			return
+		}
 
 		utils.LogInfo("开始添加评论", logrus.Fields{
 			"post_id": req.PostID,
@@ -118,8 +263,18 @@ func CommentOnPost() gin.HandlerFunc {
 			UserID:  userID,
 			Content: req.Content,
 		}
+		var parentAuthorID uint
+		if req.ParentID != nil {
+			parent, err := repository.GetCommentByID(*req.ParentID)
+			if err != nil || parent.PostID != req.PostID || parent.Hidden {
+				c.JSON(400, gin.H{"error": "回复的评论不存在"})
+				return
+			}
+			comment.ParentID = threadRootID(parent.ID, parent.ParentID)
+			parentAuthorID = parent.UserID
+		}
 
-		err := repository.AddPostCommentToDB(req.PostID, comment)
+		err := repository.AddPostCommentToDB(req.PostID, &comment)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to add comment"})
 			utils.LogError("数据库添加评论失败", logrus.Fields{
@@ -129,6 +284,15 @@ func CommentOnPost() gin.HandlerFunc {
 			return
 		}
 
+		bindAttachments(userID, attachmentIDs, model.AttachmentOwnerPostComment, comment.ID)
+		linkModerationTarget(c, model.ContentTypePostComment, comment.ID)
+		refreshPostHotScore(req.PostID)
+		tags := indexContentTags(model.ContentTypePostComment, comment.ID, comment.Content)
+		mentions := notifyMentions(model.ContentTypePostComment, comment.ID, userID, comment.Content,
+			gin.H{"post_id": req.PostID, "comment_id": comment.ID}, nil)
+		notifyNewComment(comment.Content, model.ContentTypePostComment, comment.ID, userID, post.UserID, parentAuthorID,
+			gin.H{"post_id": req.PostID, "comment_id": comment.ID})
+
 		// 重新查询评论以获取完整的用户信息
 		savedComment, err := repository.GetCommentByID(comment.ID)
 		if err != nil {
@@ -141,18 +305,22 @@ func CommentOnPost() gin.HandlerFunc {
 		})
 
 		c.JSON(200, gin.H{
-			"success":    true,
-			"id":         savedComment.ID,
-			"userId":     savedComment.UserID,
-			"userName":   savedComment.UserName,
-			"userAvatar": savedComment.UserAvatar,
-			"content":    savedComment.Content,
-			"createdAt":  savedComment.CreatedAt.Format("15:04"),
+			"success":     true,
+			"id":          savedComment.ID,
+			"userId":      savedComment.UserID,
+			"userName":    savedComment.UserName,
+			"userAvatar":  savedComment.UserAvatar,
+			"parentId":    savedComment.ParentID,
+			"content":     savedComment.Content,
+			"attachments": savedComment.Attachments,
+			"tags":        tags,
+			"mentions":    mentions,
+			"createdAt":   savedComment.CreatedAt.Format("15:04"),
 		})
 	}
 }
 
-// 删除帖子评论
+// 删除帖子评论（评论者、帖子作者或管理员）
 func DeleteUserPostComment() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, ok := getCurrentUserID(c)
@@ -175,17 +343,21 @@ func DeleteUserPostComment() gin.HandlerFunc {
 			c.JSON(404, gin.H{"error": "评论不存在"})
 			return
 		}
-		if comment.UserID != userID {
+		ownerID, _ := repository.GetPostAuthorID(comment.PostID)
+		if !canDeleteComment(userID, comment.UserID, ownerID) {
 			c.JSON(403, gin.H{"error": "无权删除此评论"})
 			return
 		}
 
-		err = repository.DeletePostCommentFromDB(req.CommentID)
+		deletedIDs, err := repository.DeletePostCommentFromDB(req.CommentID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to delete comment"})
 			utils.LogError("数据库删除评论失败", nil)
 			return
 		}
+		// 回复随一级评论一起删除，它们的附件也一并释放
+		releaseAttachments(model.AttachmentOwnerPostComment, deletedIDs...)
+		refreshPostHotScore(comment.PostID)
 		utils.LogInfo("用户删除评论成功", logrus.Fields{"comment_id": req.CommentID, "user_id": userID})
 		c.JSON(200, gin.H{"success": true, "message": "Comment deleted successfully"})
 	}
@@ -194,7 +366,8 @@ func DeleteUserPostComment() gin.HandlerFunc {
 // 获取所有帖子
 func GetAllPosts() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		posts, err := repository.GetAllPosts()
+		page, limit := parsePagination(c)
+		posts, total, err := repository.GetPostsPage((page-1)*limit, limit)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to retrieve posts"})
 			utils.LogError("数据库获取帖子失败", nil)
@@ -204,7 +377,9 @@ func GetAllPosts() gin.HandlerFunc {
 		c.JSON(200, gin.H{
 			"success": true,
 			"posts":   posts,
-			"total":   len(posts),
+			"total":   total,
+			"page":    page,
+			"limit":   limit,
 		})
 	}
 }
@@ -232,41 +407,141 @@ func LikeFlag() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req struct {
 			FlagID uint `json:"flag_id"`
-			Like   int  `json:"like"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(400, gin.H{"error": "Invalid input"})
 			return
 		}
-		err := repository.UpdateFlagLikes(req.FlagID, req.Like)
+		userID, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		flag, err := repository.GetFlagByID(req.FlagID)
+		if err != nil || flag.Hidden || (!flag.IsPublic && flag.UserID != userID) {
+			c.JSON(404, gin.H{"error": "flag不存在"})
+			return
+		}
+
+		// 切换点赞状态（如果已点赞则取消，未点赞则点赞）
+		likes, liked, err := repository.ToggleFlagLike(req.FlagID, userID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "点赞flag失败,请重新再试..."})
-			utils.LogError("数据库点赞flag失败", nil)
+			utils.LogError("数据库点赞flag失败", logrus.Fields{
+				"flag_id": req.FlagID,
+				"error":   err.Error(),
+			})
 			return
 		}
+		refreshFlagHotScore(req.FlagID)
+		if liked {
+			notifyOnce(model.Notification{
+				UserID:     flag.UserID,
+				Type:       model.NotificationLike,
+				ActorID:    userID,
+				TargetType: model.ContentTypeFlag,
+				TargetID:   flag.ID,
+				Content:    flag.Title,
+				Data:       gin.H{"flag_id": flag.ID},
+			})
+		}
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"likes":   likes,
+			"liked":   liked,
+		})
 	}
 }
 
 // 发表flag评论
 func CommentOnFlag() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var comment model.FlagComment
-		if err := c.ShouldBindJSON(&comment); err != nil {
+		userID, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		var req struct {
+			FlagID        uint   `json:"flag_id"`
+			ParentID      *uint  `json:"parent_id"` // 回复某条评论时传入
+			Content       string `json:"content"`
+			AttachmentIDs []uint `json:"attachment_ids"` // 已上传的图片附件ID（可选）
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(400, gin.H{"error": "Invalid input"})
 			return
 		}
-		err := repository.UpdateFlagComment(comment.FlagID, comment.Content)
-		if err != nil {
+		flag, err := repository.GetFlagByID(req.FlagID)
+		if err != nil || flag.Hidden || (!flag.IsPublic && flag.UserID != userID) {
+			c.JSON(404, gin.H{"error": "flag不存在"})
+			return
+		}
+		// 内容审核
+		content, ok := moderateOrReject(c, sceneFlagComment, userID, req.Content)
+		if !ok {
+			return
+		}
+		attachmentIDs, ok := validateAttachmentIDs(c, userID, req.AttachmentIDs)
+		if !ok {
+			return
+		}
+
+		comment := model.FlagComment{
+			FlagID:  req.FlagID,
+			UserID:  userID,
+			Content: content,
+		}
+		var parentAuthorID uint
+		if req.ParentID != nil {
+			parent, err := repository.GetFlagCommentByID(*req.ParentID)
+			if err != nil || parent.FlagID != req.FlagID || parent.Hidden {
+				c.JSON(400, gin.H{"error": "回复的评论不存在"})
+				return
+			}
+			comment.ParentID = threadRootID(parent.ID, parent.ParentID)
+			parentAuthorID = parent.UserID
+		}
+		if err := repository.AddFlagComment(&comment); err != nil {
 			c.JSON(500, gin.H{"error": "Failed to add comment"})
-			utils.LogError("数据库添加flag评论失败", nil)
+			utils.LogError("数据库添加flag评论失败", logrus.Fields{"flag_id": req.FlagID, "error": err.Error()})
 			return
 		}
+		bindAttachments(userID, attachmentIDs, model.AttachmentOwnerFlagComment, comment.ID)
+		linkModerationTarget(c, model.ContentTypeFlagComment, comment.ID)
+		refreshFlagHotScore(comment.FlagID)
+		tags := indexContentTags(model.ContentTypeFlagComment, comment.ID, comment.Content)
+		// 未公开的flag只有主人能看到，提及其他人不提醒
+		var canSee func(uint) bool
+		if !flag.IsPublic {
+			canSee = func(uid uint) bool { return uid == flag.UserID }
+		}
+		mentions := notifyMentions(model.ContentTypeFlagComment, comment.ID, userID, comment.Content,
+			gin.H{"flag_id": comment.FlagID, "comment_id": comment.ID}, canSee)
+		if canSee != nil && !canSee(parentAuthorID) {
+			parentAuthorID = 0
+		}
+		notifyNewComment(comment.Content, model.ContentTypeFlagComment, comment.ID, userID, flag.UserID, parentAuthorID,
+			gin.H{"flag_id": comment.FlagID, "comment_id": comment.ID})
+
+		// 重新查询评论以获取完整的用户信息
+		savedComment, err := repository.GetFlagCommentByID(comment.ID)
+		if err != nil {
+			utils.LogError("查询flag评论失败", logrus.Fields{"comment_id": comment.ID})
+			savedComment = comment
+		}
+		c.JSON(200, gin.H{"success": true, "comment": savedComment, "tags": tags, "mentions": mentions})
 	}
 }
 
-// 删除flag评论
+// 删除flag评论（评论者、flag主人或管理员）
 func DeleteFlagComment() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		userID, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
 		var req struct {
 			FlagCommentID uint `json:"flagcomment_id"`
 		}
@@ -274,12 +549,29 @@ func DeleteFlagComment() gin.HandlerFunc {
 			c.JSON(400, gin.H{"error": "Invalid input"})
 			return
 		}
-		err := repository.DeleteFlagComment(req.FlagCommentID)
+		comment, err := repository.GetFlagCommentByID(req.FlagCommentID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "评论不存在"})
+			return
+		}
+		var ownerID uint
+		if flag, err := repository.GetFlagByID(comment.FlagID); err == nil {
+			ownerID = flag.UserID
+		}
+		if !canDeleteComment(userID, comment.UserID, ownerID) {
+			c.JSON(403, gin.H{"error": "无权删除此评论"})
+			return
+		}
+		deletedIDs, err := repository.DeleteFlagComment(req.FlagCommentID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Failed to delete comment"})
 			utils.LogError("数据库删除flag评论失败", nil)
 			return
 		}
+		releaseAttachments(model.AttachmentOwnerFlagComment, deletedIDs...)
+		refreshFlagHotScore(comment.FlagID)
+		utils.LogInfo("用户删除flag评论成功", logrus.Fields{"comment_id": req.FlagCommentID, "user_id": userID})
+		c.JSON(200, gin.H{"success": true, "message": "Comment deleted successfully"})
 	}
 }
 
@@ -301,13 +593,18 @@ func LikePost() gin.HandlerFunc {
 			return
 		}
 
+		post, ok := loadVisiblePost(c, userID, req.PostID)
+		if !ok {
+			return
+		}
+
 		utils.LogInfo("开始处理帖子点赞", logrus.Fields{
 			"post_id": req.PostID,
 			"user_id": userID,
 		})
 
 		// 切换点赞状态（如果已点赞则取消，未点赞则点赞）
-		newLikeCount, err := repository.TogglePostLike(req.PostID, userID)
+		newLikeCount, liked, err := repository.TogglePostLike(req.PostID, userID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "点赞帖子失败,请重新再试..."})
 			utils.LogError("数据库点赞帖子失败", logrus.Fields{
@@ -317,6 +614,19 @@ func LikePost() gin.HandlerFunc {
 			return
 		}
 
+		refreshPostHotScore(req.PostID)
+		if liked {
+			notifyOnce(model.Notification{
+				UserID:     post.UserID,
+				Type:       model.NotificationLike,
+				ActorID:    userID,
+				TargetType: model.ContentTypePost,
+				TargetID:   post.ID,
+				Content:    post.Title,
+				Data:       gin.H{"post_id": post.ID},
+			})
+		}
+
 		utils.LogInfo("帖子点赞成功", logrus.Fields{
 			"post_id":   req.PostID,
 			"new_likes": newLikeCount,
@@ -325,6 +635,7 @@ func LikePost() gin.HandlerFunc {
 		c.JSON(200, gin.H{
 			"success": true,
 			"likes":   newLikeCount,
+			"liked":   liked,
 		})
 	}
 }
@@ -370,7 +681,7 @@ func GetPostLikes() gin.HandlerFunc {
 	}
 }
 
-// 获取当前用户点过赞的帖子ID
+// 获取当前用户点过赞的帖子和flag ID
 func GetUserLikedPosts() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, ok := getCurrentUserID(c)
@@ -384,6 +695,12 @@ func GetUserLikedPosts() gin.HandlerFunc {
 			utils.LogError("获取已点赞帖子失败", nil)
 			return
 		}
-		c.JSON(200, gin.H{"liked_post_ids": ids})
+		flagIDs, err := repository.GetLikedFlagIDsByUser(userID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取已点赞帖子失败"})
+			utils.LogError("获取已点赞flag失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"liked_post_ids": ids, "liked_flag_ids": flagIDs})
 	}
 }
@@ -1,19 +1,31 @@
 package service
 
 import (
+	"fmt"
 	"log"
-
-	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
-
 	"strconv"
+	"time"
 
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 )
 
+// 增加用户积分并发布积分事件（所有加积分的地方都应走这里）
+func AwardPoints(userID uint, amount int) error {
+	if err := repository.CountAddDB(userID, amount); err != nil {
+		return err
+	}
+	PublishEvent(EventPointsAwarded, userID, map[string]interface{}{"amount": amount})
+	return nil
+}
+
 // 积分函数（已修正为使用原子自增）
 func AddUserCount(count string, id uint) {
 	var countInt, _ = strconv.Atoi(count)
-	err := repository.CountAddDB(id, countInt)
+	err := AwardPoints(id, countInt)
 	if err != nil {
 		log.Printf("[error] 积分更新失败: %v", err)
 		return
@@ -21,53 +33,235 @@ func AddUserCount(count string, id uint) {
 	log.Printf("[info] 积分增加成功 - 用户ID: %d, 增加积分: %d", id, countInt)
 }
 
-// 积分封神榜
-func GetUserCount() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		users, err := repository.GetUserByCount()
+// 封神榜指标
+const (
+	rankingMetricPoints    = "points"     // 积分
+	rankingMetricLearnTime = "learn_time" // 学习时长（分钟）
+	rankingMetricDaka      = "daka"       // 打卡天数
+	rankingMetricFlags     = "flags"      // 完成flag数
+)
+
+// 封神榜时间范围
+const (
+	rankingWindowToday = "today"
+	rankingWindowWeek  = "week"
+	rankingWindowMonth = "month"
+	rankingWindowAll   = "all"
+)
+
+// 封神榜条目（只包含公开信息）
+type RankingEntry struct {
+	Rank   int    `json:"rank"`
+	UserID uint   `json:"-"`
+	Name   string `json:"name"`
+	Avatar string `json:"avatar"`
+	Score  int    `json:"score"`
+}
+
+// 时间范围的起点，all 返回零值
+func rankingWindowStart(window string) (time.Time, bool) {
+	today := startOfToday()
+	switch window {
+	case rankingWindowToday:
+		return today, true
+	case rankingWindowWeek:
+		return weekStartOf(today), true
+	case rankingWindowMonth:
+		return time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location()), true
+	case rankingWindowAll:
+		return time.Time{}, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// 加载指标在时间范围内的分数
+func loadRankingScores(metric, window string) ([]repository.RankingScore, error) {
+	since, _ := rankingWindowStart(window)
+	all := window == rankingWindowAll
+	switch metric {
+	case rankingMetricPoints:
+		if all {
+			return repository.GetPointsScores()
+		}
+		return repository.GetPointsScoresSince(since)
+	case rankingMetricLearnTime:
+		return repository.GetLearnTimeScoresSince(since)
+	case rankingMetricDaka:
+		if all {
+			return repository.GetDakaScores()
+		}
+		return repository.GetDakaScoresSince(since)
+	case rankingMetricFlags:
+		if all {
+			return repository.GetFlagScores()
+		}
+		return repository.GetFlagScoresSince(since)
+	default:
+		return nil, fmt.Errorf("未知的排行指标: %s", metric)
+	}
+}
+
+func isValidRankingMetric(metric string) bool {
+	switch metric {
+	case rankingMetricPoints, rankingMetricLearnTime, rankingMetricDaka, rankingMetricFlags:
+		return true
+	}
+	return false
+}
+
+// 补全用户名和头像
+func buildRankingEntries(scores []repository.RankingScore, ranks []int) ([]RankingEntry, error) {
+	ids := make([]uint, 0, len(scores))
+	for _, s := range scores {
+		ids = append(ids, s.UserID)
+	}
+	users, err := repository.GetUsersPublicInfo(ids)
+	if err != nil {
+		return nil, err
+	}
+	userMap := make(map[uint]model.User, len(users))
+	for _, u := range users {
+		userMap[u.ID] = u
+	}
+	entries := make([]RankingEntry, 0, len(scores))
+	for i, s := range scores {
+		u, ok := userMap[s.UserID]
+		if !ok {
+			continue
+		}
+		entries = append(entries, RankingEntry{
+			Rank:   ranks[i],
+			UserID: s.UserID,
+			Name:   u.Name,
+			Avatar: u.AvatarURL(),
+			Score:  s.Score,
+		})
+	}
+	return entries, nil
+}
+
+// 查询排行榜：返回指定页的条目和总人数（同分同名次）
+func queryRanking(metric, window string, offset, limit int) ([]RankingEntry, int, error) {
+	board := currentLeaderboard(metric, window)
+	total, err := leaderboards.Count(board)
+	if err != nil {
+		return nil, 0, err
+	}
+	scores, err := leaderboards.Range(board, offset, limit)
+	if err != nil || len(scores) == 0 {
+		return []RankingEntry{}, total, err
+	}
+	ranks := make([]int, len(scores))
+	for i, s := range scores {
+		switch {
+		case i == 0:
+			above, err := leaderboards.CountAbove(board, s.Score)
+			if err != nil {
+				return nil, 0, err
+			}
+			ranks[i] = above + 1
+		case s.Score == scores[i-1].Score:
+			ranks[i] = ranks[i-1]
+		default:
+			ranks[i] = offset + i + 1
+		}
+	}
+	entries, err := buildRankingEntries(scores, ranks)
+	return entries, total, err
+}
+
+// 查询自己的名次，不在榜上时 rank 为 0
+func queryMyRanking(metric, window string, userID uint) (*RankingEntry, error) {
+	board := currentLeaderboard(metric, window)
+	score, ok, err := leaderboards.Score(board, userID)
+	if err != nil {
+		return nil, err
+	}
+	rank := 0
+	if ok {
+		above, err := leaderboards.CountAbove(board, score)
 		if err != nil {
-			c.JSON(500, gin.H{"error": "获取封神榜失败,请重新再试..."})
-			return
+			return nil, err
 		}
-		//埋点
-		repository.AddTrackPointToDB(0, "查看积分封神榜")
-		c.JSON(200, gin.H{"message": "获取封神榜成功", "data": users})
+		rank = above + 1
 	}
+	entries, err := buildRankingEntries([]repository.RankingScore{{UserID: userID, Score: score}}, []int{rank})
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
 }
 
-// 月学习时间封神榜
-func GetUserMonthLearnTime() gin.HandlerFunc {
+// 统一封神榜：metric=points|learn_time|daka|flags，window=today|week|month|all，支持分页，并返回自己的名次
+func GetRanking() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		users, err := repository.GetUserByMonthLearnTime()
+		id, _ := getCurrentUserID(c)
+		metric := c.DefaultQuery("metric", rankingMetricPoints)
+		window := c.DefaultQuery("window", rankingWindowAll)
+		if !isValidRankingMetric(metric) {
+			c.JSON(400, gin.H{"error": "metric 只能是 points/learn_time/daka/flags"})
+			return
+		}
+		if _, ok := rankingWindowStart(window); !ok {
+			c.JSON(400, gin.H{"error": "window 只能是 today/week/month/all"})
+			return
+		}
+		page, limit := parsePagination(c)
+
+		entries, total, err := queryRanking(metric, window, (page-1)*limit, limit)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "获取封神榜失败,请重新再试..."})
+			utils.LogError("获取封神榜失败", logrus.Fields{"metric": metric, "window": window, "error": err.Error()})
 			return
 		}
-		repository.AddTrackPointToDB(0, "查看月学习时间封神榜")
-		c.JSON(200, gin.H{"message": "获取封神榜成功", "data": users})
+		me, err := queryMyRanking(metric, window, id)
+		if err != nil {
+			utils.LogError("获取自己的名次失败", logrus.Fields{"user_id": id, "error": err.Error()})
+		}
+
+		c.JSON(200, gin.H{
+			"success": true,
+			"metric":  metric,
+			"window":  window,
+			"page":    page,
+			"limit":   limit,
+			"total":   total,
+			"data":    entries,
+			"me":      me,
+		})
 	}
 }
 
-// 总打卡数封神榜
-func GetUserTotalDaka() gin.HandlerFunc {
+// 旧版封神榜：返回前20名（只包含公开信息）
+func legacyRanking(metric, window string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		users, err := repository.GetUserByDaka()
+		entries, _, err := queryRanking(metric, window, 0, 20)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "获取封神榜失败,请重新再试..."})
+			utils.LogError("获取封神榜失败", logrus.Fields{"metric": metric, "error": err.Error()})
+			return
 		}
-		repository.AddTrackPointToDB(0, "查看总打卡数封神榜")
-		c.JSON(200, gin.H{"message": "获取封神榜成功", "data": users})
+		c.JSON(200, gin.H{"message": "获取封神榜成功", "data": entries})
 	}
 }
 
+// 积分封神榜
+func GetUserCount() gin.HandlerFunc {
+	return legacyRanking(rankingMetricPoints, rankingWindowAll)
+}
+
+// 月学习时间封神榜
+func GetUserMonthLearnTime() gin.HandlerFunc {
+	return legacyRanking(rankingMetricLearnTime, rankingWindowMonth)
+}
+
+// 总打卡数封神榜
+func GetUserTotalDaka() gin.HandlerFunc {
+	return legacyRanking(rankingMetricDaka, rankingWindowAll)
+}
+
 // 按flag数量排序
 func GetUserByFlagNumber() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		users, err := repository.GetUserByFlagNumber()
-		if err != nil {
-			c.JSON(500, gin.H{"error": "获取封神榜失败,请重新再试..."})
-		}
-		repository.AddTrackPointToDB(0, "查看flag数量封神榜")
-		c.JSON(200, gin.H{"message": "获取封神榜成功", "data": users})
-	}
+	return legacyRanking(rankingMetricFlags, rankingWindowAll)
 }
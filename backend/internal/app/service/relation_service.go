@@ -0,0 +1,512 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const sceneFriendRequest = "friend_request"
+
+// 用户公开信息
+type UserBrief struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Avatar string `json:"avatar"`
+}
+
+// 批量获取用户公开信息
+func loadUserBriefs(ids []uint) (map[uint]UserBrief, error) {
+	users, err := repository.GetUsersPublicInfo(ids)
+	if err != nil {
+		return nil, err
+	}
+	briefs := make(map[uint]UserBrief, len(users))
+	for _, u := range users {
+		briefs[u.ID] = UserBrief{ID: u.ID, Name: u.Name, Avatar: u.AvatarURL()}
+	}
+	return briefs, nil
+}
+
+// 解析路径中的目标用户ID，不能是自己且用户必须存在
+func parseTargetUser(c *gin.Context, selfID uint) (uint, bool) {
+	targetID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || targetID == 0 {
+		c.JSON(400, gin.H{"error": "无效的用户ID"})
+		return 0, false
+	}
+	if uint(targetID) == selfID {
+		c.JSON(400, gin.H{"error": "不能对自己进行此操作"})
+		return 0, false
+	}
+	if _, err := repository.GetUserByID(uint(targetID)); err != nil {
+		c.JSON(404, gin.H{"error": "用户不存在"})
+		return 0, false
+	}
+	return uint(targetID), true
+}
+
+// 关注用户
+func FollowUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		targetID, ok := parseTargetUser(c, id)
+		if !ok {
+			return
+		}
+		blocked, err := repository.IsBlockedBetween(id, targetID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "关注失败,请重新再试..."})
+			utils.LogError("查询拉黑关系失败", logrus.Fields{"user_id": id, "target_id": targetID, "error": err.Error()})
+			return
+		}
+		if blocked {
+			c.JSON(403, gin.H{"error": "无法关注该用户"})
+			return
+		}
+		if err := repository.AddRelation(id, targetID, model.RelationFollow); err != nil {
+			c.JSON(500, gin.H{"error": "关注失败,请重新再试..."})
+			utils.LogError("关注用户失败", logrus.Fields{"user_id": id, "target_id": targetID, "error": err.Error()})
+			return
+		}
+		mutual, _ := repository.HasRelation(targetID, id, model.RelationFollow)
+		utils.LogInfo("关注用户成功", logrus.Fields{"user_id": id, "target_id": targetID})
+		c.JSON(200, gin.H{"success": true, "following": true, "mutual": mutual})
+	}
+}
+
+// 取消关注
+func UnfollowUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		targetID, ok := parseTargetUser(c, id)
+		if !ok {
+			return
+		}
+		if err := repository.DeleteRelation(id, targetID, model.RelationFollow); err != nil {
+			c.JSON(500, gin.H{"error": "取消关注失败,请重新再试..."})
+			utils.LogError("取消关注失败", logrus.Fields{"user_id": id, "target_id": targetID, "error": err.Error()})
+			return
+		}
+		utils.LogInfo("取消关注成功", logrus.Fields{"user_id": id, "target_id": targetID})
+		c.JSON(200, gin.H{"success": true, "following": false})
+	}
+}
+
+// 拉黑用户：解除双方关注，对方无法再关注、申请好友或私信
+func BlockUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		targetID, ok := parseTargetUser(c, id)
+		if !ok {
+			return
+		}
+		if err := repository.BlockUser(id, targetID); err != nil {
+			c.JSON(500, gin.H{"error": "拉黑失败,请重新再试..."})
+			utils.LogError("拉黑用户失败", logrus.Fields{"user_id": id, "target_id": targetID, "error": err.Error()})
+			return
+		}
+		utils.LogInfo("拉黑用户成功", logrus.Fields{"user_id": id, "target_id": targetID})
+		c.JSON(200, gin.H{"success": true, "blocked": true})
+	}
+}
+
+// 取消拉黑
+func UnblockUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		targetID, ok := parseTargetUser(c, id)
+		if !ok {
+			return
+		}
+		if err := repository.DeleteRelation(id, targetID, model.RelationBlock); err != nil {
+			c.JSON(500, gin.H{"error": "取消拉黑失败,请重新再试..."})
+			utils.LogError("取消拉黑失败", logrus.Fields{"user_id": id, "target_id": targetID, "error": err.Error()})
+			return
+		}
+		utils.LogInfo("取消拉黑成功", logrus.Fields{"user_id": id, "target_id": targetID})
+		c.JSON(200, gin.H{"success": true, "blocked": false})
+	}
+}
+
+// 关系列表中的一项
+type relationItem struct {
+	UserBrief
+	Since       time.Time `json:"since"`
+	IsFollowing bool      `json:"is_following"` // 我是否关注了对方
+}
+
+// 把关系记录转换成列表，pick 取出对方的ID
+func buildRelationItems(selfID uint, relations []model.UserRelation, pick func(model.UserRelation) uint) ([]relationItem, error) {
+	ids := make([]uint, 0, len(relations))
+	for _, r := range relations {
+		ids = append(ids, pick(r))
+	}
+	briefs, err := loadUserBriefs(ids)
+	if err != nil {
+		return nil, err
+	}
+	following, err := repository.FilterFollowing(selfID, ids)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]relationItem, 0, len(relations))
+	for _, r := range relations {
+		brief, ok := briefs[pick(r)]
+		if !ok {
+			continue
+		}
+		items = append(items, relationItem{UserBrief: brief, Since: r.CreatedAt, IsFollowing: following[brief.ID]})
+	}
+	return items, nil
+}
+
+// 分页列出关系：粉丝/关注/黑名单
+func listRelations(load func(userID uint, offset, limit int) ([]model.UserRelation, int64, error), pick func(model.UserRelation) uint, ownOnly bool, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		userID := id
+		if !ownOnly {
+			targetID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+			if err != nil || targetID == 0 {
+				c.JSON(400, gin.H{"error": "无效的用户ID"})
+				return
+			}
+			userID = uint(targetID)
+		}
+		page, limit := parsePagination(c)
+		relations, total, err := load(userID, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取" + name + "失败,请重新再试..."})
+			utils.LogError("获取"+name+"失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+			return
+		}
+		items, err := buildRelationItems(id, relations, pick)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取" + name + "失败,请重新再试..."})
+			utils.LogError("获取"+name+"用户信息失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "data": items, "total": total, "page": page, "limit": limit})
+	}
+}
+
+func relationSource(r model.UserRelation) uint { return r.UserID }
+func relationTarget(r model.UserRelation) uint { return r.TargetID }
+
+// 粉丝列表
+func GetFollowers() gin.HandlerFunc {
+	return listRelations(repository.GetFollowers, relationSource, false, "粉丝列表")
+}
+
+// 关注列表
+func GetFollowing() gin.HandlerFunc {
+	return listRelations(repository.GetFollowing, relationTarget, false, "关注列表")
+}
+
+// 我的黑名单
+func GetBlockedUsers() gin.HandlerFunc {
+	return listRelations(repository.GetBlocked, relationTarget, true, "黑名单")
+}
+
+// 好友列表（互相关注）
+func GetFriends() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		ids, err := repository.GetFriendIDs(id)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取好友列表失败,请重新再试..."})
+			utils.LogError("获取好友列表失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		briefs, err := loadUserBriefs(ids)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取好友列表失败,请重新再试..."})
+			utils.LogError("获取好友信息失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		friends := make([]UserBrief, 0, len(briefs))
+		for _, fid := range ids {
+			if brief, ok := briefs[fid]; ok {
+				friends = append(friends, brief)
+			}
+		}
+		c.JSON(200, gin.H{"success": true, "data": friends, "total": len(friends)})
+	}
+}
+
+// 发送好友申请；对方已向我发出申请时直接成为好友
+func SendFriendRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		var req struct {
+			UserID  uint   `json:"user_id" binding:"required"`
+			Message string `json:"message"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		if req.UserID == id {
+			c.JSON(400, gin.H{"error": "不能添加自己为好友"})
+			return
+		}
+		if len([]rune(req.Message)) > 100 {
+			c.JSON(400, gin.H{"error": "附言不能超过100字"})
+			return
+		}
+		if _, err := repository.GetUserByID(req.UserID); err != nil {
+			c.JSON(404, gin.H{"error": "用户不存在"})
+			return
+		}
+		if blocked, err := repository.IsBlockedBetween(id, req.UserID); err != nil || blocked {
+			c.JSON(403, gin.H{"error": "无法添加该用户为好友"})
+			return
+		}
+		following, _ := repository.HasRelation(id, req.UserID, model.RelationFollow)
+		followed, _ := repository.HasRelation(req.UserID, id, model.RelationFollow)
+		if following && followed {
+			c.JSON(400, gin.H{"error": "你们已经是好友了"})
+			return
+		}
+		message, ok := moderateOrReject(c, sceneFriendRequest, id, req.Message)
+		if !ok {
+			return
+		}
+
+		// 对方已经申请过我，直接同意
+		if incoming, err := repository.GetPendingFriendRequest(req.UserID, id); err == nil {
+			if err := repository.AcceptFriendRequest(&incoming); err != nil {
+				c.JSON(500, gin.H{"error": "添加好友失败,请重新再试..."})
+				utils.LogError("同意好友申请失败", logrus.Fields{"request_id": incoming.ID, "error": err.Error()})
+				return
+			}
+			notifyFriendRequest(incoming.FromID, "friend_accepted", incoming.ID, id)
+			c.JSON(200, gin.H{"success": true, "status": model.FriendRequestAccepted, "request_id": incoming.ID})
+			return
+		}
+
+		if _, err := repository.GetPendingFriendRequest(id, req.UserID); err == nil {
+			c.JSON(400, gin.H{"error": "已发送过好友申请，请等待对方处理"})
+			return
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(500, gin.H{"error": "发送好友申请失败,请重新再试..."})
+			utils.LogError("查询好友申请失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		request := model.FriendRequest{FromID: id, ToID: req.UserID, Message: message, Status: model.FriendRequestPending}
+		if err := repository.SaveFriendRequest(&request); err != nil {
+			c.JSON(500, gin.H{"error": "发送好友申请失败,请重新再试..."})
+			utils.LogError("保存好友申请失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		notifyFriendRequest(req.UserID, "friend_request", request.ID, id)
+		utils.LogInfo("发送好友申请成功", logrus.Fields{"user_id": id, "to_id": req.UserID})
+		c.JSON(200, gin.H{"success": true, "status": request.Status, "request_id": request.ID})
+	}
+}
+
+// 通过 WebSocket 提醒对方（不在线时忽略）
+func notifyFriendRequest(toID uint, kind string, requestID uint, fromID uint) {
+	briefs, _ := loadUserBriefs([]uint{fromID})
+	manager.SendToUser(toID, gin.H{"type": kind, "data": gin.H{"request_id": requestID, "user": briefs[fromID]}})
+}
+
+// 好友申请列表：box=received|sent，status=pending|accepted|rejected（默认全部）
+func GetFriendRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		box := c.DefaultQuery("box", "received")
+		if box != "received" && box != "sent" {
+			c.JSON(400, gin.H{"error": "box 只能是 received/sent"})
+			return
+		}
+		status := c.Query("status")
+		page, limit := parsePagination(c)
+		requests, total, err := repository.GetFriendRequests(id, box, status, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取好友申请失败,请重新再试..."})
+			utils.LogError("获取好友申请失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		ids := make([]uint, 0, len(requests))
+		for _, r := range requests {
+			if box == "sent" {
+				ids = append(ids, r.ToID)
+			} else {
+				ids = append(ids, r.FromID)
+			}
+		}
+		briefs, err := loadUserBriefs(ids)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取好友申请失败,请重新再试..."})
+			utils.LogError("获取好友申请用户信息失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		items := make([]gin.H, 0, len(requests))
+		for i, r := range requests {
+			items = append(items, gin.H{
+				"id":         r.ID,
+				"user":       briefs[ids[i]],
+				"message":    r.Message,
+				"status":     r.Status,
+				"created_at": r.CreatedAt,
+			})
+		}
+		c.JSON(200, gin.H{"success": true, "data": items, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// 获取发给我的待处理申请
+func loadIncomingFriendRequest(c *gin.Context, id uint) (model.FriendRequest, bool) {
+	requestID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "无效的申请ID"})
+		return model.FriendRequest{}, false
+	}
+	request, err := repository.GetFriendRequestByID(uint(requestID))
+	if err != nil || request.ToID != id {
+		c.JSON(404, gin.H{"error": "好友申请不存在"})
+		return model.FriendRequest{}, false
+	}
+	if request.Status != model.FriendRequestPending {
+		c.JSON(400, gin.H{"error": "该申请已处理"})
+		return model.FriendRequest{}, false
+	}
+	return request, true
+}
+
+// 同意好友申请
+func AcceptFriendRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		request, ok := loadIncomingFriendRequest(c, id)
+		if !ok {
+			return
+		}
+		if blocked, err := repository.IsBlockedBetween(id, request.FromID); err != nil || blocked {
+			c.JSON(403, gin.H{"error": "无法添加该用户为好友"})
+			return
+		}
+		if err := repository.AcceptFriendRequest(&request); err != nil {
+			c.JSON(500, gin.H{"error": "同意好友申请失败,请重新再试..."})
+			utils.LogError("同意好友申请失败", logrus.Fields{"request_id": request.ID, "error": err.Error()})
+			return
+		}
+		notifyFriendRequest(request.FromID, "friend_accepted", request.ID, id)
+		utils.LogInfo("同意好友申请成功", logrus.Fields{"request_id": request.ID, "user_id": id})
+		c.JSON(200, gin.H{"success": true, "status": model.FriendRequestAccepted})
+	}
+}
+
+// 拒绝好友申请
+func RejectFriendRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		request, ok := loadIncomingFriendRequest(c, id)
+		if !ok {
+			return
+		}
+		request.Status = model.FriendRequestRejected
+		if err := repository.SaveFriendRequest(&request); err != nil {
+			c.JSON(500, gin.H{"error": "拒绝好友申请失败,请重新再试..."})
+			utils.LogError("拒绝好友申请失败", logrus.Fields{"request_id": request.ID, "error": err.Error()})
+			return
+		}
+		utils.LogInfo("拒绝好友申请成功", logrus.Fields{"request_id": request.ID, "user_id": id})
+		c.JSON(200, gin.H{"success": true, "status": model.FriendRequestRejected})
+	}
+}
+
+// 好友封神榜：只包含我关注的人和我自己，参数同 /api/ranking
+func GetFriendsRanking() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		metric := c.DefaultQuery("metric", rankingMetricPoints)
+		window := c.DefaultQuery("window", rankingWindowAll)
+		if !isValidRankingMetric(metric) {
+			c.JSON(400, gin.H{"error": "metric 只能是 points/learn_time/daka/flags"})
+			return
+		}
+		if _, ok := rankingWindowStart(window); !ok {
+			c.JSON(400, gin.H{"error": "window 只能是 today/week/month/all"})
+			return
+		}
+		page, limit := parsePagination(c)
+
+		ids, err := repository.GetFollowingIDs(id)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取好友封神榜失败,请重新再试..."})
+			utils.LogError("获取关注列表失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		entries, err := rankUsers(metric, window, append(ids, id))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取好友封神榜失败,请重新再试..."})
+			utils.LogError("获取好友封神榜失败", logrus.Fields{"user_id": id, "metric": metric, "error": err.Error()})
+			return
+		}
+
+		var me *RankingEntry
+		for i := range entries {
+			if entries[i].UserID == id {
+				me = &entries[i]
+				break
+			}
+		}
+		total := len(entries)
+		start := min((page-1)*limit, total)
+		end := min(start+limit, total)
+		c.JSON(200, gin.H{
+			"success": true,
+			"metric":  metric,
+			"window":  window,
+			"page":    page,
+			"limit":   limit,
+			"total":   total,
+			"data":    entries[start:end],
+			"me":      me,
+		})
+	}
+}
+
+// 对指定的一组用户排名（分数取自封神榜，同分同名次，0分排在最后）
+func rankUsers(metric, window string, userIDs []uint) ([]RankingEntry, error) {
+	board := currentLeaderboard(metric, window)
+	scores := make([]repository.RankingScore, 0, len(userIDs))
+	seen := make(map[uint]bool, len(userIDs))
+	for _, uid := range userIDs {
+		if seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		score, _, err := leaderboards.Score(board, uid)
+		if err != nil {
+			return nil, err
+		}
+		scores = append(scores, repository.RankingScore{UserID: uid, Score: score})
+	}
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Score != scores[j].Score {
+			return scores[i].Score > scores[j].Score
+		}
+		return scores[i].UserID < scores[j].UserID
+	})
+	ranks := make([]int, len(scores))
+	for i := range scores {
+		if i > 0 && scores[i].Score == scores[i-1].Score {
+			ranks[i] = ranks[i-1]
+		} else {
+			ranks[i] = i + 1
+		}
+	}
+	return buildRankingEntries(scores, ranks)
+}
@@ -0,0 +1,479 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSuspendDays = 7
+	maxSuspendDays     = 365
+	maxReportDetail    = 500 // 举报说明最多字数
+)
+
+// 举报原因
+var reportReasons = map[string]bool{
+	"spam":    true, // 广告、刷屏
+	"abuse":   true, // 辱骂、骚扰
+	"porn":    true, // 色情低俗
+	"illegal": true, // 违法信息
+	"other":   true,
+}
+
+var errReportTargetNotFound = errors.New("举报的内容不存在")
+
+// 被举报的内容
+type reportTarget struct {
+	AuthorID uint
+	Snapshot string
+}
+
+// 加载被举报的内容；viewerID 不为 0 时要求该用户能看到这条内容
+func loadReportTarget(targetType string, targetID, viewerID uint) (reportTarget, error) {
+	switch targetType {
+	case model.ContentTypePost:
+		post, err := repository.GetPostByID(targetID)
+		if err != nil || (post.Hidden && viewerID != 0) {
+			return reportTarget{}, errReportTargetNotFound
+		}
+		return reportTarget{AuthorID: post.UserID, Snapshot: post.Title + "\n" + post.Content}, nil
+	case model.ContentTypePostComment:
+		comment, err := repository.GetCommentByID(targetID)
+		if err != nil || (comment.Hidden && viewerID != 0) {
+			return reportTarget{}, errReportTargetNotFound
+		}
+		return reportTarget{AuthorID: comment.UserID, Snapshot: comment.Content}, nil
+	case model.ContentTypeFlag:
+		flag, err := repository.GetFlagByID(targetID)
+		if err != nil || (viewerID != 0 && (flag.Hidden || (!flag.IsPublic && flag.UserID != viewerID))) {
+			return reportTarget{}, errReportTargetNotFound
+		}
+		return reportTarget{AuthorID: flag.UserID, Snapshot: flag.Title + "\n" + flag.Detail}, nil
+	case model.ContentTypeFlagComment:
+		comment, err := repository.GetFlagCommentByID(targetID)
+		if err != nil || (comment.Hidden && viewerID != 0) {
+			return reportTarget{}, errReportTargetNotFound
+		}
+		if viewerID != 0 {
+			flag, err := repository.GetFlagByID(comment.FlagID)
+			if err != nil || flag.Hidden || (!flag.IsPublic && flag.UserID != viewerID) {
+				return reportTarget{}, errReportTargetNotFound
+			}
+		}
+		return reportTarget{AuthorID: comment.UserID, Snapshot: comment.Content}, nil
+	case model.ContentTypeChatMessage:
+		message, err := repository.GetChatMessageByID(targetID)
+		if err != nil || (message.Hidden && viewerID != 0) {
+			return reportTarget{}, errReportTargetNotFound
+		}
+		if viewerID != 0 && !canSeeChatMessage(message, viewerID) {
+			return reportTarget{}, errReportTargetNotFound
+		}
+		return reportTarget{AuthorID: message.FromUserID, Snapshot: message.Content}, nil
+	}
+	return reportTarget{}, errReportTargetNotFound
+}
+
+// 私信只有双方能看到，小组聊天室只有组员能看到
+func canSeeChatMessage(message model.ChatMessage, userID uint) bool {
+	if message.ToUserID != 0 {
+		return message.FromUserID == userID || message.ToUserID == userID
+	}
+	if isGroupRoom(message.RoomID) {
+		return canJoinGroupRoom(message.RoomID, userID)
+	}
+	return true
+}
+
+// 删除被举报的内容（与作者自己删除时的处理一致）
+func deleteReportedContent(targetType string, targetID uint) error {
+	var err error
+	switch targetType {
+	case model.ContentTypePost:
+		err = repository.DeletePostFromDB(targetID)
+		if err == nil {
+			releaseAttachments(model.AttachmentOwnerPost, targetID)
+		}
+	case model.ContentTypePostComment:
+		var deletedIDs []uint
+		deletedIDs, err = repository.DeletePostCommentFromDB(targetID)
+		if err == nil {
+			releaseAttachments(model.AttachmentOwnerPostComment, deletedIDs...)
+		}
+	case model.ContentTypeFlag:
+		err = repository.DeleteFlagFromDB(targetID)
+	case model.ContentTypeFlagComment:
+		var deletedIDs []uint
+		deletedIDs, err = repository.DeleteFlagComment(targetID)
+		if err == nil {
+			releaseAttachments(model.AttachmentOwnerFlagComment, deletedIDs...)
+		}
+	case model.ContentTypeChatMessage:
+		err = repository.DeleteChatMessage(targetID)
+		if err == nil {
+			releaseAttachments(model.AttachmentOwnerChatMessage, targetID)
+		}
+	default:
+		err = errReportTargetNotFound
+	}
+	return err
+}
+
+// 是否处于封禁期
+func isSuspended(until *time.Time) bool {
+	return until != nil && until.After(time.Now())
+}
+
+// 封禁期间拒绝请求并返回 403；返回 true 表示已拒绝
+func rejectIfSuspended(c *gin.Context, until *time.Time) bool {
+	if !isSuspended(until) {
+		return false
+	}
+	c.JSON(403, gin.H{
+		"error":           "账号已被封禁至 " + until.Format("2006-01-02 15:04"),
+		"suspended_until": until,
+		"success":         false,
+	})
+	return true
+}
+
+// 按用户ID检查封禁状态（发布内容前调用）
+func rejectIfUserSuspended(c *gin.Context, userID uint) bool {
+	until, err := repository.GetUserSuspendedUntil(userID)
+	if err != nil {
+		utils.LogError("获取用户封禁状态失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+		return false
+	}
+	return rejectIfSuspended(c, until)
+}
+
+// 举报内容：target_type 为 post/post_comment/flag/flag_comment/chat_message
+func CreateReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		var req struct {
+			TargetType string `json:"target_type"`
+			TargetID   uint   `json:"target_id"`
+			Reason     string `json:"reason"`
+			Detail     string `json:"detail"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid input"})
+			return
+		}
+		if !reportReasons[req.Reason] {
+			c.JSON(400, gin.H{"error": "reason 只能是 spam/abuse/porn/illegal/other"})
+			return
+		}
+		detail := strings.TrimSpace(req.Detail)
+		if len([]rune(detail)) > maxReportDetail {
+			c.JSON(400, gin.H{"error": "举报说明不能超过" + strconv.Itoa(maxReportDetail) + "字"})
+			return
+		}
+		target, err := loadReportTarget(req.TargetType, req.TargetID, userID)
+		if err != nil {
+			c.JSON(404, gin.H{"error": "举报的内容不存在"})
+			return
+		}
+		if target.AuthorID == userID {
+			c.JSON(400, gin.H{"error": "不能举报自己的内容"})
+			return
+		}
+		reported, err := repository.HasOpenReport(userID, req.TargetType, req.TargetID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "举报失败,请重新再试..."})
+			utils.LogError("查询举报记录失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+			return
+		}
+		if reported {
+			c.JSON(409, gin.H{"error": "你已经举报过这条内容，请等待处理"})
+			return
+		}
+		report := model.Report{
+			ReporterID:   userID,
+			TargetType:   req.TargetType,
+			TargetID:     req.TargetID,
+			TargetUserID: target.AuthorID,
+			Reason:       req.Reason,
+			Detail:       detail,
+			Snapshot:     target.Snapshot,
+			Status:       model.ReportStatusOpen,
+		}
+		if err := repository.SaveReport(&report); err != nil {
+			c.JSON(500, gin.H{"error": "举报失败,请重新再试..."})
+			utils.LogError("保存举报失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+			return
+		}
+		utils.LogInfo("用户举报内容", logrus.Fields{"user_id": userID, "target_type": req.TargetType, "target_id": req.TargetID, "reason": req.Reason})
+		c.JSON(200, gin.H{"success": true, "report_id": report.ID, "message": "举报已提交，我们会尽快处理"})
+	}
+}
+
+// 审核队列中的举报（附带举报人、被举报人和同一内容的待处理举报数）
+type ReportItem struct {
+	model.Report
+	Reporter    UserBrief `json:"reporter"`
+	TargetUser  UserBrief `json:"target_user"`
+	OpenReports int64     `json:"open_reports"`
+}
+
+// 审核队列（管理员）：status=open/actioned/dismissed/all（默认 open），可按 target_type 筛选
+func GetReports() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status := c.DefaultQuery("status", model.ReportStatusOpen)
+		switch status {
+		case model.ReportStatusOpen, model.ReportStatusActioned, model.ReportStatusDismissed:
+		case "all":
+			status = ""
+		default:
+			c.JSON(400, gin.H{"error": "status 只能是 open/actioned/dismissed/all"})
+			return
+		}
+		page, limit := parsePagination(c)
+		reports, total, err := repository.GetReports(status, c.Query("target_type"), (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取举报列表失败,请重新再试..."})
+			utils.LogError("获取举报列表失败", logrus.Fields{"error": err.Error()})
+			return
+		}
+
+		var userIDs []uint
+		targets := make(map[string][]uint)
+		for _, r := range reports {
+			userIDs = append(userIDs, r.ReporterID, r.TargetUserID)
+			targets[r.TargetType] = append(targets[r.TargetType], r.TargetID)
+		}
+		briefs, err := loadUserBriefs(userIDs)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取举报列表失败,请重新再试..."})
+			utils.LogError("获取举报相关用户失败", logrus.Fields{"error": err.Error()})
+			return
+		}
+		openCounts := make(map[string]map[uint]int64, len(targets))
+		for targetType, ids := range targets {
+			counts, err := repository.CountOpenReportsByTarget(targetType, ids)
+			if err != nil {
+				c.JSON(500, gin.H{"error": "获取举报列表失败,请重新再试..."})
+				utils.LogError("统计举报次数失败", logrus.Fields{"error": err.Error()})
+				return
+			}
+			openCounts[targetType] = counts
+		}
+
+		items := make([]ReportItem, 0, len(reports))
+		for _, r := range reports {
+			items = append(items, ReportItem{
+				Report:      r,
+				Reporter:    briefs[r.ReporterID],
+				TargetUser:  briefs[r.TargetUserID],
+				OpenReports: openCounts[r.TargetType][r.TargetID],
+			})
+		}
+		c.JSON(200, gin.H{"success": true, "data": items, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// 处理举报（管理员）：action=hide/delete/warn/suspend/dismiss
+// 同一内容的其他待处理举报一并结案；warn/suspend 可同时 hide_content，suspend 用 days 指定天数（默认 7）
+func ResolveReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID, _ := getCurrentUserID(c)
+		reportID, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+		var req struct {
+			Action      string `json:"action"`
+			Days        int    `json:"days"`
+			HideContent bool   `json:"hide_content"`
+			Note        string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid input"})
+			return
+		}
+		report, err := repository.GetReportByID(reportID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(404, gin.H{"error": "举报不存在"})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "处理举报失败,请重新再试..."})
+			utils.LogError("获取举报失败", logrus.Fields{"report_id": reportID, "error": err.Error()})
+			return
+		}
+		if report.Status != model.ReportStatusOpen {
+			c.JSON(409, gin.H{"error": "该举报已处理"})
+			return
+		}
+
+		status := model.ReportStatusActioned
+		result := gin.H{}
+		switch req.Action {
+		case model.ReportActionDismiss:
+			status = model.ReportStatusDismissed
+		case model.ReportActionHide:
+			err = repository.SetContentHidden(report.TargetType, report.TargetID, true)
+		case model.ReportActionDelete:
+			err = deleteReportedContent(report.TargetType, report.TargetID)
+		case model.ReportActionWarn:
+			err = repository.AddUserWarning(report.TargetUserID)
+		case model.ReportActionSuspend:
+			if req.Days == 0 {
+				req.Days = defaultSuspendDays
+			}
+			if req.Days < 1 || req.Days > maxSuspendDays {
+				c.JSON(400, gin.H{"error": "days 必须在1-" + strconv.Itoa(maxSuspendDays) + "之间"})
+				return
+			}
+			until := time.Now().AddDate(0, 0, req.Days)
+			err = repository.SetUserSuspendedUntil(report.TargetUserID, &until)
+			result["suspended_until"] = until
+		default:
+			c.JSON(400, gin.H{"error": "action 只能是 hide/delete/warn/suspend/dismiss"})
+			return
+		}
+		if err == nil && req.HideContent && (req.Action == model.ReportActionWarn || req.Action == model.ReportActionSuspend) {
+			err = repository.SetContentHidden(report.TargetType, report.TargetID, true)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(404, gin.H{"error": errReportTargetNotFound.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "处理举报失败,请重新再试..."})
+			utils.LogError("执行举报处理失败", logrus.Fields{"report_id": reportID, "action": req.Action, "error": err.Error()})
+			return
+		}
+
+		note := strings.TrimSpace(req.Note)
+		reporterIDs, err := repository.ResolveReports(report.TargetType, report.TargetID, status, req.Action, note, adminID, time.Now())
+		if err != nil {
+			c.JSON(500, gin.H{"error": "处理举报失败,请重新再试..."})
+			utils.LogError("更新举报状态失败", logrus.Fields{"report_id": reportID, "error": err.Error()})
+			return
+		}
+		notifyReportResolved(report, req.Action, note, reporterIDs, result["suspended_until"])
+		utils.LogInfo("管理员处理举报", logrus.Fields{"admin_id": adminID, "report_id": reportID, "action": req.Action, "resolved": len(reporterIDs)})
+		result["success"] = true
+		result["status"] = status
+		result["resolved"] = len(reporterIDs)
+		c.JSON(200, result)
+	}
+}
+
+// 通知举报人处理结果，警告或封禁时通知作者
+func notifyReportResolved(report model.Report, action, note string, reporterIDs []uint, suspendedUntil interface{}) {
+	data := gin.H{"report_id": report.ID, "target_type": report.TargetType, "target_id": report.TargetID, "action": action}
+	content := "你举报的内容已处理，感谢你的反馈"
+	if action == model.ReportActionDismiss {
+		content = "你举报的内容经核实未发现违规"
+	}
+	for _, id := range reporterIDs {
+		notify(model.Notification{
+			UserID:     id,
+			Type:       model.NotificationModeration,
+			TargetType: "report",
+			TargetID:   report.ID,
+			Content:    content,
+			Data:       data,
+		})
+	}
+
+	var authorContent string
+	switch action {
+	case model.ReportActionWarn:
+		authorContent = "你发布的内容被举报并经核实违规，请遵守社区规范"
+	case model.ReportActionSuspend:
+		authorContent = "你发布的内容违反社区规范，账号已被封禁"
+	case model.ReportActionHide, model.ReportActionDelete:
+		authorContent = "你发布的内容违反社区规范，已被管理员处理"
+	default:
+		return
+	}
+	if note != "" {
+		authorContent += "：" + note
+	}
+	notify(model.Notification{
+		UserID:     report.TargetUserID,
+		Type:       model.NotificationModeration,
+		TargetType: report.TargetType,
+		TargetID:   report.TargetID,
+		Content:    authorContent,
+		Data:       gin.H{"action": action, "suspended_until": suspendedUntil},
+	})
+}
+
+// 恢复被隐藏的内容（管理员），用于撤销误判的 hide 处理
+func UnhideContent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID, _ := getCurrentUserID(c)
+		var req struct {
+			TargetType string `json:"target_type"`
+			TargetID   uint   `json:"target_id"`
+			Note       string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || req.TargetID == 0 {
+			c.JSON(400, gin.H{"error": "Invalid input"})
+			return
+		}
+		target, err := loadReportTarget(req.TargetType, req.TargetID, 0)
+		if err == nil {
+			err = repository.SetContentHidden(req.TargetType, req.TargetID, false)
+		}
+		if errors.Is(err, errReportTargetNotFound) || errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(404, gin.H{"error": errReportTargetNotFound.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "恢复内容失败,请重新再试..."})
+			utils.LogError("恢复被隐藏内容失败", logrus.Fields{"target_type": req.TargetType, "target_id": req.TargetID, "error": err.Error()})
+			return
+		}
+		content := "你发布的内容经复核已恢复显示"
+		if note := strings.TrimSpace(req.Note); note != "" {
+			content += "：" + note
+		}
+		notify(model.Notification{
+			UserID:     target.AuthorID,
+			Type:       model.NotificationModeration,
+			TargetType: req.TargetType,
+			TargetID:   req.TargetID,
+			Content:    content,
+			Data:       gin.H{"action": "unhide"},
+		})
+		utils.LogInfo("管理员恢复被隐藏内容", logrus.Fields{"admin_id": adminID, "target_type": req.TargetType, "target_id": req.TargetID})
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// 解除封禁（管理员）
+func UnsuspendUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminID, _ := getCurrentUserID(c)
+		userID, ok := parseIDParam(c, "id")
+		if !ok {
+			return
+		}
+		if err := repository.SetUserSuspendedUntil(userID, nil); err != nil {
+			c.JSON(500, gin.H{"error": "解除封禁失败,请重新再试..."})
+			utils.LogError("解除封禁失败", logrus.Fields{"user_id": userID, "error": err.Error()})
+			return
+		}
+		utils.LogInfo("管理员解除封禁", logrus.Fields{"admin_id": adminID, "user_id": userID})
+		c.JSON(200, gin.H{"success": true})
+	}
+}
@@ -0,0 +1,935 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	groupRoomPrefix       = "group-"
+	defaultGroupMaxMember = 50
+	maxGroupMaxMember     = 200
+)
+
+// 小组动态类型
+const (
+	groupActivityJoined        = "joined"
+	groupActivityLeft          = "left"
+	groupActivityDaka          = "daka"
+	groupActivityLearnTime     = "learn_time"
+	groupActivityFlagCompleted = "flag_completed"
+	groupActivityAchievement   = "achievement"
+	groupActivityGoalCreated   = "goal_created"
+	groupActivityGoalCompleted = "goal_completed"
+)
+
+// 事件对应的共同目标指标
+var groupGoalMetricByEvent = map[string]string{
+	EventDaka:              model.GoalMetricDakaDays,
+	EventLearnTimeRecorded: model.GoalMetricLearnMinutes,
+	EventFlagCompleted:     model.GoalMetricFlagCompletions,
+	EventPointsAwarded:     model.GoalMetricPoints,
+}
+
+func init() {
+	for eventType := range groupGoalMetricByEvent {
+		SubscribeEvent(eventType, onGroupMemberEvent)
+	}
+	SubscribeEvent(EventAchievementUnlocked, onGroupMemberEvent)
+}
+
+// 角色权重，用于权限比较
+func groupRoleLevel(role string) int {
+	switch role {
+	case model.GroupRoleOwner:
+		return 3
+	case model.GroupRoleAdmin:
+		return 2
+	case model.GroupRoleMember:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ---------- 小组聊天室 ----------
+
+func groupRoomID(groupID uint) string {
+	return groupRoomPrefix + strconv.FormatUint(uint64(groupID), 10)
+}
+
+func isGroupRoom(roomID string) bool {
+	return strings.HasPrefix(roomID, groupRoomPrefix)
+}
+
+// 只有小组正式成员可以进入小组聊天室
+func canJoinGroupRoom(roomID string, userID uint) bool {
+	groupID, err := strconv.ParseUint(strings.TrimPrefix(roomID, groupRoomPrefix), 10, 64)
+	if err != nil {
+		return false
+	}
+	member, err := repository.GetGroupMember(uint(groupID), userID)
+	return err == nil && member.Status == model.GroupMemberActive
+}
+
+// 为小组创建聊天室（已存在时只更新名称和人数上限）
+func ensureGroupRoom(group model.StudyGroup) {
+	roomID := groupRoomID(group.ID)
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	if room, ok := manager.Rooms[roomID]; ok {
+		room.Name = group.Name
+		room.MaxUsers = group.MaxMembers
+		return
+	}
+	manager.Rooms[roomID] = &ChatRoom{
+		ID:         roomID,
+		Name:       group.Name,
+		CreatorID:  group.OwnerID,
+		Clients:    make(map[uint]*Client),
+		CreatedAt:  group.CreatedAt,
+		LastActive: time.Now(),
+		MaxUsers:   group.MaxMembers,
+	}
+}
+
+// 删除小组聊天室并断开房间内的连接
+func removeGroupRoom(groupID uint) {
+	roomID := groupRoomID(groupID)
+	// 持锁复制连接列表，避免与 Unregister 并发读写 Clients
+	manager.mu.Lock()
+	var clients []*Client
+	if room, ok := manager.Rooms[roomID]; ok {
+		for _, client := range room.Clients {
+			clients = append(clients, client)
+		}
+		delete(manager.Rooms, roomID)
+	}
+	manager.mu.Unlock()
+	for _, client := range clients {
+		client.Conn.Close()
+	}
+}
+
+// 启动时为所有小组创建聊天室
+func LoadGroupRooms() {
+	groups, err := repository.GetAllStudyGroups()
+	if err != nil {
+		utils.LogError("加载小组聊天室失败", logrus.Fields{"error": err.Error()})
+		return
+	}
+	for _, group := range groups {
+		ensureGroupRoom(group)
+	}
+	utils.LogInfo("小组聊天室加载完成", logrus.Fields{"count": len(groups)})
+}
+
+// ---------- 小组动态 ----------
+
+// 记录小组动态并推送到小组聊天室
+func recordGroupActivity(groupID, userID uint, activityType, content string) {
+	activity := model.GroupActivity{GroupID: groupID, UserID: userID, Type: activityType, Content: content}
+	if err := repository.AddGroupActivity(&activity); err != nil {
+		utils.LogError("记录小组动态失败", logrus.Fields{"group_id": groupID, "user_id": userID, "error": err.Error()})
+		return
+	}
+	manager.SendToRoom(groupRoomID(groupID), gin.H{"type": "group_activity", "data": activity})
+}
+
+// 成员的学习行为写入所在小组的动态，并检查共同目标是否完成
+func onGroupMemberEvent(event DomainEvent) {
+	memberships, err := repository.GetUserGroupMemberships(event.UserID)
+	if err != nil || len(memberships) == 0 {
+		return
+	}
+	activityType, content := groupActivityFor(event)
+	metric, hasGoal := groupGoalMetricByEvent[event.Type]
+	for _, m := range memberships {
+		if activityType != "" {
+			recordGroupActivity(m.GroupID, event.UserID, activityType, content)
+		}
+		if hasGoal {
+			checkGroupFlags(m.GroupID, metric)
+		}
+	}
+}
+
+// 事件对应的动态，返回空类型表示不记录
+func groupActivityFor(event DomainEvent) (string, string) {
+	switch event.Type {
+	case EventDaka:
+		if done, ok := event.Payload["done"].(bool); ok && !done {
+			return "", ""
+		}
+		return groupActivityDaka, "完成了今日打卡"
+	case EventLearnTimeRecorded:
+		return groupActivityLearnTime, fmt.Sprintf("学习了 %d 分钟", payloadInt(event.Payload, "duration"))
+	case EventFlagCompleted:
+		if label := model.FlagLabelNames[payloadInt(event.Payload, "label")]; label != "" {
+			return groupActivityFlagCompleted, "完成了一个「" + label + "」flag"
+		}
+		return groupActivityFlagCompleted, "完成了一个flag"
+	case EventAchievementUnlocked:
+		name, _ := event.Payload["name"].(string)
+		return groupActivityAchievement, "解锁了成就「" + name + "」"
+	default:
+		return "", ""
+	}
+}
+
+// ---------- 共同目标 ----------
+
+// 共同目标当前进度：全体正式成员在目标时间范围内的指标之和
+func groupFlagProgress(flag model.GroupFlag, memberIDs []uint) (int64, error) {
+	return repository.SumGoalMetricForUsers(flag.Metric, memberIDs, flag.StartTime, flag.EndTime)
+}
+
+// 检查小组中进行中的共同目标，达成时记录动态
+func checkGroupFlags(groupID uint, metric string) {
+	now := time.Now()
+	flags, err := repository.GetOpenGroupFlags(groupID, metric, now)
+	if err != nil || len(flags) == 0 {
+		return
+	}
+	memberIDs, err := repository.GetActiveMemberIDs(groupID)
+	if err != nil {
+		return
+	}
+	for _, flag := range flags {
+		progress, err := groupFlagProgress(flag, memberIDs)
+		if err != nil {
+			utils.LogError("计算小组目标进度失败", logrus.Fields{"group_flag_id": flag.ID, "error": err.Error()})
+			continue
+		}
+		if progress < int64(flag.Target) {
+			continue
+		}
+		if marked, err := repository.MarkGroupFlagCompleted(flag.ID, now); err == nil && marked {
+			recordGroupActivity(groupID, 0, groupActivityGoalCompleted, "小组共同完成了目标「"+flag.Title+"」")
+			utils.LogInfo("小组目标达成", logrus.Fields{"group_id": groupID, "group_flag_id": flag.ID})
+		}
+	}
+}
+
+// ---------- 权限 ----------
+
+// 加载路径中的小组，并校验当前用户是正式成员且角色不低于 minRole
+func loadGroupForMember(c *gin.Context, userID uint, minRole string) (model.StudyGroup, model.GroupMember, bool) {
+	groupID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "无效的小组ID"})
+		return model.StudyGroup{}, model.GroupMember{}, false
+	}
+	group, err := repository.GetStudyGroupByID(uint(groupID))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "小组不存在"})
+		return model.StudyGroup{}, model.GroupMember{}, false
+	}
+	member, err := repository.GetGroupMember(group.ID, userID)
+	if err != nil || member.Status != model.GroupMemberActive {
+		c.JSON(403, gin.H{"error": "你不是该小组的成员"})
+		return model.StudyGroup{}, model.GroupMember{}, false
+	}
+	if groupRoleLevel(member.Role) < groupRoleLevel(minRole) {
+		c.JSON(403, gin.H{"error": "没有权限进行此操作"})
+		return model.StudyGroup{}, model.GroupMember{}, false
+	}
+	return group, member, true
+}
+
+// 解析路径中的成员ID并加载成员记录
+func loadTargetMember(c *gin.Context, groupID uint) (model.GroupMember, bool) {
+	userID, err := strconv.ParseUint(c.Param("uid"), 10, 64)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "无效的用户ID"})
+		return model.GroupMember{}, false
+	}
+	member, err := repository.GetGroupMember(groupID, uint(userID))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "该用户不在小组中"})
+		return model.GroupMember{}, false
+	}
+	return member, true
+}
+
+// 小组信息，组长和管理员可以看到邀请码
+func groupView(group model.StudyGroup, member model.GroupMember, memberCount int64) gin.H {
+	view := gin.H{
+		"id":            group.ID,
+		"name":          group.Name,
+		"description":   group.Description,
+		"owner_id":      group.OwnerID,
+		"need_approval": group.NeedApproval,
+		"max_members":   group.MaxMembers,
+		"member_count":  memberCount,
+		"room_id":       groupRoomID(group.ID),
+		"my_role":       member.Role,
+		"created_at":    group.CreatedAt,
+	}
+	if groupRoleLevel(member.Role) >= groupRoleLevel(model.GroupRoleAdmin) {
+		view["invite_code"] = group.InviteCode
+	}
+	return view
+}
+
+// 生成不重复的邀请码
+func newGroupInviteCode() string {
+	for i := 0; i < 5; i++ {
+		code := utils.GenerateInviteCode()
+		if _, err := repository.GetStudyGroupByInviteCode(code); errors.Is(err, gorm.ErrRecordNotFound) {
+			return code
+		}
+	}
+	return utils.GenerateInviteCode()
+}
+
+// ---------- 小组 ----------
+
+type groupRequest struct {
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	NeedApproval *bool  `json:"need_approval"`
+	MaxMembers   int    `json:"max_members"`
+}
+
+// 校验并审核小组名称和简介
+func (req *groupRequest) validate(c *gin.Context, userID uint) bool {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len([]rune(req.Name)) > 50 {
+		c.JSON(400, gin.H{"error": "小组名称不能为空且不超过50字"})
+		return false
+	}
+	if len([]rune(req.Description)) > 500 {
+		c.JSON(400, gin.H{"error": "小组简介不能超过500字"})
+		return false
+	}
+	if req.MaxMembers < 0 || req.MaxMembers > maxGroupMaxMember {
+		c.JSON(400, gin.H{"error": fmt.Sprintf("人数上限应在 1-%d 之间", maxGroupMaxMember)})
+		return false
+	}
+	var ok bool
+	if req.Name, ok = moderateOrReject(c, sceneGroup, userID, req.Name); !ok {
+		return false
+	}
+	req.Description, ok = moderateOrReject(c, sceneGroup, userID, req.Description)
+	return ok
+}
+
+// 创建小组
+func CreateStudyGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		var req groupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		if !req.validate(c, id) {
+			return
+		}
+		group := model.StudyGroup{
+			Name:         req.Name,
+			Description:  req.Description,
+			OwnerID:      id,
+			InviteCode:   newGroupInviteCode(),
+			NeedApproval: true,
+			MaxMembers:   defaultGroupMaxMember,
+		}
+		if req.NeedApproval != nil {
+			group.NeedApproval = *req.NeedApproval
+		}
+		if req.MaxMembers > 0 {
+			group.MaxMembers = req.MaxMembers
+		}
+		if err := repository.CreateStudyGroup(&group); err != nil {
+			c.JSON(500, gin.H{"error": "创建小组失败,请重新再试..."})
+			utils.LogError("创建小组失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		ensureGroupRoom(group)
+		recordGroupActivity(group.ID, id, groupActivityJoined, "创建了小组")
+		utils.LogInfo("创建小组成功", logrus.Fields{"user_id": id, "group_id": group.ID})
+		c.JSON(200, gin.H{"success": true, "data": groupView(group, model.GroupMember{Role: model.GroupRoleOwner}, 1)})
+	}
+}
+
+// 我加入的小组
+func GetMyStudyGroups() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		memberships, err := repository.GetUserGroupMemberships(id)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取小组列表失败,请重新再试..."})
+			utils.LogError("获取小组列表失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		ids := make([]uint, 0, len(memberships))
+		for _, m := range memberships {
+			ids = append(ids, m.GroupID)
+		}
+		groups, err := repository.GetStudyGroupsByIDs(ids)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取小组列表失败,请重新再试..."})
+			utils.LogError("获取小组信息失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		counts, _ := repository.CountActiveMembersByGroups(ids)
+		groupMap := make(map[uint]model.StudyGroup, len(groups))
+		for _, g := range groups {
+			groupMap[g.ID] = g
+		}
+		views := make([]gin.H, 0, len(memberships))
+		for _, m := range memberships {
+			if g, ok := groupMap[m.GroupID]; ok {
+				views = append(views, groupView(g, m, counts[g.ID]))
+			}
+		}
+		c.JSON(200, gin.H{"success": true, "data": views})
+	}
+}
+
+// 小组详情
+func GetStudyGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, member, ok := loadGroupForMember(c, id, model.GroupRoleMember)
+		if !ok {
+			return
+		}
+		count, _ := repository.CountActiveGroupMembers(group.ID)
+		c.JSON(200, gin.H{"success": true, "data": groupView(group, member, count)})
+	}
+}
+
+// 修改小组信息（组长、管理员）
+func UpdateStudyGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, member, ok := loadGroupForMember(c, id, model.GroupRoleAdmin)
+		if !ok {
+			return
+		}
+		req := groupRequest{Name: group.Name, Description: group.Description}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		if !req.validate(c, id) {
+			return
+		}
+		count, _ := repository.CountActiveGroupMembers(group.ID)
+		if req.MaxMembers > 0 && int64(req.MaxMembers) < count {
+			c.JSON(400, gin.H{"error": "人数上限不能小于当前成员数"})
+			return
+		}
+		group.Name = req.Name
+		group.Description = req.Description
+		if req.NeedApproval != nil {
+			group.NeedApproval = *req.NeedApproval
+		}
+		if req.MaxMembers > 0 {
+			group.MaxMembers = req.MaxMembers
+		}
+		if err := repository.SaveStudyGroup(&group); err != nil {
+			c.JSON(500, gin.H{"error": "修改小组失败,请重新再试..."})
+			utils.LogError("修改小组失败", logrus.Fields{"group_id": group.ID, "error": err.Error()})
+			return
+		}
+		ensureGroupRoom(group)
+		c.JSON(200, gin.H{"success": true, "data": groupView(group, member, count)})
+	}
+}
+
+// 解散小组（仅组长）
+func DeleteStudyGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, _, ok := loadGroupForMember(c, id, model.GroupRoleOwner)
+		if !ok {
+			return
+		}
+		if err := repository.DeleteStudyGroup(group.ID); err != nil {
+			c.JSON(500, gin.H{"error": "解散小组失败,请重新再试..."})
+			utils.LogError("解散小组失败", logrus.Fields{"group_id": group.ID, "error": err.Error()})
+			return
+		}
+		removeGroupRoom(group.ID)
+		utils.LogInfo("解散小组成功", logrus.Fields{"group_id": group.ID, "user_id": id})
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// 重置邀请码（组长、管理员）
+func ResetGroupInviteCode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, _, ok := loadGroupForMember(c, id, model.GroupRoleAdmin)
+		if !ok {
+			return
+		}
+		group.InviteCode = newGroupInviteCode()
+		if err := repository.SaveStudyGroup(&group); err != nil {
+			c.JSON(500, gin.H{"error": "重置邀请码失败,请重新再试..."})
+			utils.LogError("重置邀请码失败", logrus.Fields{"group_id": group.ID, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "invite_code": group.InviteCode})
+	}
+}
+
+// 通过邀请码加入小组；需要审批时进入待审批状态
+func JoinStudyGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		var req struct {
+			InviteCode string `json:"invite_code" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "邀请码不能为空"})
+			return
+		}
+		group, err := repository.GetStudyGroupByInviteCode(strings.ToUpper(strings.TrimSpace(req.InviteCode)))
+		if err != nil {
+			c.JSON(404, gin.H{"error": "邀请码无效"})
+			return
+		}
+		if existing, err := repository.GetGroupMember(group.ID, id); err == nil {
+			if existing.Status == model.GroupMemberPending {
+				c.JSON(400, gin.H{"error": "已提交申请，请等待管理员审批"})
+			} else {
+				c.JSON(400, gin.H{"error": "你已经是该小组成员"})
+			}
+			return
+		}
+		count, err := repository.CountActiveGroupMembers(group.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "加入小组失败,请重新再试..."})
+			return
+		}
+		if count >= int64(group.MaxMembers) {
+			c.JSON(403, gin.H{"error": "小组人数已满"})
+			return
+		}
+
+		member := model.GroupMember{GroupID: group.ID, UserID: id, Role: model.GroupRoleMember, Status: model.GroupMemberPending}
+		if !group.NeedApproval {
+			now := time.Now()
+			member.Status = model.GroupMemberActive
+			member.JoinedAt = &now
+		}
+		if err := repository.SaveGroupMember(&member); err != nil {
+			c.JSON(500, gin.H{"error": "加入小组失败,请重新再试..."})
+			utils.LogError("加入小组失败", logrus.Fields{"group_id": group.ID, "user_id": id, "error": err.Error()})
+			return
+		}
+		if member.Status == model.GroupMemberActive {
+			recordGroupActivity(group.ID, id, groupActivityJoined, "加入了小组")
+		}
+		utils.LogInfo("申请加入小组", logrus.Fields{"group_id": group.ID, "user_id": id, "status": member.Status})
+		c.JSON(200, gin.H{"success": true, "group_id": group.ID, "status": member.Status})
+	}
+}
+
+// 退出小组（组长需先解散小组）
+func LeaveStudyGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, member, ok := loadGroupForMember(c, id, model.GroupRoleMember)
+		if !ok {
+			return
+		}
+		if member.Role == model.GroupRoleOwner {
+			c.JSON(400, gin.H{"error": "组长不能退出小组，请先解散小组"})
+			return
+		}
+		if err := repository.DeleteGroupMember(group.ID, id); err != nil {
+			c.JSON(500, gin.H{"error": "退出小组失败,请重新再试..."})
+			utils.LogError("退出小组失败", logrus.Fields{"group_id": group.ID, "user_id": id, "error": err.Error()})
+			return
+		}
+		manager.EvictFromRoom(groupRoomID(group.ID), id)
+		recordGroupActivity(group.ID, id, groupActivityLeft, "退出了小组")
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// ---------- 成员管理 ----------
+
+// 成员列表：status=active|pending（待审批列表仅组长、管理员可见）
+func GetGroupMembers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		status := c.DefaultQuery("status", model.GroupMemberActive)
+		if status != model.GroupMemberActive && status != model.GroupMemberPending {
+			c.JSON(400, gin.H{"error": "status 只能是 active/pending"})
+			return
+		}
+		minRole := model.GroupRoleMember
+		if status == model.GroupMemberPending {
+			minRole = model.GroupRoleAdmin
+		}
+		group, _, ok := loadGroupForMember(c, id, minRole)
+		if !ok {
+			return
+		}
+		page, limit := parsePagination(c)
+		members, total, err := repository.GetGroupMembers(group.ID, status, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取成员列表失败,请重新再试..."})
+			utils.LogError("获取小组成员失败", logrus.Fields{"group_id": group.ID, "error": err.Error()})
+			return
+		}
+		ids := make([]uint, 0, len(members))
+		for _, m := range members {
+			ids = append(ids, m.UserID)
+		}
+		briefs, err := loadUserBriefs(ids)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取成员列表失败,请重新再试..."})
+			return
+		}
+		items := make([]gin.H, 0, len(members))
+		for _, m := range members {
+			items = append(items, gin.H{
+				"user":       briefs[m.UserID],
+				"role":       m.Role,
+				"status":     m.Status,
+				"joined_at":  m.JoinedAt,
+				"applied_at": m.CreatedAt,
+			})
+		}
+		c.JSON(200, gin.H{"success": true, "data": items, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// 通过入组申请（组长、管理员）
+func ApproveGroupMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, _, ok := loadGroupForMember(c, id, model.GroupRoleAdmin)
+		if !ok {
+			return
+		}
+		target, ok := loadTargetMember(c, group.ID)
+		if !ok {
+			return
+		}
+		if target.Status != model.GroupMemberPending {
+			c.JSON(400, gin.H{"error": "该用户已是小组成员"})
+			return
+		}
+		if count, err := repository.CountActiveGroupMembers(group.ID); err != nil || count >= int64(group.MaxMembers) {
+			c.JSON(403, gin.H{"error": "小组人数已满"})
+			return
+		}
+		now := time.Now()
+		target.Status = model.GroupMemberActive
+		target.JoinedAt = &now
+		if err := repository.SaveGroupMember(&target); err != nil {
+			c.JSON(500, gin.H{"error": "审批失败,请重新再试..."})
+			utils.LogError("通过入组申请失败", logrus.Fields{"group_id": group.ID, "user_id": target.UserID, "error": err.Error()})
+			return
+		}
+		recordGroupActivity(group.ID, target.UserID, groupActivityJoined, "加入了小组")
+		manager.SendToUser(target.UserID, gin.H{"type": "group_approved", "data": gin.H{"group_id": group.ID, "name": group.Name}})
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// 拒绝入组申请（组长、管理员）
+func RejectGroupMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, _, ok := loadGroupForMember(c, id, model.GroupRoleAdmin)
+		if !ok {
+			return
+		}
+		target, ok := loadTargetMember(c, group.ID)
+		if !ok {
+			return
+		}
+		if target.Status != model.GroupMemberPending {
+			c.JSON(400, gin.H{"error": "该申请已处理"})
+			return
+		}
+		if err := repository.DeleteGroupMember(group.ID, target.UserID); err != nil {
+			c.JSON(500, gin.H{"error": "审批失败,请重新再试..."})
+			utils.LogError("拒绝入组申请失败", logrus.Fields{"group_id": group.ID, "user_id": target.UserID, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// 设置成员角色 admin/member（仅组长）
+func SetGroupMemberRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, _, ok := loadGroupForMember(c, id, model.GroupRoleOwner)
+		if !ok {
+			return
+		}
+		var req struct {
+			Role string `json:"role" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil || (req.Role != model.GroupRoleAdmin && req.Role != model.GroupRoleMember) {
+			c.JSON(400, gin.H{"error": "role 只能是 admin/member"})
+			return
+		}
+		target, ok := loadTargetMember(c, group.ID)
+		if !ok {
+			return
+		}
+		if target.Status != model.GroupMemberActive || target.Role == model.GroupRoleOwner {
+			c.JSON(400, gin.H{"error": "不能修改该成员的角色"})
+			return
+		}
+		target.Role = req.Role
+		if err := repository.SaveGroupMember(&target); err != nil {
+			c.JSON(500, gin.H{"error": "设置角色失败,请重新再试..."})
+			utils.LogError("设置小组成员角色失败", logrus.Fields{"group_id": group.ID, "user_id": target.UserID, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "role": target.Role})
+	}
+}
+
+// 移除成员（组长可移除管理员和成员，管理员只能移除成员）
+func RemoveGroupMember() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, member, ok := loadGroupForMember(c, id, model.GroupRoleAdmin)
+		if !ok {
+			return
+		}
+		target, ok := loadTargetMember(c, group.ID)
+		if !ok {
+			return
+		}
+		if target.UserID == id || groupRoleLevel(target.Role) >= groupRoleLevel(member.Role) {
+			c.JSON(403, gin.H{"error": "没有权限移除该成员"})
+			return
+		}
+		if err := repository.DeleteGroupMember(group.ID, target.UserID); err != nil {
+			c.JSON(500, gin.H{"error": "移除成员失败,请重新再试..."})
+			utils.LogError("移除小组成员失败", logrus.Fields{"group_id": group.ID, "user_id": target.UserID, "error": err.Error()})
+			return
+		}
+		manager.EvictFromRoom(groupRoomID(group.ID), target.UserID)
+		recordGroupActivity(group.ID, target.UserID, groupActivityLeft, "被移出了小组")
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// ---------- 共同目标接口 ----------
+
+// 创建共同目标（组长、管理员）
+func CreateGroupFlag() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, _, ok := loadGroupForMember(c, id, model.GroupRoleAdmin)
+		if !ok {
+			return
+		}
+		var req struct {
+			Title       string    `json:"title" binding:"required"`
+			Description string    `json:"description"`
+			Metric      string    `json:"metric" binding:"required"`
+			Target      int       `json:"target" binding:"required"`
+			StartTime   time.Time `json:"start_time"`
+			EndTime     time.Time `json:"end_time" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(400, gin.H{"error": "参数错误"})
+			return
+		}
+		if !model.IsValidGoalMetric(req.Metric) {
+			c.JSON(400, gin.H{"error": "metric 只能是 learn_minutes/daka_days/flag_completions/points"})
+			return
+		}
+		if req.Target <= 0 {
+			c.JSON(400, gin.H{"error": "目标值必须大于0"})
+			return
+		}
+		if req.StartTime.IsZero() {
+			req.StartTime = time.Now()
+		}
+		if !req.EndTime.After(req.StartTime) {
+			c.JSON(400, gin.H{"error": "结束时间必须晚于开始时间"})
+			return
+		}
+		title, ok := moderateOrReject(c, sceneGroup, id, req.Title)
+		if !ok {
+			return
+		}
+		description, ok := moderateOrReject(c, sceneGroup, id, req.Description)
+		if !ok {
+			return
+		}
+		flag := model.GroupFlag{
+			GroupID:     group.ID,
+			Title:       title,
+			Description: description,
+			Metric:      req.Metric,
+			Target:      req.Target,
+			StartTime:   req.StartTime,
+			EndTime:     req.EndTime,
+			CreatedBy:   id,
+		}
+		if err := repository.SaveGroupFlag(&flag); err != nil {
+			c.JSON(500, gin.H{"error": "创建小组目标失败,请重新再试..."})
+			utils.LogError("创建小组目标失败", logrus.Fields{"group_id": group.ID, "error": err.Error()})
+			return
+		}
+		recordGroupActivity(group.ID, id, groupActivityGoalCreated, "发布了小组目标「"+flag.Title+"」")
+		c.JSON(200, gin.H{"success": true, "data": flag})
+	}
+}
+
+// 小组共同目标列表（含当前进度）
+func GetGroupFlags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, _, ok := loadGroupForMember(c, id, model.GroupRoleMember)
+		if !ok {
+			return
+		}
+		flags, err := repository.GetGroupFlags(group.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取小组目标失败,请重新再试..."})
+			utils.LogError("获取小组目标失败", logrus.Fields{"group_id": group.ID, "error": err.Error()})
+			return
+		}
+		memberIDs, err := repository.GetActiveMemberIDs(group.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取小组目标失败,请重新再试..."})
+			return
+		}
+		items := make([]gin.H, 0, len(flags))
+		for _, flag := range flags {
+			progress, err := groupFlagProgress(flag, memberIDs)
+			if err != nil {
+				utils.LogError("计算小组目标进度失败", logrus.Fields{"group_flag_id": flag.ID, "error": err.Error()})
+			}
+			items = append(items, gin.H{
+				"flag":     flag,
+				"progress": progress,
+				"percent":  roundTo1(float64(min(int(progress), flag.Target)) * 100 / float64(flag.Target)),
+			})
+		}
+		c.JSON(200, gin.H{"success": true, "data": items})
+	}
+}
+
+// 删除共同目标（组长、管理员）
+func DeleteGroupFlag() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, _, ok := loadGroupForMember(c, id, model.GroupRoleAdmin)
+		if !ok {
+			return
+		}
+		flagID, err := strconv.ParseUint(c.Param("fid"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "无效的目标ID"})
+			return
+		}
+		flag, err := repository.GetGroupFlagByID(uint(flagID))
+		if err != nil || flag.GroupID != group.ID {
+			c.JSON(404, gin.H{"error": "小组目标不存在"})
+			return
+		}
+		if err := repository.DeleteGroupFlag(flag.ID); err != nil {
+			c.JSON(500, gin.H{"error": "删除小组目标失败,请重新再试..."})
+			utils.LogError("删除小组目标失败", logrus.Fields{"group_flag_id": flag.ID, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true})
+	}
+}
+
+// ---------- 排行和动态 ----------
+
+// 小组封神榜：参数同 /api/ranking，只包含小组成员
+func GetGroupRanking() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, _, ok := loadGroupForMember(c, id, model.GroupRoleMember)
+		if !ok {
+			return
+		}
+		metric := c.DefaultQuery("metric", rankingMetricPoints)
+		window := c.DefaultQuery("window", rankingWindowWeek)
+		if !isValidRankingMetric(metric) {
+			c.JSON(400, gin.H{"error": "metric 只能是 points/learn_time/daka/flags"})
+			return
+		}
+		if _, ok := rankingWindowStart(window); !ok {
+			c.JSON(400, gin.H{"error": "window 只能是 today/week/month/all"})
+			return
+		}
+		memberIDs, err := repository.GetActiveMemberIDs(group.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取小组封神榜失败,请重新再试..."})
+			return
+		}
+		entries, err := rankUsers(metric, window, memberIDs)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取小组封神榜失败,请重新再试..."})
+			utils.LogError("获取小组封神榜失败", logrus.Fields{"group_id": group.ID, "error": err.Error()})
+			return
+		}
+		var me *RankingEntry
+		for i := range entries {
+			if entries[i].UserID == id {
+				me = &entries[i]
+				break
+			}
+		}
+		c.JSON(200, gin.H{"success": true, "metric": metric, "window": window, "data": entries, "me": me})
+	}
+}
+
+// 小组动态（分页，最新在前）
+func GetGroupActivities() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		group, _, ok := loadGroupForMember(c, id, model.GroupRoleMember)
+		if !ok {
+			return
+		}
+		page, limit := parsePagination(c)
+		activities, total, err := repository.GetGroupActivities(group.ID, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取小组动态失败,请重新再试..."})
+			utils.LogError("获取小组动态失败", logrus.Fields{"group_id": group.ID, "error": err.Error()})
+			return
+		}
+		ids := make([]uint, 0, len(activities))
+		for _, a := range activities {
+			if a.UserID != 0 {
+				ids = append(ids, a.UserID)
+			}
+		}
+		briefs, _ := loadUserBriefs(ids)
+		items := make([]gin.H, 0, len(activities))
+		for _, a := range activities {
+			item := gin.H{"id": a.ID, "type": a.Type, "content": a.Content, "created_at": a.CreatedAt}
+			if brief, ok := briefs[a.UserID]; ok {
+				item["user"] = brief
+			}
+			items = append(items, item)
+		}
+		c.JSON(200, gin.H{"success": true, "data": items, "total": total, "page": page, "limit": limit})
+	}
+}
@@ -0,0 +1,23 @@
+package service
+
+import "testing"
+
+func TestGroupActivityForFlagCompleted(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload map[string]interface{}
+		want    string
+	}{
+		{name: "known label", payload: map[string]interface{}{"flag_id": uint(1), "label": 5}, want: "完成了一个「运动」flag"},
+		{name: "unknown label", payload: map[string]interface{}{"flag_id": uint(1), "label": 9}, want: "完成了一个flag"},
+		{name: "missing label", payload: map[string]interface{}{"flag_id": uint(1)}, want: "完成了一个flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, content := groupActivityFor(DomainEvent{Type: EventFlagCompleted, UserID: 1, Payload: tt.payload})
+			if kind != groupActivityFlagCompleted || content != tt.want {
+				t.Errorf("groupActivityFor = (%q, %q), want (%q, %q)", kind, content, groupActivityFlagCompleted, tt.want)
+			}
+		})
+	}
+}
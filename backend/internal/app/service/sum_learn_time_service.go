@@ -42,6 +42,7 @@ func RecordLearnTime() gin.HandlerFunc {
 		}
 
 		utils.LogInfo("记录学习时长成功", logrus.Fields{"user_id": id, "duration": req.Duration})
+		PublishEvent(EventLearnTimeRecorded, id, map[string]interface{}{"duration": req.Duration})
 		c.JSON(200, gin.H{"success": true, "message": "学习时长已记录", "duration": req.Duration})
 	}
 }
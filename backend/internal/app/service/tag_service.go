@@ -0,0 +1,167 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultTrendingDays = 7
+	maxTrendingDays     = 30
+)
+
+// 解析内容中的 #话题 并建立索引，返回话题列表
+func indexContentTags(ownerType string, ownerID uint, text string) []string {
+	tags := utils.ParseHashtags(text)
+	if err := repository.ReplaceContentTags(ownerType, ownerID, tags); err != nil {
+		utils.LogError("保存话题失败", logrus.Fields{"owner_type": ownerType, "owner_id": ownerID, "error": err.Error()})
+	}
+	return tags
+}
+
+// 解析内容中的 @用户名，记录并通知新被提及的用户，返回本条内容提及的用户
+// canSee 判断被提及的人能否看到这条内容，为 nil 时表示所有人可见
+// extra 写入通知的 data，用于前端跳转（如 post_id、flag_id）
+func notifyMentions(ownerType string, ownerID, authorID uint, text string, extra gin.H, canSee func(uint) bool) []UserBrief {
+	names := utils.ParseMentions(text)
+	if len(names) == 0 {
+		return nil
+	}
+	users, err := repository.GetUsersByNames(names)
+	if err != nil {
+		utils.LogError("解析提及用户失败", logrus.Fields{"owner_type": ownerType, "owner_id": ownerID, "error": err.Error()})
+		return nil
+	}
+	already, err := repository.GetMentionedUserIDs(ownerType, ownerID)
+	if err != nil {
+		utils.LogError("获取已提及用户失败", logrus.Fields{"owner_type": ownerType, "owner_id": ownerID, "error": err.Error()})
+		return nil
+	}
+	notified := make(map[uint]bool, len(already))
+	for _, id := range already {
+		notified[id] = true
+	}
+
+	var mentionedIDs []uint
+	var fresh []model.Mention
+	for _, u := range users {
+		if u.ID == authorID {
+			continue
+		}
+		if blocked, err := repository.IsBlockedBetween(authorID, u.ID); err != nil || blocked {
+			continue
+		}
+		if canSee != nil && !canSee(u.ID) {
+			continue
+		}
+		mentionedIDs = append(mentionedIDs, u.ID)
+		if !notified[u.ID] {
+			fresh = append(fresh, model.Mention{UserID: u.ID, FromUserID: authorID, OwnerType: ownerType, OwnerID: ownerID})
+		}
+	}
+	briefs, err := loadUserBriefs(mentionedIDs)
+	if err != nil {
+		utils.LogError("获取提及用户信息失败", logrus.Fields{"owner_type": ownerType, "owner_id": ownerID, "error": err.Error()})
+		return nil
+	}
+	mentioned := make([]UserBrief, 0, len(mentionedIDs))
+	for _, id := range mentionedIDs {
+		mentioned = append(mentioned, briefs[id])
+	}
+	if err := repository.SaveMentions(fresh); err != nil {
+		utils.LogError("保存提及记录失败", logrus.Fields{"owner_type": ownerType, "owner_id": ownerID, "error": err.Error()})
+		return mentioned
+	}
+	if len(fresh) == 0 {
+		return mentioned
+	}
+
+	for _, m := range fresh {
+		notify(model.Notification{
+			UserID:     m.UserID,
+			Type:       model.NotificationMention,
+			ActorID:    authorID,
+			TargetType: ownerType,
+			TargetID:   ownerID,
+			Content:    text,
+			Data:       extra,
+		})
+	}
+	return mentioned
+}
+
+// 截取前 n 个字
+func excerpt(text string, n int) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) <= n {
+		return string(runes)
+	}
+	return string(runes[:n]) + "…"
+}
+
+// 热门话题：days 天内（默认 7，最多 30）使用次数最多的话题
+func GetTrendingTags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultTrendingDays)))
+		if err != nil || days < 1 || days > maxTrendingDays {
+			c.JSON(400, gin.H{"error": "days 必须在1-" + strconv.Itoa(maxTrendingDays) + "之间"})
+			return
+		}
+		_, limit := parsePagination(c)
+		tags, err := repository.GetTrendingTags(time.Now().AddDate(0, 0, -days), limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取热门话题失败,请重新再试..."})
+			utils.LogError("获取热门话题失败", logrus.Fields{"error": err.Error()})
+			return
+		}
+		if tags == nil {
+			tags = []repository.TagStat{}
+		}
+		c.JSON(200, gin.H{"success": true, "data": tags, "days": days})
+	}
+}
+
+// 某个话题下的帖子（最新在前，分页）
+func GetPostsByTag() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, _ := getCurrentUserID(c)
+		name := strings.ToLower(strings.Trim(c.Param("name"), "#"))
+		if name == "" {
+			c.JSON(400, gin.H{"error": "话题不能为空"})
+			return
+		}
+		page, limit := parsePagination(c)
+		tag, err := repository.GetTagByName(name)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(200, gin.H{"success": true, "tag": name, "data": []model.Post{}, "total": 0, "page": page, "limit": limit})
+			return
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取话题帖子失败,请重新再试..."})
+			utils.LogError("获取话题失败", logrus.Fields{"tag": name, "error": err.Error()})
+			return
+		}
+		blocked, err := repository.GetBlockedIDsBetween(id)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取话题帖子失败,请重新再试..."})
+			utils.LogError("获取拉黑关系失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		posts, total, err := repository.GetPostsByTag(tag.ID, blocked, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取话题帖子失败,请重新再试..."})
+			utils.LogError("获取话题帖子失败", logrus.Fields{"tag": name, "error": err.Error()})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "tag": tag.Name, "data": posts, "total": total, "page": page, "limit": limit})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strings"
 
 	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
@@ -64,6 +65,12 @@ func JWTAuth() gin.HandlerFunc {
 
 		log.Printf("[JWT] Token 验证成功 - 用户ID: %d, 用户名: %s", claims.UserID, claims.Username)
 
+		// 封禁期间只能浏览，点赞、关注、举报等写操作一律拒绝
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead && rejectIfUserSuspended(c, claims.UserID) {
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存入上下文
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
@@ -74,6 +81,22 @@ func JWTAuth() gin.HandlerFunc {
 	}
 }
 
+// 不强制登录的接口读取可选的 token（Authorization 头或 URL 参数），无效或缺失时返回 0
+func optionalUserID(c *gin.Context) uint {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if token == "" {
+		token = c.Query("token")
+	}
+	if token == "" {
+		return 0
+	}
+	claims, err := utils.ParseToken(token)
+	if err != nil {
+		return 0
+	}
+	return claims.UserID
+}
+
 func getCurrentUserID(c *gin.Context) (uint, bool) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -89,6 +112,49 @@ func getCurrentUserID(c *gin.Context) (uint, bool) {
 	return id, true
 }
 
+// 角色校验中间件（需放在 JWTAuth 之后），角色以数据库为准
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := getCurrentUserID(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "未登录"})
+			return
+		}
+		user, err := repository.GetUserByID(id)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"code": 401, "msg": "用户不存在"})
+			return
+		}
+		for _, role := range roles {
+			if user.Role == role {
+				c.Next()
+				return
+			}
+		}
+		utils.LogInfo("权限不足", logrus.Fields{"user_id": id, "role": user.Role, "path": c.FullPath()})
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"code": 403, "msg": "没有权限"})
+	}
+}
+
+// 把 ADMIN_EMAILS（逗号分隔）中的用户设为管理员
+func promoteConfiguredAdmins() {
+	var emails []string
+	for _, email := range strings.Split(os.Getenv("ADMIN_EMAILS"), ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	if len(emails) == 0 {
+		return
+	}
+	promoted, err := repository.PromoteAdmins(emails)
+	if err != nil {
+		utils.LogError("设置管理员失败", logrus.Fields{"error": err.Error()})
+		return
+	}
+	utils.LogInfo("设置管理员完成", logrus.Fields{"promoted": promoted})
+}
+
 // 用户注册
 func RegisterUser() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -129,8 +195,6 @@ func RegisterUser() gin.HandlerFunc {
 		repository.SaveEmailCodeToDB(code, user.Email)
 		c.Set("user_password", password)
 		c.Next()
-		// 初始化用户成就表
-		user = InitAchievementTable(user)
 		AddUserCronJob(user)
 		if err := repository.AddUserToDB(user); err != nil {
 			c.JSON(405, gin.H{"error": "注册失败,请重新再试..."})
@@ -164,6 +228,10 @@ func LoginUser() gin.HandlerFunc {
 			c.JSON(401, gin.H{"error": "用户名或密码错误,请重新再试..."})
 			return
 		}
+		// 封禁期间不允许登录
+		if rejectIfSuspended(c, user.SuspendedUntil) {
+			return
+		}
 		token, err := utils.GenerateToken(user.ID, user.Name, user.Email)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -180,6 +248,7 @@ func LoginUser() gin.HandlerFunc {
 			"name":             user.Name,
 			"email":            user.Email,
 			"head_show":        user.HeadShow,
+			"avatar":           user.AvatarURL(),
 			"daka":             user.Daka,
 			"flag_number":      user.FlagNumber,
 			"count":            user.Count,
@@ -335,6 +404,7 @@ func GetUserStats() gin.HandlerFunc {
 			"name":             user.Name,
 			"head_show":        user.HeadShow,
 			"avatar_index":     user.HeadShow,
+			"avatar":           user.AvatarURL(),
 			"total_points":     user.Count,
 			"month_learn_time": user.MonthLearntime,
 			"completed_flags":  len(doneFlags),
@@ -367,6 +437,7 @@ func GetUser() gin.HandlerFunc {
 			"email":            user.Email,
 			"phone":            user.Email,
 			"head_show":        user.HeadShow,
+			"avatar":           user.AvatarURL(),
 			"daka":             user.Daka,
 			"flag_number":      user.FlagNumber,
 			"count":            user.Count,
@@ -406,6 +477,12 @@ func DoDaKa() gin.HandlerFunc {
 			return
 		}
 		utils.LogInfo("用户打卡成功", logrus.Fields{"user_id": id})
+		// 重复打卡会取消今天的打卡，事件中带上最新状态
+		done := true
+		if daka, err := repository.GetRecentDakaNumber(id); err == nil {
+			done = daka.HadDone
+		}
+		PublishEvent(EventDaka, id, map[string]interface{}{"done": done})
 		c.JSON(http.StatusOK, gin.H{"message": "打卡成功!"})
 	}
 }
@@ -506,18 +583,22 @@ func SwithHead() gin.HandlerFunc {
 			return
 		}
 
-		// 验证头像编号必须在1-32之间（支持全部头像）
-		if req.Number < 1 || req.Number > 32 {
-			c.JSON(400, gin.H{"error": "头像编号必须在1-32之间"})
+		// 验证头像编号必须在内置头像范围内
+		if req.Number < 1 || req.Number > utils.BuiltinAvatarCount {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("头像编号必须在1-%d之间", utils.BuiltinAvatarCount)})
 			log.Printf("Invalid avatar number: %d", req.Number)
 			return
 		}
 
 		log.Printf("切换头像 - 用户ID: %d, 头像编号: %d", id, req.Number)
 		user, _ := repository.GetUserByID(id)
+		// 选择内置头像时不再使用自定义头像
+		oldKey := user.AvatarKey
 		user.HeadShow = req.Number
+		user.AvatarKey = ""
 		repository.SaveUserToDB(user)
-		c.JSON(200, gin.H{"success": true})
+		removeAvatarFiles(c.Request.Context(), oldKey)
+		c.JSON(200, gin.H{"success": true, "avatar": user.AvatarURL()})
 	}
 }
 
@@ -550,7 +631,7 @@ func AddPointsHandler() gin.HandlerFunc {
 		utils.LogInfo("开始添加积分", logrus.Fields{"user_id": id, "points": req.Points})
 
 		// 问题5&6修复：使用原子自增操作，直接传递增量
-		err := repository.CountAddDB(id, req.Points)
+		err := AwardPoints(id, req.Points)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "积分添加失败，请稍后重试"})
 			utils.LogError("积分添加失败：数据库更新错误", logrus.Fields{"user_id": id, "points": req.Points, "error": err.Error()})
@@ -0,0 +1,441 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
+	utils "github.com/NCUHOME-Y/25-Hack4-Unimate-BE/util"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const taskWeeklyReport = "weekly_report"
+
+// 周报中的统计数据
+type weeklyStats struct {
+	WeekStart      time.Time
+	LearnMinutes   int
+	LearnDays      int
+	DakaDays       int
+	FlagsCompleted int
+	PointsEarned   int
+	DailyMinutes   []int // 周一到周日每天的学习时长
+}
+
+const weeklyReportSystemPrompt = `你是"太傅AI学习教练"，根据用户一周的学习数据写一份简短的学习周报。
+
+【要求】
+1. summary：2-3句话总结本周表现，语气积极但实事求是
+2. suggestions：2-3条具体可执行的下周建议
+3. 数据为0时不要编造内容，应鼓励用户开始行动
+
+【返回JSON格式】（不要包含markdown代码块标记）
+{
+    "summary": "本周总结",
+    "suggestions": ["建议1", "建议2"]
+}`
+
+var weekdayNames = []string{"周一", "周二", "周三", "周四", "周五", "周六", "周日"}
+
+// 计算某时间所在周的周一 00:00
+func weekStartOf(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	return day.AddDate(0, 0, -offset)
+}
+
+// 汇总用户一周的学习数据
+func collectWeeklyStats(userID uint, weekStart time.Time) (weeklyStats, error) {
+	weekEnd := weekStart.AddDate(0, 0, 7)
+	stats := weeklyStats{WeekStart: weekStart, DailyMinutes: make([]int, 7)}
+
+	learnTimes, err := repository.GetLearnTimesBetween(userID, weekStart, weekEnd)
+	if err != nil {
+		return stats, err
+	}
+	for _, lt := range learnTimes {
+		idx := int(lt.CreatedAt.Sub(weekStart).Hours() / 24)
+		if idx < 0 || idx > 6 {
+			continue
+		}
+		stats.DailyMinutes[idx] += lt.Duration
+		stats.LearnMinutes += lt.Duration
+	}
+	for _, minutes := range stats.DailyMinutes {
+		if minutes > 0 {
+			stats.LearnDays++
+		}
+	}
+
+	dakaDays, err := repository.CountDakaDaysBetween(userID, weekStart, weekEnd)
+	if err != nil {
+		return stats, err
+	}
+	stats.DakaDays = int(dakaDays)
+
+	completed, err := repository.CountFlagCompletionsBetween(userID, weekStart, weekEnd)
+	if err != nil {
+		return stats, err
+	}
+	stats.FlagsCompleted = int(completed)
+
+	stats.PointsEarned, err = repository.SumPointsBetween(userID, weekStart, weekEnd)
+	return stats, err
+}
+
+// 构建周报的模型请求
+func buildWeeklyReportLLMRequest(stats weeklyStats) LLMRequest {
+	var b strings.Builder
+	fmt.Fprintf(&b, "周期: %s 至 %s\n", stats.WeekStart.Format("2006-01-02"), stats.WeekStart.AddDate(0, 0, 6).Format("2006-01-02"))
+	fmt.Fprintf(&b, "学习时长: %d 分钟（%d 天有学习记录）\n", stats.LearnMinutes, stats.LearnDays)
+	fmt.Fprintf(&b, "打卡天数: %d\n", stats.DakaDays)
+	fmt.Fprintf(&b, "完成flag次数: %d\n", stats.FlagsCompleted)
+	fmt.Fprintf(&b, "获得积分: %d\n", stats.PointsEarned)
+	b.WriteString("每日学习时长（分钟）:\n")
+	for i, minutes := range stats.DailyMinutes {
+		fmt.Fprintf(&b, "- %s: %d\n", weekdayNames[i], minutes)
+	}
+	b.WriteString("\n请根据以上数据生成学习周报,返回标准JSON格式。")
+
+	return LLMRequest{
+		Task: taskWeeklyReport,
+		Messages: []LLMMessage{
+			{Role: "system", Content: weeklyReportSystemPrompt},
+			{Role: "user", Content: b.String()},
+		},
+	}
+}
+
+// 解析周报响应
+func parseWeeklyReportResponse(response string) (string, []string, error) {
+	clean := strings.TrimSpace(response)
+	clean = strings.TrimPrefix(clean, "```json")
+	clean = strings.TrimPrefix(clean, "```")
+	clean = strings.TrimSuffix(clean, "```")
+	clean = strings.TrimSpace(clean)
+
+	var result struct {
+		Summary     string   `json:"summary"`
+		Suggestions []string `json:"suggestions"`
+	}
+	if err := json.Unmarshal([]byte(clean), &result); err != nil {
+		return "", nil, fmt.Errorf("解析周报失败: %v", err)
+	}
+	if result.Summary == "" {
+		return "", nil, fmt.Errorf("周报总结为空")
+	}
+	return result.Summary, result.Suggestions, nil
+}
+
+// 模型不可用时的模板周报
+func templateWeeklyReport(stats weeklyStats) (string, []string) {
+	if stats.LearnMinutes == 0 && stats.DakaDays == 0 && stats.FlagsCompleted == 0 {
+		return "本周还没有学习记录。", []string{
+			"从每天15分钟开始，先把学习习惯建立起来",
+			"给自己定一个小flag，完成后记得打卡",
+		}
+	}
+	summary := fmt.Sprintf("本周学习 %d 分钟，%d 天有学习记录，打卡 %d 天，完成flag %d 次，获得积分 %d。",
+		stats.LearnMinutes, stats.LearnDays, stats.DakaDays, stats.FlagsCompleted, stats.PointsEarned)
+	suggestions := []string{}
+	if stats.LearnDays < 5 {
+		suggestions = append(suggestions, "尽量保证每周至少5天有学习记录，保持节奏比单次时长更重要")
+	}
+	if stats.DakaDays < stats.LearnDays {
+		suggestions = append(suggestions, "学习后别忘了打卡，记录自己的坚持")
+	}
+	if stats.FlagsCompleted == 0 {
+		suggestions = append(suggestions, "把学习目标拆成小flag，完成一个就记一次进度")
+	}
+	if len(suggestions) == 0 {
+		suggestions = append(suggestions, "状态很好，下周可以尝试适当提高flag的每日目标")
+	}
+	return summary, suggestions
+}
+
+// 本周是否有任何学习数据
+func (s weeklyStats) hasActivity() bool {
+	return s.LearnMinutes > 0 || s.DakaDays > 0 || s.FlagsCompleted > 0 || s.PointsEarned > 0
+}
+
+// 已保存的周报数据是否与当前统计一致（一致时无需重新生成）
+func weeklyReportUpToDate(report model.WeeklyReport, stats weeklyStats) bool {
+	daily, _ := json.Marshal(stats.DailyMinutes)
+	return report.LearnMinutes == stats.LearnMinutes &&
+		report.LearnDays == stats.LearnDays &&
+		report.DakaDays == stats.DakaDays &&
+		report.FlagsCompleted == stats.FlagsCompleted &&
+		report.PointsEarned == stats.PointsEarned &&
+		report.DailyMinutes == string(daily)
+}
+
+// 生成并保存周报；useLLM 为 false 或模型失败时使用模板周报
+func generateWeeklyReport(ctx context.Context, userID uint, stats weeklyStats, useLLM bool) (model.WeeklyReport, error) {
+	source := "template"
+	summary, suggestions := templateWeeklyReport(stats)
+	if useLLM {
+		initPlanner()
+		response, err := planner.Provider.ChatCompletion(ctx, buildWeeklyReportLLMRequest(stats))
+		if err == nil {
+			var llmSuggestions []string
+			var llmSummary string
+			llmSummary, llmSuggestions, err = parseWeeklyReportResponse(response)
+			if err == nil {
+				source = "llm"
+				summary, suggestions = llmSummary, llmSuggestions
+			}
+		}
+		if err != nil {
+			utils.LogError("AI生成周报失败，使用模板周报", logrus.Fields{"user_id": userID, "error": err.Error()})
+		}
+	}
+
+	daily, _ := json.Marshal(stats.DailyMinutes)
+	suggestionsJSON, _ := json.Marshal(suggestions)
+	report := model.WeeklyReport{
+		UserID:         userID,
+		WeekStart:      stats.WeekStart,
+		LearnMinutes:   stats.LearnMinutes,
+		LearnDays:      stats.LearnDays,
+		DakaDays:       stats.DakaDays,
+		FlagsCompleted: stats.FlagsCompleted,
+		PointsEarned:   stats.PointsEarned,
+		DailyMinutes:   string(daily),
+		Summary:        summary,
+		Suggestions:    string(suggestionsJSON),
+		Source:         source,
+	}
+	if err := repository.SaveWeeklyReport(&report); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// 周报返回给前端的结构（展开JSON字段）
+func weeklyReportView(report model.WeeklyReport) gin.H {
+	var daily []int
+	var suggestions []string
+	json.Unmarshal([]byte(report.DailyMinutes), &daily)
+	json.Unmarshal([]byte(report.Suggestions), &suggestions)
+	return gin.H{
+		"id":              report.ID,
+		"week_start":      report.WeekStart.Format("2006-01-02"),
+		"week_end":        report.WeekStart.AddDate(0, 0, 6).Format("2006-01-02"),
+		"learn_minutes":   report.LearnMinutes,
+		"learn_days":      report.LearnDays,
+		"daka_days":       report.DakaDays,
+		"flags_completed": report.FlagsCompleted,
+		"points_earned":   report.PointsEarned,
+		"daily_minutes":   daily,
+		"summary":         report.Summary,
+		"suggestions":     suggestions,
+		"source":          report.Source,
+		"updated_at":      report.UpdatedAt,
+	}
+}
+
+// 周报纯文本（邮件用）
+func weeklyReportText(report model.WeeklyReport) string {
+	var suggestions []string
+	json.Unmarshal([]byte(report.Suggestions), &suggestions)
+	var b strings.Builder
+	fmt.Fprintf(&b, "你的学习周报（%s 至 %s）\n\n", report.WeekStart.Format("2006-01-02"), report.WeekStart.AddDate(0, 0, 6).Format("2006-01-02"))
+	fmt.Fprintf(&b, "学习时长：%d 分钟（%d 天）\n", report.LearnMinutes, report.LearnDays)
+	fmt.Fprintf(&b, "打卡天数：%d\n", report.DakaDays)
+	fmt.Fprintf(&b, "完成flag：%d 次\n", report.FlagsCompleted)
+	fmt.Fprintf(&b, "获得积分：%d\n\n", report.PointsEarned)
+	fmt.Fprintf(&b, "%s\n", report.Summary)
+	if len(suggestions) > 0 {
+		b.WriteString("\n下周建议：\n")
+		for i, s := range suggestions {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, s)
+		}
+	}
+	return b.String()
+}
+
+// 获取周报列表
+func GetWeeklyReports() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		page, limit := parsePagination(c)
+		reports, total, err := repository.GetWeeklyReportsByUserID(id, (page-1)*limit, limit)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "获取周报失败,请重新再试..."})
+			utils.LogError("获取周报列表失败", logrus.Fields{"user_id": id, "error": err.Error()})
+			return
+		}
+		items := make([]gin.H, 0, len(reports))
+		for _, r := range reports {
+			items = append(items, weeklyReportView(r))
+		}
+		c.JSON(200, gin.H{"success": true, "reports": items, "total": total, "page": page, "limit": limit})
+	}
+}
+
+// 获取周报详情
+func GetWeeklyReportDetail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		reportID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "无效的周报ID"})
+			return
+		}
+		report, err := repository.GetWeeklyReportByID(uint(reportID))
+		if err != nil || report.UserID != id {
+			c.JSON(404, gin.H{"error": "周报不存在"})
+			return
+		}
+		c.JSON(200, gin.H{"success": true, "report": weeklyReportView(report)})
+	}
+}
+
+// 生成周报：week_start 可选（默认本周）
+// 已有周报且数据没有变化时直接返回（refresh=true 强制重新生成）；调用模型时计入每日 AI 配额，没有学习数据的周使用模板
+func GenerateWeeklyReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := getCurrentUserID(c)
+		if !ok {
+			c.JSON(401, gin.H{"error": "未授权"})
+			return
+		}
+		var req struct {
+			WeekStart string `json:"week_start"` // 2006-01-02，自动对齐到周一
+			Refresh   bool   `json:"refresh"`    // 强制重新生成
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(400, gin.H{"error": "参数错误,请重新再试..."})
+				return
+			}
+		}
+
+		currentWeek := weekStartOf(time.Now())
+		weekStart := currentWeek
+		if req.WeekStart != "" {
+			t, err := time.ParseInLocation("2006-01-02", req.WeekStart, time.Local)
+			if err != nil {
+				c.JSON(400, gin.H{"error": "week_start 格式应为 YYYY-MM-DD"})
+				return
+			}
+			weekStart = weekStartOf(t)
+			if weekStart.After(currentWeek) {
+				c.JSON(400, gin.H{"error": "不能生成未来的周报"})
+				return
+			}
+		}
+
+		stats, err := collectWeeklyStats(id, weekStart)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "生成周报失败,请重新再试..."})
+			utils.LogError("统计周报数据失败", logrus.Fields{"user_id": id, "week_start": weekStart.Format("2006-01-02"), "error": err.Error()})
+			return
+		}
+		if !req.Refresh {
+			if report, err := repository.GetWeeklyReportByWeek(id, weekStart); err == nil && weeklyReportUpToDate(report, stats) {
+				c.JSON(200, gin.H{"success": true, "report": weeklyReportView(report)})
+				return
+			}
+		}
+
+		useLLM := stats.hasActivity()
+		usage := &model.AIUsage{UserID: id, Task: taskWeeklyReport}
+		if useLLM && !reserveAIUsage(c, usage) {
+			return
+		}
+		report, err := generateWeeklyReport(c.Request.Context(), id, stats, useLLM)
+		if useLLM && report.Source != "llm" {
+			releaseAIUsage(usage)
+		}
+		if err != nil {
+			c.JSON(500, gin.H{"error": "生成周报失败,请重新再试..."})
+			utils.LogError("生成周报失败", logrus.Fields{"user_id": id, "week_start": weekStart.Format("2006-01-02"), "error": err.Error()})
+			return
+		}
+		repository.AddTrackPointToDB(id, "生成学习周报")
+		utils.LogInfo("生成周报成功", logrus.Fields{"user_id": id, "report_id": report.ID, "source": report.Source})
+		c.JSON(200, gin.H{"success": true, "report": weeklyReportView(report)})
+	}
+}
+
+// 是否通过邮件发送周报（WEEKLY_REPORT_EMAIL=true 开启）
+func weeklyReportEmailEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("WEEKLY_REPORT_EMAIL"))
+	return enabled
+}
+
+// 定时任务：为上周有学习数据的用户生成周报，并按配置发送邮件
+// 已生成且数据没有变化的周报不再重新生成
+func RunWeeklyReportJob() {
+	lastWeek := weekStartOf(time.Now()).AddDate(0, 0, -7)
+	users, err := repository.GetAllUser()
+	if err != nil {
+		utils.LogError("周报任务获取用户列表失败", logrus.Fields{"error": err.Error()})
+		return
+	}
+	sendEmail := weeklyReportEmailEnabled()
+	generated := 0
+	for _, user := range users {
+		stats, err := collectWeeklyStats(user.ID, lastWeek)
+		if err != nil {
+			utils.LogError("统计周报数据失败", logrus.Fields{"user_id": user.ID, "error": err.Error()})
+			continue
+		}
+		if !stats.hasActivity() {
+			continue
+		}
+		report, err := repository.GetWeeklyReportByWeek(user.ID, lastWeek)
+		if err != nil || !weeklyReportUpToDate(report, stats) {
+			report, err = generateWeeklyReport(context.Background(), user.ID, stats, true)
+			if err != nil {
+				utils.LogError("生成周报失败", logrus.Fields{"user_id": user.ID, "error": err.Error()})
+				continue
+			}
+			generated++
+		}
+		if !sendEmail || user.Email == "" || !user.IsRemind || report.EmailedAt != nil {
+			continue
+		}
+		if err := utils.SentEmail(user.Email, "知序：你的学习周报", weeklyReportText(report)); err != nil {
+			utils.LogError("发送周报邮件失败", logrus.Fields{"user_id": user.ID, "error": err.Error()})
+			continue
+		}
+		if err := repository.UpdateWeeklyReportEmailedAt(report.ID, time.Now()); err != nil {
+			utils.LogError("更新周报发送时间失败", logrus.Fields{"report_id": report.ID, "error": err.Error()})
+		}
+	}
+	utils.LogInfo("周报任务执行完成", logrus.Fields{"week_start": lastWeek.Format("2006-01-02"), "generated": generated, "email": sendEmail})
+}
+
+var fakeWeeklyMinutesPattern = regexp.MustCompile(`学习时长: (\d+) 分钟（(\d+) 天`)
+
+// fake provider 的周报响应
+func fakeWeeklyReportResponse(userPrompt string) string {
+	minutes, days := 0, 0
+	if m := fakeWeeklyMinutesPattern.FindStringSubmatch(userPrompt); m != nil {
+		minutes, _ = strconv.Atoi(m[1])
+		days, _ = strconv.Atoi(m[2])
+	}
+	out, _ := json.Marshal(map[string]interface{}{
+		"summary":     fmt.Sprintf("本周共学习 %d 分钟，%d 天有学习记录，继续保持。", minutes, days),
+		"suggestions": []string{"固定每天的学习时间段", "每完成一个flag就及时记录进度"},
+	})
+	return string(out)
+}
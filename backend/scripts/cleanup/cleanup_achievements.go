@@ -29,12 +29,8 @@ func main() {
 	// 	"任务收藏家":   "任务大师",
 	// }
 
-	// 标准的16个成就名称
-	validAchievementNames := []string{
-		"首次完成", "7天连卡", "任务大师", "目标达成", "学习之星", "坚持不懈",
-		"效率达人", "专注大师", "早起鸟", "夜猫子", "完美主义", "全能选手",
-		"学习狂人", "社交达人", "时间管理者", "成就收集者",
-	}
+	// 标准成就名称以注册表为准
+	validAchievementNames := model.AchievementNames()
 
 	// 创建成就名称集合以便快速查找
 	validNamesSet := make(map[string]bool)
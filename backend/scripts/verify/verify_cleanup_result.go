@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/model"
 	"github.com/NCUHOME-Y/25-Hack4-Unimate-BE/internal/app/repository"
 	"github.com/joho/godotenv"
 )
@@ -34,11 +35,7 @@ func main() {
 		fmt.Printf("用户 %s (ID: %d): %d 个成就\n", user.Name, user.ID, len(achievements))
 
 		// 检查成就名称是否有效
-		validNames := []string{
-			"首次完成", "7天连卡", "任务大师", "目标达成", "学习之星", "坚持不懈",
-			"效率达人", "专注大师", "早起鸟", "夜猫子", "完美主义", "全能选手",
-			"学习狂人", "社交达人", "时间管理者", "成就收集者",
-		}
+		validNames := model.AchievementNames()
 		validSet := make(map[string]bool)
 		for _, name := range validNames {
 			validSet[name] = true
@@ -0,0 +1,99 @@
+package utils
+
+import "unicode"
+
+// AhoCorasick 多模式串匹配自动机，按 rune 匹配，忽略英文大小写
+type AhoCorasick struct {
+	nodes []acNode
+	lens  []int // 每个模式串的 rune 长度
+}
+
+type acNode struct {
+	next    map[rune]int
+	fail    int
+	outputs []int // 以该节点结尾的模式串下标
+}
+
+// ACMatch 一次命中，Start/End 为 rune 下标（左闭右开）
+type ACMatch struct {
+	Pattern int
+	Start   int
+	End     int
+}
+
+// NewAhoCorasick 用模式串构建自动机，空串会被忽略
+func NewAhoCorasick(patterns []string) *AhoCorasick {
+	ac := &AhoCorasick{nodes: []acNode{{next: map[rune]int{}}}, lens: make([]int, len(patterns))}
+	for i, p := range patterns {
+		if p == "" {
+			continue
+		}
+		ac.lens[i] = len([]rune(p))
+		cur := 0
+		for _, r := range p {
+			r = unicode.ToLower(r)
+			nxt, ok := ac.nodes[cur].next[r]
+			if !ok {
+				ac.nodes = append(ac.nodes, acNode{next: map[rune]int{}})
+				nxt = len(ac.nodes) - 1
+				ac.nodes[cur].next[r] = nxt
+			}
+			cur = nxt
+		}
+		ac.nodes[cur].outputs = append(ac.nodes[cur].outputs, i)
+	}
+	ac.build()
+	return ac
+}
+
+// 广度优先构建 fail 指针，并把 fail 链上的输出合并到当前节点
+func (ac *AhoCorasick) build() {
+	queue := make([]int, 0, len(ac.nodes))
+	for _, child := range ac.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for r, child := range ac.nodes[cur].next {
+			f := ac.nodes[cur].fail
+			for f != 0 {
+				if _, ok := ac.nodes[f].next[r]; ok {
+					break
+				}
+				f = ac.nodes[f].fail
+			}
+			if nxt, ok := ac.nodes[f].next[r]; ok && nxt != child {
+				ac.nodes[child].fail = nxt
+			}
+			ac.nodes[child].outputs = append(ac.nodes[child].outputs, ac.nodes[ac.nodes[child].fail].outputs...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// FindAll 返回文本中所有命中（可重叠）
+func (ac *AhoCorasick) FindAll(text string) []ACMatch {
+	var matches []ACMatch
+	if ac == nil || len(ac.nodes) == 1 {
+		return matches
+	}
+	runes := []rune(text)
+	cur := 0
+	for i, r := range runes {
+		r = unicode.ToLower(r)
+		for cur != 0 {
+			if _, ok := ac.nodes[cur].next[r]; ok {
+				break
+			}
+			cur = ac.nodes[cur].fail
+		}
+		if nxt, ok := ac.nodes[cur].next[r]; ok {
+			cur = nxt
+		}
+		for _, p := range ac.nodes[cur].outputs {
+			matches = append(matches, ACMatch{Pattern: p, Start: i + 1 - ac.lens[p], End: i + 1})
+		}
+	}
+	return matches
+}
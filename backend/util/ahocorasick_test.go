@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAhoCorasickFindAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		text     string
+		want     []ACMatch
+	}{
+		{
+			name:     "no patterns",
+			patterns: nil,
+			text:     "任何内容",
+			want:     nil,
+		},
+		{
+			name:     "no match",
+			patterns: []string{"作弊"},
+			text:     "认真学习",
+			want:     nil,
+		},
+		{
+			name:     "rune offsets for chinese text",
+			patterns: []string{"代考"},
+			text:     "有人代考吗",
+			want:     []ACMatch{{Pattern: 0, Start: 2, End: 4}},
+		},
+		{
+			name:     "overlapping and nested patterns",
+			patterns: []string{"he", "she", "his", "hers"},
+			text:     "ushers",
+			want: []ACMatch{
+				{Pattern: 1, Start: 1, End: 4},
+				{Pattern: 0, Start: 2, End: 4},
+				{Pattern: 3, Start: 2, End: 6},
+			},
+		},
+		{
+			name:     "case insensitive",
+			patterns: []string{"SPAM"},
+			text:     "this is Spam",
+			want:     []ACMatch{{Pattern: 0, Start: 8, End: 12}},
+		},
+		{
+			name:     "repeated matches",
+			patterns: []string{"aa"},
+			text:     "aaa",
+			want:     []ACMatch{{Pattern: 0, Start: 0, End: 2}, {Pattern: 0, Start: 1, End: 3}},
+		},
+		{
+			name:     "empty pattern is ignored",
+			patterns: []string{"", "ab"},
+			text:     "cab",
+			want:     []ACMatch{{Pattern: 1, Start: 1, End: 3}},
+		},
+		{
+			name:     "fail link to a shorter pattern",
+			patterns: []string{"abcd", "bc"},
+			text:     "abce",
+			want:     []ACMatch{{Pattern: 1, Start: 1, End: 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewAhoCorasick(tt.patterns).FindAll(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindAll(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// 把图片铺到白色背景上转成 RGBA（透明部分变为白色，便于编码为 JPEG）
+func flattenRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Over)
+	return dst
+}
+
+// 缩放到 w×h，缩小时按覆盖区域取平均（盒式滤波），放大时取最近像素
+// 源图逐行铺白转换，只额外占用一行像素的内存，不复制整张原图
+func Resize(src image.Image, w, h int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if w <= 0 || h <= 0 || sw == 0 || sh == 0 {
+		return image.NewRGBA(image.Rect(0, 0, max(w, 0), max(h, 0)))
+	}
+	if w == sw && h == sh {
+		return flattenRGBA(src)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	row := image.NewRGBA(image.Rect(0, 0, sw, 1))
+	loaded := -1
+	loadRow := func(sy int) {
+		if sy == loaded {
+			return
+		}
+		draw.Draw(row, row.Rect, image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(row, row.Rect, src, image.Pt(b.Min.X, b.Min.Y+sy), draw.Over)
+		loaded = sy
+	}
+	sums := make([]uint32, w*4)
+	for y := 0; y < h; y++ {
+		y0 := y * sh / h
+		y1 := max((y+1)*sh/h, y0+1)
+		clear(sums)
+		for sy := y0; sy < y1; sy++ {
+			loadRow(sy)
+			for x := 0; x < w; x++ {
+				x0 := x * sw / w
+				x1 := max((x+1)*sw/w, x0+1)
+				s := sums[x*4 : x*4+4]
+				for sx := x0; sx < x1; sx++ {
+					p := row.Pix[sx*4 : sx*4+4]
+					s[0] += uint32(p[0])
+					s[1] += uint32(p[1])
+					s[2] += uint32(p[2])
+					s[3] += uint32(p[3])
+				}
+			}
+		}
+		for x := 0; x < w; x++ {
+			x0 := x * sw / w
+			n := uint32((max((x+1)*sw/w, x0+1) - x0) * (y1 - y0))
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = uint8(sums[x*4] / n)
+			dst.Pix[i+1] = uint8(sums[x*4+1] / n)
+			dst.Pix[i+2] = uint8(sums[x*4+2] / n)
+			dst.Pix[i+3] = uint8(sums[x*4+3] / n)
+		}
+	}
+	return dst
+}
+
+// 等比缩小到最长边不超过 maxSide（本身更小时只做背景铺白）
+func Thumbnail(src image.Image, maxSide int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return flattenRGBA(src)
+	}
+	if w >= h {
+		return Resize(src, maxSide, max(h*maxSide/w, 1))
+	}
+	return Resize(src, max(w*maxSide/h, 1), maxSide)
+}
+
+// 裁剪出 rect 区域（与原图求交集），支持 SubImage 的图片不复制像素
+func Crop(src image.Image, rect image.Rectangle) image.Image {
+	rect = rect.Intersect(src.Bounds())
+	if sub, ok := src.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, rect.Min, draw.Src)
+	return dst
+}
+
+// 居中裁出最大的正方形区域
+func CenterSquare(b image.Rectangle) image.Rectangle {
+	side := min(b.Dx(), b.Dy())
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	return image.Rect(x, y, x+side, y+side)
+}
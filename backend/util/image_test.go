@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// 先整张铺白再做盒式滤波的参考实现
+func referenceResize(src image.Image, w, h int) *image.RGBA {
+	in := flattenRGBA(src)
+	sw, sh := in.Rect.Dx(), in.Rect.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := y * sh / h
+		y1 := max((y+1)*sh/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := x * sw / w
+			x1 := max((x+1)*sw/w, x0+1)
+			var sum [4]uint32
+			var n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					p := in.Pix[sy*in.Stride+sx*4:]
+					for k := range sum {
+						sum[k] += uint32(p[k])
+					}
+					n++
+				}
+			}
+			for k := range sum {
+				dst.Pix[y*dst.Stride+x*4+k] = uint8(sum[k] / n)
+			}
+		}
+	}
+	return dst
+}
+
+func randomNRGBA(r *rand.Rand, w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	r.Read(img.Pix)
+	return img
+}
+
+func TestResizeMatchesReference(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	src := randomNRGBA(r, 37, 23)
+	sub := randomNRGBA(r, 50, 50).SubImage(image.Rect(7, 11, 40, 31)) // 原点不在 (0,0)
+	palette := image.NewPaletted(image.Rect(0, 0, 19, 29), color.Palette{color.Transparent, color.Black, color.RGBA{200, 30, 60, 255}})
+	for i := range palette.Pix {
+		palette.Pix[i] = uint8(r.Intn(3))
+	}
+
+	tests := []struct {
+		name string
+		src  image.Image
+		w, h int
+	}{
+		{name: "downscale", src: src, w: 10, h: 7},
+		{name: "downscale to one pixel", src: src, w: 1, h: 1},
+		{name: "upscale", src: src, w: 80, h: 50},
+		{name: "mixed", src: src, w: 74, h: 5},
+		{name: "same size", src: src, w: 37, h: 23},
+		{name: "sub image", src: sub, w: 9, h: 6},
+		{name: "paletted with transparency", src: palette, w: 6, h: 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Resize(tt.src, tt.w, tt.h)
+			want := referenceResize(tt.src, tt.w, tt.h)
+			if got.Rect != want.Rect || !bytes.Equal(got.Pix, want.Pix) {
+				t.Errorf("Resize(%v, %d, %d) differs from reference", tt.src.Bounds(), tt.w, tt.h)
+			}
+		})
+	}
+}
+
+func TestThumbnailSize(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	tests := []struct {
+		name         string
+		w, h         int
+		wantW, wantH int
+	}{
+		{name: "landscape", w: 640, h: 480, wantW: 320, wantH: 240},
+		{name: "portrait", w: 300, h: 900, wantW: 106, wantH: 320},
+		{name: "already small", w: 200, h: 100, wantW: 200, wantH: 100},
+		{name: "thin strip", w: 2000, h: 1, wantW: 320, wantH: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Thumbnail(randomNRGBA(r, tt.w, tt.h), 320).Bounds()
+			if b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+				t.Errorf("Thumbnail(%dx%d) = %dx%d, want %dx%d", tt.w, tt.h, b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	skipListMaxLevel = 32
+	skipListP        = 0.25
+)
+
+// SkipListEntry 跳表中的一个成员及其分数
+type SkipListEntry struct {
+	Member uint
+	Score  int
+}
+
+type skipListLevel struct {
+	forward *skipListNode
+	span    int // 到 forward 之间跨过的节点数，用于按名次定位
+}
+
+type skipListNode struct {
+	entry  SkipListEntry
+	levels []skipListLevel
+}
+
+// SkipList 按分数降序（同分按成员升序）排列的跳表，结构同 Redis 有序集合
+// 非并发安全，调用方负责加锁
+type SkipList struct {
+	head   *skipListNode
+	level  int
+	length int
+	scores map[uint]int
+	rnd    *rand.Rand
+}
+
+// NewSkipList 创建空跳表
+func NewSkipList() *SkipList {
+	return &SkipList{
+		head:   &skipListNode{levels: make([]skipListLevel, skipListMaxLevel)},
+		level:  1,
+		scores: make(map[uint]int),
+		rnd:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// a 是否排在 b 前面
+func skipListLess(a, b SkipListEntry) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.Member < b.Member
+}
+
+func (sl *SkipList) randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && sl.rnd.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+func (sl *SkipList) insert(e SkipListEntry) {
+	var update [skipListMaxLevel]*skipListNode
+	var rank [skipListMaxLevel]int
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].forward != nil && skipListLess(x.levels[i].forward.entry, e) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+	level := sl.randomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = sl.head
+			update[i].levels[i].span = sl.length
+		}
+		sl.level = level
+	}
+	node := &skipListNode{entry: e, levels: make([]skipListLevel, level)}
+	for i := 0; i < level; i++ {
+		node.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = node
+		node.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < sl.level; i++ {
+		update[i].levels[i].span++
+	}
+	sl.length++
+}
+
+func (sl *SkipList) remove(e SkipListEntry) {
+	var update [skipListMaxLevel]*skipListNode
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && skipListLess(x.levels[i].forward.entry, e) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+	x = x.levels[0].forward
+	if x == nil || x.entry != e {
+		return
+	}
+	for i := 0; i < sl.level; i++ {
+		if update[i].levels[i].forward == x {
+			update[i].levels[i].span += x.levels[i].span - 1
+			update[i].levels[i].forward = x.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	for sl.level > 1 && sl.head.levels[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+}
+
+// Set 设置成员分数（不存在时插入）
+func (sl *SkipList) Set(member uint, score int) {
+	if old, ok := sl.scores[member]; ok {
+		if old == score {
+			return
+		}
+		sl.remove(SkipListEntry{Member: member, Score: old})
+	}
+	sl.scores[member] = score
+	sl.insert(SkipListEntry{Member: member, Score: score})
+}
+
+// IncrBy 增加成员分数并返回新分数
+func (sl *SkipList) IncrBy(member uint, delta int) int {
+	score := sl.scores[member] + delta
+	sl.Set(member, score)
+	return score
+}
+
+// Remove 删除成员
+func (sl *SkipList) Remove(member uint) {
+	if old, ok := sl.scores[member]; ok {
+		sl.remove(SkipListEntry{Member: member, Score: old})
+		delete(sl.scores, member)
+	}
+}
+
+// Score 成员的分数
+func (sl *SkipList) Score(member uint) (int, bool) {
+	score, ok := sl.scores[member]
+	return score, ok
+}
+
+// Len 成员数量
+func (sl *SkipList) Len() int {
+	return sl.length
+}
+
+// Range 返回从第 offset 名（从0开始）起的 limit 个成员
+func (sl *SkipList) Range(offset, limit int) []SkipListEntry {
+	if offset < 0 || limit <= 0 || offset >= sl.length {
+		return nil
+	}
+	// 利用 span 跳到第 offset+1 个节点
+	target := offset + 1
+	traversed := 0
+	x := sl.head
+	for i := sl.level - 1; i >= 0 && traversed < target; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= target {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+	}
+	entries := make([]SkipListEntry, 0, min(limit, sl.length-offset))
+	for ; x != nil && len(entries) < limit; x = x.levels[0].forward {
+		entries = append(entries, x.entry)
+	}
+	return entries
+}
+
+// CountAbove 分数严格大于 score 的成员数量
+func (sl *SkipList) CountAbove(score int) int {
+	count := 0
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.entry.Score > score {
+			count += x.levels[i].span
+			x = x.levels[i].forward
+		}
+	}
+	return count
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSkipListRange(t *testing.T) {
+	sl := NewSkipList()
+	sl.Set(1, 10)
+	sl.Set(2, 30)
+	sl.Set(3, 20)
+	sl.Set(4, 20) // 同分按成员升序
+	sl.IncrBy(1, 25)
+	sl.Set(5, 5)
+	sl.Remove(5)
+
+	tests := []struct {
+		name          string
+		offset, limit int
+		want          []SkipListEntry
+	}{
+		{name: "all", offset: 0, limit: 10, want: []SkipListEntry{{1, 35}, {2, 30}, {3, 20}, {4, 20}}},
+		{name: "middle", offset: 1, limit: 2, want: []SkipListEntry{{2, 30}, {3, 20}}},
+		{name: "tail", offset: 3, limit: 5, want: []SkipListEntry{{4, 20}}},
+		{name: "offset past end", offset: 4, limit: 1, want: nil},
+		{name: "negative offset", offset: -1, limit: 1, want: nil},
+		{name: "zero limit", offset: 0, limit: 0, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sl.Range(tt.offset, tt.limit); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Range(%d, %d) = %v, want %v", tt.offset, tt.limit, got, tt.want)
+			}
+		})
+	}
+
+	if sl.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", sl.Len())
+	}
+	if _, ok := sl.Score(5); ok {
+		t.Errorf("removed member still has a score")
+	}
+	if got := sl.CountAbove(20); got != 2 {
+		t.Errorf("CountAbove(20) = %d, want 2", got)
+	}
+}
+
+// 随机操作后与排序切片逐项对比，覆盖多层节点的 span 维护
+func TestSkipListMatchesSortedSlice(t *testing.T) {
+	sl := NewSkipList()
+	sl.rnd = rand.New(rand.NewSource(1))
+	rnd := rand.New(rand.NewSource(2))
+	scores := make(map[uint]int)
+
+	for i := 0; i < 5000; i++ {
+		member := uint(rnd.Intn(300))
+		switch rnd.Intn(4) {
+		case 0:
+			sl.Remove(member)
+			delete(scores, member)
+		case 1:
+			delta := rnd.Intn(21) - 10
+			sl.IncrBy(member, delta)
+			scores[member] += delta
+		default:
+			score := rnd.Intn(100)
+			sl.Set(member, score)
+			scores[member] = score
+		}
+	}
+
+	want := make([]SkipListEntry, 0, len(scores))
+	for member, score := range scores {
+		want = append(want, SkipListEntry{Member: member, Score: score})
+	}
+	sort.Slice(want, func(i, j int) bool { return skipListLess(want[i], want[j]) })
+
+	if sl.Len() != len(want) {
+		t.Fatalf("Len() = %d, want %d", sl.Len(), len(want))
+	}
+	if got := sl.Range(0, len(want)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("full range does not match sorted entries")
+	}
+	for offset := 0; offset < len(want); offset += 7 {
+		end := min(offset+5, len(want))
+		if got := sl.Range(offset, 5); !reflect.DeepEqual(got, want[offset:end]) {
+			t.Fatalf("Range(%d, 5) = %v, want %v", offset, got, want[offset:end])
+		}
+	}
+	for _, e := range want {
+		above := sort.Search(len(want), func(i int) bool { return want[i].Score <= e.Score })
+		if got := sl.CountAbove(e.Score); got != above {
+			t.Fatalf("CountAbove(%d) = %d, want %d", e.Score, got, above)
+		}
+		if score, ok := sl.Score(e.Member); !ok || score != e.Score {
+			t.Fatalf("Score(%d) = %d, %v, want %d", e.Member, score, ok, e.Score)
+		}
+	}
+}
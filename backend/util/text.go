@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	maxMentionsPerText = 10
+	maxTagsPerText     = 10
+)
+
+var (
+	// @用户名：用户名由字母（含中文）、数字、下划线和减号组成，以空格或标点结束
+	mentionPattern = regexp.MustCompile(`@([\p{L}\p{N}_\-]{1,32})`)
+	// #话题 或 #话题#，话题名由字母（含中文）、数字和下划线组成
+	hashtagPattern = regexp.MustCompile(`#([\p{L}\p{N}_]{1,32})#?`)
+)
+
+// 匹配位置前紧跟英文字母、数字、下划线或点时不算（如邮箱 a@b.com）
+func attachedToWord(text string, start int) bool {
+	if start == 0 {
+		return false
+	}
+	b := text[start-1]
+	return b == '_' || b == '.' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// 取出每个匹配的第一个分组，去重并保持出现顺序
+func uniqueMatches(re *regexp.Regexp, text string, limit int, normalize func(string) string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+		if attachedToWord(text, m[0]) {
+			continue
+		}
+		v := normalize(text[m[2]:m[3]])
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		result = append(result, v)
+		if len(result) >= limit {
+			break
+		}
+	}
+	return result
+}
+
+// ParseMentions 解析文本中 @ 到的用户名（最多 10 个）
+func ParseMentions(text string) []string {
+	return uniqueMatches(mentionPattern, text, maxMentionsPerText, func(s string) string { return s })
+}
+
+// ParseHashtags 解析文本中的话题（统一转小写，最多 10 个）
+func ParseHashtags(text string) []string {
+	return uniqueMatches(hashtagPattern, text, maxTagsPerText, strings.ToLower)
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "empty", text: "", want: nil},
+		{name: "single", text: "你好 @alice", want: []string{"alice"}},
+		{name: "chinese and punctuation", text: "@小明，@bob_1! @carol-x。", want: []string{"小明", "bob_1", "carol-x"}},
+		{name: "dedupe keeps order", text: "@bob @alice @bob", want: []string{"bob", "alice"}},
+		{name: "case preserved", text: "@Alice @alice", want: []string{"Alice", "alice"}},
+		{name: "email skipped", text: "联系 me@example.com 或 @admin", want: []string{"admin"}},
+		{name: "attached to underscore", text: "a_@b x.@c", want: nil},
+		{name: "bare at", text: "@ @@", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseMentions(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMentions(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "empty", text: "", want: nil},
+		{name: "open tag", text: "今天 #考研 加油", want: []string{"考研"}},
+		{name: "closed tag", text: "#考研#冲刺 #四六级#", want: []string{"考研", "四六级"}},
+		{name: "lowercased and deduped", text: "#Go #GO #go_lang", want: []string{"go", "go_lang"}},
+		{name: "attached to word", text: "issue#12 a.#b", want: nil},
+		{name: "bare hash", text: "# ##", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseHashtags(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHashtags(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	var mentions, tags []string
+	for i := 0; i < 15; i++ {
+		mentions = append(mentions, fmt.Sprintf("@user%d", i))
+		tags = append(tags, fmt.Sprintf("#tag%d", i))
+	}
+	if got := ParseMentions(strings.Join(mentions, " ")); len(got) != maxMentionsPerText || got[0] != "user0" {
+		t.Errorf("ParseMentions returned %v, want first %d mentions", got, maxMentionsPerText)
+	}
+	if got := ParseHashtags(strings.Join(tags, " ")); len(got) != maxTagsPerText || got[maxTagsPerText-1] != "tag9" {
+		t.Errorf("ParseHashtags returned %v, want first %d tags", got, maxTagsPerText)
+	}
+}
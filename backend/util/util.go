@@ -142,13 +142,38 @@ func GenerateCode() string {
 	return fmt.Sprintf("%06d", num%1_000_000)
 }
 
-// GetAvatarPath 根据用户的 HeadShow 字段获取头像路径
-// headShow: 用户选择的头像编号（1-36）
+// 生成邀请码（8位，去掉了容易混淆的字符）
+func GenerateInviteCode() string {
+	const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	for i, b := range buf {
+		buf[i] = alphabet[int(b)%len(alphabet)]
+	}
+	return string(buf)
+}
+
+// 内置头像数量（编号 1-32，与 ServeAvatar 中的文件列表一致）
+const BuiltinAvatarCount = 32
+
+// 自定义头像的标准尺寸（正方形边长）
+const (
+	AvatarSizeLarge = 256
+	AvatarSizeSmall = 64
+)
+
+// GetAvatarPath 根据用户的 HeadShow 字段获取内置头像路径
+// headShow: 用户选择的头像编号（1-32）
 // 返回: 头像的API路径，用于前端访问
 func GetAvatarPath(headShow int) string {
-	if headShow > 0 && headShow <= 36 {
+	if headShow > 0 && headShow <= BuiltinAvatarCount {
 		return fmt.Sprintf("/api/avatar/%d", headShow)
 	}
 	// 默认返回第一个头像
 	return "/api/avatar/1"
 }
+
+// GetCustomAvatarPath 返回用户上传头像指定尺寸的访问路径
+func GetCustomAvatarPath(key string, size int) string {
+	return fmt.Sprintf("/api/avatars/%s_%d.jpg", key, size)
+}